@@ -16,58 +16,217 @@ type Session struct {
 	ID      string
 	History []llm.ChatMessage
 	Plan    string
+	// Steps is Plan parsed into steps whose progress the coder reports.
+	Steps []PlanStep
+	// PlanVersion counts the plans set for the session, the first being 1.
+	PlanVersion int
 	// LastReflection holds the latest reflection content to feed into the next turn.
 	LastReflection string
+	// Summary condenses history that was compacted away; it is pinned ahead of History.
+	Summary string
+
+	persist sync.Mutex // serializes the session's records and their writes to the store
 }
 
+// maxToolResultBytes caps a single tool result kept in session history.
+const maxToolResultBytes = 16 * 1024
+
 // Agent orchestrates chat calls with history and context handling.
 type Agent struct {
 	registry *llm.Registry
 	cfg      config.AgentConfig
 
-	mu       sync.Mutex
-	sessions map[string]*Session
+	mu    sync.Mutex // guards session fields
+	store SessionStore
+
+	prompts   *PromptTemplates
+	env       PromptEnv
+	structure func() (string, error)
 }
 
-// New creates a new Agent.
+// New creates a new Agent. Sessions are kept in memory within the agent.sessions limits;
+// use SetSessionStore to keep them elsewhere. System prompts come from the built-in
+// templates until SetPromptTemplates replaces them.
 func New(registry *llm.Registry, cfg config.AgentConfig) *Agent {
+	limits := SessionLimits{TTL: cfg.Sessions.TTL, MaxSessions: cfg.Sessions.MaxSessions, MaxBytes: cfg.Sessions.MaxBytes}
 	return &Agent{
 		registry: registry,
 		cfg:      cfg,
-		sessions: make(map[string]*Session),
+		store:    NewMemorySessionStore(limits),
+		prompts:  DefaultPromptTemplates(),
 	}
 }
 
+// SetSessionStore replaces the store sessions are opened from and persisted to.
+func (a *Agent) SetSessionStore(store SessionStore) {
+	a.store = store
+}
+
+// SetPromptTemplates replaces the system prompt templates.
+func (a *Agent) SetPromptTemplates(p *PromptTemplates) {
+	a.prompts = p
+}
+
+// SetPromptEnv sets the workspace description the prompt templates render.
+func (a *Agent) SetPromptEnv(env PromptEnv) {
+	a.env = env
+}
+
+// SetPromptStructure has every system prompt describe the workspace with fn instead of
+// the fixed PromptEnv.Structure, so the tree follows the files runs create.
+func (a *Agent) SetPromptStructure(fn func() (string, error)) {
+	a.structure = fn
+}
+
 // Run executes a single-turn agent call, maintaining session history.
 func (a *Agent) Run(ctx context.Context, req Request) (Response, error) {
+	turn, err := a.prepareTurn(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	resp, err := turn.provider.Chat(ctx, turn.chatReq)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return a.finishTurn(req, turn, resp.Message, resp.FinishReason, resp.Usage)
+}
+
+// RunStream behaves like Run but streams the reply through Provider.Stream,
+// invoking onDelta for each content delta while the model is still generating.
+// The assembled message is appended to session history once the stream ends.
+func (a *Agent) RunStream(ctx context.Context, req Request, onDelta func(delta string)) (Response, error) {
+	turn, err := a.prepareTurn(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	// Cancelling stops a provider that keeps sending after a chunk error we return on.
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	turn.chatReq.Stream = true
+	chunks, errCh := turn.provider.Stream(streamCtx, turn.chatReq)
+
+	var (
+		content      strings.Builder
+		toolCalls    []llm.ToolCall
+		finishReason string
+		usage        llm.Usage
+	)
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			return Response{}, chunk.Err
+		}
+		if chunk.Content != "" {
+			content.WriteString(chunk.Content)
+			if onDelta != nil {
+				onDelta(chunk.Content)
+			}
+		}
+		toolCalls = append(toolCalls, chunk.ToolCalls...)
+		if chunk.FinishReason != "" {
+			finishReason = chunk.FinishReason
+		}
+		if chunk.Usage != (llm.Usage{}) {
+			usage = chunk.Usage
+		}
+	}
+	if err := <-errCh; err != nil {
+		return Response{}, err
+	}
+
+	msg := llm.ChatMessage{Role: llm.RoleAssistant, Content: content.String(), ToolCalls: toolCalls}
+	return a.finishTurn(req, turn, msg, finishReason, usage)
+}
+
+// turn carries the prepared state of a single Run/RunStream call.
+type turn struct {
+	provider      llm.Provider
+	route         llm.ModelRoute
+	session       *Session
+	pending       []llm.ChatMessage
+	chatReq       llm.ChatRequest
+	prevAssistant string
+}
+
+func (a *Agent) prepareTurn(ctx context.Context, req Request) (turn, error) {
 	if req.Prompt == "" {
-		return Response{}, fmt.Errorf("prompt is required")
+		return turn{}, fmt.Errorf("prompt is required")
 	}
 
 	provider, route, err := a.registry.Resolve(req.Model)
 	if err != nil {
-		return Response{}, err
+		return turn{}, err
 	}
 
 	if a.cfg.EnablePlan {
 		if _, err := a.Plan(ctx, req); err != nil {
-			return Response{}, err
+			return turn{}, err
 		}
 	}
 
-	session := a.ensureSession(req.SessionID)
+	session, err := a.ensureSession(req.SessionID)
+	if err != nil {
+		return turn{}, err
+	}
+
+	prevAssistant := a.lastAssistantContent(session)
+	messages, pending, err := a.turnMessages(session, req)
+	if err != nil {
+		return turn{}, err
+	}
+	if a.needsCompaction(route, messages) {
+		compacted, err := a.compact(ctx, req, session)
+		if err != nil {
+			return turn{}, err
+		}
+		if compacted {
+			if messages, pending, err = a.turnMessages(session, req); err != nil {
+				return turn{}, err
+			}
+		}
+	}
 
-	systemPrompt := buildSystemPrompt(a.cfg)
-	userPrompt := buildUserPrompt(req.Prompt, req.Context)
+	return turn{
+		provider: provider,
+		route:    route,
+		session:  session,
+		pending:  pending,
+		chatReq: llm.ChatRequest{
+			Role:        RoleCoder,
+			Model:       route.Model,
+			Messages:    messages,
+			Tools:       req.Tools,
+			MaxTokens:   pickMaxTokens(a.cfg.MaxTokens, route.MaxTokens),
+			Temperature: pickTemperature(a.cfg.Temperature, route.Temperature),
+			Stream:      false,
+		},
+		prevAssistant: prevAssistant,
+	}, nil
+}
 
+// turnMessages assembles the coder prompt: system prompt, plan, compacted-history summary
+// and latest reflection, then history and the pending user turn, which is also returned.
+func (a *Agent) turnMessages(session *Session, req Request) ([]llm.ChatMessage, []llm.ChatMessage, error) {
+	system, err := a.systemPrompt(RoleCoder, session, req.Instructions)
+	if err != nil {
+		return nil, nil, err
+	}
 	messages := []llm.ChatMessage{
-		{Role: llm.RoleSystem, Content: systemPrompt},
+		{Role: llm.RoleSystem, Content: system},
 	}
-	prevAssistant := a.lastAssistantContent(session)
-	if plan := a.sessionPlan(session); plan != "" {
+	if plan, structured := a.sessionPlan(session); plan != "" {
+		content := "Planned steps:\n" + plan
+		if structured {
+			content += "\n\n" + buildPlanProgressPrompt()
+		}
+		messages = append(messages, llm.ChatMessage{Role: llm.RoleAssistant, Content: content})
+	}
+	if summary := a.sessionSummary(session); summary != "" {
 		messages = append(messages, llm.ChatMessage{
 			Role:    llm.RoleAssistant,
-			Content: "Planned steps:\n" + plan,
+			Content: "Summary of earlier steps:\n" + summary,
 		})
 	}
 	if reflection := a.sessionReflection(session); reflection != "" {
@@ -76,29 +235,35 @@ func (a *Agent) Run(ctx context.Context, req Request) (Response, error) {
 			Content: "Previous reflection:\n" + reflection,
 		})
 	}
-	messages = append(messages, session.History...)
-	messages = append(messages, llm.ChatMessage{Role: llm.RoleUser, Content: userPrompt})
+	history := a.sessionHistory(session)
+	messages = append(messages, history...)
 
-	chatReq := llm.ChatRequest{
-		Model:       route.Model,
-		Messages:    messages,
-		MaxTokens:   pickMaxTokens(a.cfg.MaxTokens, route.MaxTokens),
-		Temperature: pickTemperature(a.cfg.Temperature, route.Temperature),
-		Stream:      false,
+	// A continued turn picks up from the tool results and reflections already in
+	// history instead of repeating the task; it only needs a nudge when the model
+	// spoke last.
+	var pending []llm.ChatMessage
+	if req.Continue && len(history) > 0 {
+		if history[len(history)-1].Role == llm.RoleAssistant {
+			pending = append(pending, llm.ChatMessage{Role: llm.RoleUser, Content: buildContinuePrompt()})
+		}
+	} else {
+		pending = append(pending, llm.ChatMessage{Role: llm.RoleUser, Content: buildUserPrompt(req.Prompt, req.Context)})
 	}
+	return append(messages, pending...), pending, nil
+}
 
-	resp, err := provider.Chat(ctx, chatReq)
-	if err != nil {
+func (a *Agent) finishTurn(req Request, t turn, msg llm.ChatMessage, finishReason string, usage llm.Usage) (Response, error) {
+	req.Usage.Add(RoleCoder, t.route, usage)
+	if err := a.record(t.session, SessionRecord{Messages: append(t.pending, msg)}); err != nil {
 		return Response{}, err
 	}
 
-	a.appendHistory(session, messages[len(messages)-1], resp.Message)
-
 	return Response{
-		Message:           resp.Message,
-		Route:             route,
-		FinishReason:      resp.FinishReason,
-		PreviousAssistant: prevAssistant,
+		Message:           msg,
+		Route:             t.route,
+		FinishReason:      finishReason,
+		Usage:             usage,
+		PreviousAssistant: t.prevAssistant,
 	}, nil
 }
 
@@ -110,8 +275,11 @@ func (a *Agent) Plan(ctx context.Context, req Request) (string, error) {
 	if strings.TrimSpace(req.Prompt) == "" {
 		return "", fmt.Errorf("prompt is required")
 	}
-	session := a.ensureSession(req.SessionID)
-	if plan := a.sessionPlan(session); plan != "" {
+	session, err := a.ensureSession(req.SessionID)
+	if err != nil {
+		return "", err
+	}
+	if plan := a.sessionPlanText(session); plan != "" {
 		return plan, nil
 	}
 
@@ -120,12 +288,17 @@ func (a *Agent) Plan(ctx context.Context, req Request) (string, error) {
 		return "", err
 	}
 
+	system, err := a.systemPrompt(RolePlanner, session, req.Instructions)
+	if err != nil {
+		return "", err
+	}
 	messages := []llm.ChatMessage{
-		{Role: llm.RoleSystem, Content: buildPlanSystemPrompt(a.cfg)},
+		{Role: llm.RoleSystem, Content: system},
 		{Role: llm.RoleUser, Content: buildPlanUserPrompt(req.Prompt)},
 	}
 
 	chatReq := llm.ChatRequest{
+		Role:        RolePlanner,
 		Model:       route.Model,
 		Messages:    messages,
 		MaxTokens:   pickMaxTokens(a.cfg.MaxTokens, route.MaxTokens),
@@ -137,9 +310,12 @@ func (a *Agent) Plan(ctx context.Context, req Request) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	req.Usage.Add(RolePlanner, route, resp.Usage)
 
 	plan := strings.TrimSpace(resp.Message.Content)
-	a.setPlan(session, plan)
+	if err := a.record(session, SessionRecord{Plan: &plan, Steps: ParsePlan(plan)}); err != nil {
+		return "", err
+	}
 	return plan, nil
 }
 
@@ -148,22 +324,32 @@ func (a *Agent) Reflect(ctx context.Context, req Request, last Response, ctxInfo
 	if !a.cfg.EnableReflect {
 		return "", nil
 	}
-	if strings.TrimSpace(last.Message.Content) == "" {
+	lastContent := describeAssistantMessage(last.Message)
+	if lastContent == "" {
 		return "", fmt.Errorf("last message is required for reflection")
 	}
 
-	session := a.ensureSession(req.SessionID)
+	session, err := a.ensureSession(req.SessionID)
+	if err != nil {
+		return "", err
+	}
 	provider, route, err := a.registry.Resolve(req.Model)
 	if err != nil {
 		return "", err
 	}
 
+	system, err := a.systemPrompt(RoleCritic, session, req.Instructions)
+	if err != nil {
+		return "", err
+	}
+	plan, _ := a.sessionPlan(session)
 	messages := []llm.ChatMessage{
-		{Role: llm.RoleSystem, Content: buildReflectSystemPrompt(a.cfg)},
-		{Role: llm.RoleUser, Content: buildReflectUserPrompt(req.Prompt, last.Message.Content, a.sessionPlan(session), ctxInfo)},
+		{Role: llm.RoleSystem, Content: system},
+		{Role: llm.RoleUser, Content: buildReflectUserPrompt(req.Prompt, lastContent, plan, ctxInfo)},
 	}
 
 	chatReq := llm.ChatRequest{
+		Role:        RoleCritic,
 		Model:       route.Model,
 		Messages:    messages,
 		MaxTokens:   pickMaxTokens(a.cfg.MaxTokens, route.MaxTokens),
@@ -175,13 +361,144 @@ func (a *Agent) Reflect(ctx context.Context, req Request, last Response, ctxInfo
 	if err != nil {
 		return "", err
 	}
+	req.Usage.Add(RoleCritic, route, resp.Usage)
 
 	reflection := strings.TrimSpace(resp.Message.Content)
-	a.setReflection(session, reflection)
-	a.appendReflection(session, reflection)
+	err = a.record(session, SessionRecord{
+		Messages:   []llm.ChatMessage{{Role: llm.RoleAssistant, Content: "Reflection: " + reflection}},
+		Reflection: &reflection,
+	})
+	if err != nil {
+		return "", err
+	}
 	return reflection, nil
 }
 
+// AppendToolResult records a tool result so the next turn can observe it. Results
+// of native calls become tool-role messages linked to callID; calls without an ID
+// (text-parsed or client-supplied) are recorded as a user-role observation.
+func (a *Agent) AppendToolResult(sessionID, callID, toolName, output string) error {
+	session, err := a.ensureSession(sessionID)
+	if err != nil {
+		return err
+	}
+	output = truncateForPrompt(output, maxToolResultBytes)
+
+	msg := llm.ChatMessage{
+		Role:       llm.RoleTool,
+		Name:       toolName,
+		Content:    output,
+		ToolCallID: callID,
+	}
+	if callID == "" {
+		msg = llm.ChatMessage{Role: llm.RoleUser, Content: buildToolResultPrompt(toolName, output)}
+	}
+	return a.record(session, SessionRecord{Messages: []llm.ChatMessage{msg}})
+}
+
+// AppendUserInput records a message the user sent while a run was in progress, so the
+// next turn answers it.
+func (a *Agent) AppendUserInput(sessionID, text string) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("user input is empty")
+	}
+	session, err := a.ensureSession(sessionID)
+	if err != nil {
+		return err
+	}
+	msg := llm.ChatMessage{Role: llm.RoleUser, Content: buildUserInputPrompt(text)}
+	return a.record(session, SessionRecord{Messages: []llm.ChatMessage{msg}})
+}
+
+// CurrentPlan returns the session's plan.
+func (a *Agent) CurrentPlan(sessionID string) (PlanRevision, error) {
+	session, err := a.ensureSession(sessionID)
+	if err != nil {
+		return PlanRevision{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return PlanRevision{Text: session.Plan, Steps: clonePlanSteps(session.Steps), Version: session.PlanVersion}, nil
+}
+
+// Replan asks the planner to revise the session plan in light of failing tests or a poor
+// critique. Steps carried over unchanged keep their progress; the plan version grows by one.
+func (a *Agent) Replan(ctx context.Context, req Request, why ReplanContext) (PlanRevision, error) {
+	session, err := a.ensureSession(req.SessionID)
+	if err != nil {
+		return PlanRevision{}, err
+	}
+	provider, route, err := a.registry.Resolve(req.Model)
+	if err != nil {
+		return PlanRevision{}, err
+	}
+
+	system, err := a.systemPrompt(RoleReplanner, session, req.Instructions)
+	if err != nil {
+		return PlanRevision{}, err
+	}
+	current, _ := a.sessionPlan(session)
+	resp, err := provider.Chat(ctx, llm.ChatRequest{
+		Role:  RoleReplanner,
+		Model: route.Model,
+		Messages: []llm.ChatMessage{
+			{Role: llm.RoleSystem, Content: system},
+			{Role: llm.RoleUser, Content: buildReplanUserPrompt(req.Prompt, current, why)},
+		},
+		MaxTokens:   pickMaxTokens(a.cfg.MaxTokens, route.MaxTokens),
+		Temperature: pickTemperature(a.cfg.Temperature, route.Temperature),
+	})
+	if err != nil {
+		return PlanRevision{}, err
+	}
+	req.Usage.Add(RolePlanner, route, resp.Usage)
+
+	text := strings.TrimSpace(resp.Message.Content)
+	if text == "" {
+		return PlanRevision{}, fmt.Errorf("planner returned an empty revision")
+	}
+	a.mu.Lock()
+	steps := mergePlanRevision(clonePlanSteps(session.Steps), ParsePlan(text))
+	a.mu.Unlock()
+	if err := a.record(session, SessionRecord{Plan: &text, Steps: clonePlanSteps(steps)}); err != nil {
+		return PlanRevision{}, err
+	}
+	return a.CurrentPlan(req.SessionID)
+}
+
+// UpdatePlan applies the progress of one coder step to the session plan: a revised plan,
+// step status markers, and the step's tool calls and test run, which are linked to the
+// active step.
+func (a *Agent) UpdatePlan(sessionID string, p PlanProgress) (PlanUpdate, error) {
+	session, err := a.ensureSession(sessionID)
+	if err != nil {
+		return PlanUpdate{}, err
+	}
+	a.mu.Lock()
+	current := clonePlanSteps(session.Steps)
+	a.mu.Unlock()
+
+	steps, notes := applyPlanProgress(current, p)
+	if len(notes) == 0 {
+		return PlanUpdate{Steps: steps, Version: a.planVersion(session)}, nil
+	}
+	rec := SessionRecord{Steps: clonePlanSteps(steps)}
+	if m := planBlockRe.FindStringSubmatch(p.Content); m != nil && len(ParsePlan(m[1])) > 0 {
+		text := strings.TrimSpace(m[1])
+		rec.Plan = &text
+	}
+	if err := a.record(session, rec); err != nil {
+		return PlanUpdate{}, err
+	}
+	return PlanUpdate{Steps: steps, Notes: notes, Version: a.planVersion(session)}, nil
+}
+
+// MaxReplans returns how many automatic plan revisions a run may make.
+func (a *Agent) MaxReplans() int {
+	return a.cfg.MaxReplans
+}
+
 // MaxSteps returns configured maximum steps (>0).
 func (a *Agent) MaxSteps() int {
 	if a.cfg.MaxSteps > 0 {
@@ -234,6 +551,16 @@ func (a *Agent) TestTimeoutSeconds() int {
 	return a.cfg.TestTimeoutSeconds
 }
 
+// QuestionTimeout returns how long a run waits for the answer to a question (0 = until the run ends).
+func (a *Agent) QuestionTimeout() time.Duration {
+	return a.cfg.QuestionTimeout
+}
+
+// Instructions returns which workspace instruction files to load and their byte budget.
+func (a *Agent) Instructions() config.InstructionsConfig {
+	return a.cfg.Instructions
+}
+
 // MaxContextBytes returns the limit for aggregated context bytes (0 = unlimited).
 func (a *Agent) MaxContextBytes() int {
 	if a.cfg.MaxContextBytes < 0 {
@@ -247,33 +574,48 @@ func (a *Agent) EnableSelfDiff() bool {
 	return a.cfg.EnableSelfDiff
 }
 
-func (a *Agent) ensureSession(id string) *Session {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
+func (a *Agent) ensureSession(id string) (*Session, error) {
 	if id == "" {
 		id = fmt.Sprintf("sess-%d", time.Now().UnixNano())
 	}
+	s, err := a.store.Open(id)
+	if err != nil {
+		return nil, fmt.Errorf("open session %s: %w", id, err)
+	}
+	return s, nil
+}
+
+// record applies rec to the session and persists it to the store. Only applying rec
+// takes the agent lock; the store write holds the session's own lock, so slow store IO
+// does not hold up other sessions.
+func (a *Agent) record(s *Session, rec SessionRecord) error {
+	s.persist.Lock()
+	defer s.persist.Unlock()
 
-	if s, ok := a.sessions[id]; ok {
-		return s
+	rec.Time = time.Now()
+	a.mu.Lock()
+	rec.apply(s)
+	a.mu.Unlock()
+	if err := a.store.Append(s, rec); err != nil {
+		return fmt.Errorf("persist session %s: %w", s.ID, err)
 	}
-	s := &Session{ID: id, History: make([]llm.ChatMessage, 0, 8)}
-	a.sessions[id] = s
-	return s
+	return nil
 }
 
-func (a *Agent) appendHistory(s *Session, userMsg llm.ChatMessage, assistantMsg llm.ChatMessage) {
+func (a *Agent) sessionHistory(s *Session) []llm.ChatMessage {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	s.History = append(s.History, userMsg, assistantMsg)
+	return append([]llm.ChatMessage(nil), s.History...)
 }
 
 func (a *Agent) lastAssistantContent(s *Session) string {
 	if s == nil {
 		return ""
 	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	for i := len(s.History) - 1; i >= 0; i-- {
 		if s.History[i].Role == llm.RoleAssistant {
 			return s.History[i].Content
@@ -282,42 +624,62 @@ func (a *Agent) lastAssistantContent(s *Session) string {
 	return ""
 }
 
-func (a *Agent) setPlan(s *Session, plan string) {
+// sessionPlan returns the plan for prompts, rendered with step progress when it parsed
+// into steps.
+func (a *Agent) sessionPlan(s *Session) (string, bool) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	s.Plan = plan
+	if len(s.Steps) > 0 {
+		return RenderPlan(s.Steps), true
+	}
+	return s.Plan, false
+}
+
+// systemPrompt renders the role's template with the workspace, the session plan and the
+// run's instruction files.
+func (a *Agent) systemPrompt(role string, s *Session, instructions []ContextFile) (string, error) {
+	plan, _ := a.sessionPlan(s)
+	a.mu.Lock()
+	steps := clonePlanSteps(s.Steps)
+	a.mu.Unlock()
+	env := a.env
+	if a.structure != nil {
+		structure, err := a.structure()
+		if err != nil {
+			return "", fmt.Errorf("describe workspace: %w", err)
+		}
+		env.Structure = structure
+	}
+	return a.prompts.Render(role, PromptData{PromptEnv: env, Plan: plan, Steps: steps, Instructions: instructions})
 }
 
-func (a *Agent) sessionPlan(s *Session) string {
+func (a *Agent) planVersion(s *Session) int {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	return s.Plan
+	return s.PlanVersion
 }
 
-func (a *Agent) setReflection(s *Session, reflection string) {
+func (a *Agent) sessionPlanText(s *Session) string {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	s.LastReflection = reflection
+	return s.Plan
 }
 
-func (a *Agent) sessionReflection(s *Session) string {
+func (a *Agent) sessionSummary(s *Session) string {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	return s.LastReflection
+	return s.Summary
 }
 
-func (a *Agent) appendReflection(s *Session, reflection string) {
+func (a *Agent) sessionReflection(s *Session) string {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	s.History = append(s.History, llm.ChatMessage{
-		Role:    llm.RoleAssistant,
-		Content: "Reflection: " + reflection,
-	})
+	return s.LastReflection
 }
 
 func pickTemperature(agentTemp float64, routeTemp float64) float64 {
@@ -339,3 +701,22 @@ func pickMaxTokens(agentMax int, routeMax int) int {
 	}
 	return 0
 }
+
+// describeAssistantMessage renders an assistant turn for the critic. Steps that only
+// requested native tool calls have no text, so the calls themselves are listed.
+func describeAssistantMessage(msg llm.ChatMessage) string {
+	content := strings.TrimSpace(msg.Content)
+	if len(msg.ToolCalls) == 0 {
+		return content
+	}
+	var b strings.Builder
+	if content != "" {
+		b.WriteString(content)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Requested tool calls:")
+	for _, tc := range msg.ToolCalls {
+		fmt.Fprintf(&b, "\n- %s %s", tc.Function.Name, string(tc.Function.Arguments))
+	}
+	return b.String()
+}
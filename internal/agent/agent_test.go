@@ -2,8 +2,10 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -93,6 +95,28 @@ func TestAgentReflectionFeedsNextRun(t *testing.T) {
 	require.Equal(t, 3, callCount)
 }
 
+func TestAgentReflectsOnToolCallOnlyResponse(t *testing.T) {
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			require.Contains(t, req.Messages[1].Content, "Requested tool calls:\n- fs.read_file {\"path\":\"a.go\"}")
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "fine"}}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+	a := New(reg, config.AgentConfig{EnableReflect: true})
+
+	last := Response{Message: llm.ChatMessage{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{
+		Function: llm.ToolFunctionCall{Name: "fs.read_file", Arguments: []byte(`{"path":"a.go"}`)},
+	}}}}
+	reflection, err := a.Reflect(context.Background(), Request{SessionID: "r-tools", Prompt: "task"}, last, ReflectionContext{})
+	require.NoError(t, err)
+	require.Equal(t, "fine", reflection)
+
+	_, err = a.Reflect(context.Background(), Request{SessionID: "r-tools", Prompt: "task"}, Response{}, ReflectionContext{})
+	require.Error(t, err)
+}
+
 func TestAgentUsesRegistryAndHistory(t *testing.T) {
 	reg := llm.NewRegistry()
 	mockProvider := &llmmock.Provider{
@@ -164,7 +188,8 @@ func TestPickers(t *testing.T) {
 }
 
 func TestSystemPromptIncludesInstruction(t *testing.T) {
-	sp := buildSystemPrompt(config.AgentConfig{})
+	sp, err := DefaultPromptTemplates().Render(RoleCoder, PromptData{})
+	require.NoError(t, err)
 	require.True(t, strings.Contains(sp, "MyCodex"))
 }
 
@@ -177,3 +202,340 @@ func TestMaxStepsDefault(t *testing.T) {
 	a2 := New(reg, config.AgentConfig{MaxSteps: 5})
 	require.Equal(t, 5, a2.MaxSteps())
 }
+
+func TestAgentRunStreamForwardsDeltasAndRecordsHistory(t *testing.T) {
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		StreamChunks: []llm.StreamChunk{
+			{Content: "Hel"},
+			{Content: "lo"},
+			{FinishReason: "stop"},
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	a := New(reg, config.AgentConfig{})
+	var deltas []string
+	resp, err := a.RunStream(context.Background(), Request{SessionID: "s1", Prompt: "greet"}, func(d string) {
+		deltas = append(deltas, d)
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Hel", "lo"}, deltas)
+	require.Equal(t, "Hello", resp.Message.Content)
+	require.Equal(t, "stop", resp.FinishReason)
+
+	session := mustSession(t, a, "s1")
+	require.Len(t, session.History, 2)
+	require.Equal(t, "Hello", session.History[1].Content)
+}
+
+func TestAgentRunStreamRecordsUsage(t *testing.T) {
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		StreamChunks: []llm.StreamChunk{
+			{Content: "Hi"},
+			{FinishReason: "stop", Usage: llm.Usage{PromptTokens: 2000, CompletionTokens: 100}},
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m", InputCostPerMTok: 1, OutputCostPerMTok: 10}, true)
+
+	a := New(reg, config.AgentConfig{})
+	ledger := NewUsageLedger()
+	for i := 0; i < 2; i++ {
+		resp, err := a.RunStream(context.Background(), Request{SessionID: "s1", Prompt: "greet", Usage: ledger}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 2000, resp.Usage.PromptTokens)
+	}
+
+	require.Equal(t, []UsageEntry{{
+		Role:    RoleCoder,
+		Model:   "default",
+		Calls:   2,
+		Usage:   llm.Usage{PromptTokens: 4000, CompletionTokens: 200, TotalTokens: 4200},
+		CostUSD: 0.006,
+	}}, ledger.Entries())
+	total, cost := ledger.Total()
+	require.Equal(t, 4200, total.TotalTokens)
+	require.InDelta(t, 0.006, cost, 1e-12)
+
+	// A nil ledger is a no-op.
+	var none *UsageLedger
+	none.Add(RoleCoder, llm.ModelRoute{}, llm.Usage{PromptTokens: 1})
+	require.Empty(t, none.Entries())
+}
+
+func TestAgentRunStreamPropagatesError(t *testing.T) {
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		StreamChunks: []llm.StreamChunk{{Content: "partial"}},
+		StreamErr:    errors.New("stream broke"),
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	a := New(reg, config.AgentConfig{})
+	_, err := a.RunStream(context.Background(), Request{SessionID: "s2", Prompt: "greet"}, nil)
+	require.ErrorContains(t, err, "stream broke")
+	require.Empty(t, mustSession(t, a, "s2").History)
+}
+
+// chattyStreamProvider reports a chunk error and keeps streaming until its context ends.
+type chattyStreamProvider struct {
+	llmmock.Provider
+	stopped chan struct{}
+}
+
+func (p *chattyStreamProvider) Stream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, <-chan error) {
+	ch := make(chan llm.StreamChunk, 16)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(p.stopped)
+		defer close(ch)
+		defer close(errCh)
+		ch <- llm.StreamChunk{Err: errors.New("bad chunk")}
+		for {
+			select {
+			case ch <- llm.StreamChunk{Content: "more"}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, errCh
+}
+
+func TestAgentRunStreamStopsProviderAfterChunkError(t *testing.T) {
+	provider := &chattyStreamProvider{stopped: make(chan struct{})}
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", provider)
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	a := New(reg, config.AgentConfig{})
+	_, err := a.RunStream(context.Background(), Request{SessionID: "chunk-err", Prompt: "greet"}, nil)
+	require.ErrorContains(t, err, "bad chunk")
+	select {
+	case <-provider.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("provider still streaming after RunStream returned")
+	}
+}
+
+func TestAgentContinueUsesToolResultsInsteadOfPrompt(t *testing.T) {
+	reg := llm.NewRegistry()
+	var requests [][]llm.ChatMessage
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			requests = append(requests, req.Messages)
+			if len(requests) == 1 {
+				return llm.ChatResponse{Message: llm.ChatMessage{
+					Role: llm.RoleAssistant,
+					ToolCalls: []llm.ToolCall{{
+						ID:       "call_1",
+						Function: llm.ToolFunctionCall{Name: "fs.read_file", Arguments: []byte(`{"path":"a.go"}`)},
+					}},
+				}, FinishReason: "tool_calls"}, nil
+			}
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "read it"}}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+	a := New(reg, config.AgentConfig{})
+
+	_, err := a.Run(context.Background(), Request{SessionID: "c1", Prompt: "inspect a.go"})
+	require.NoError(t, err)
+	require.NoError(t, a.AppendToolResult("c1", "call_1", "fs.read_file", "package a"))
+
+	_, err = a.Run(context.Background(), Request{SessionID: "c1", Prompt: "inspect a.go", Continue: true})
+	require.NoError(t, err)
+
+	second := requests[1]
+	last := second[len(second)-1]
+	require.Equal(t, llm.RoleTool, last.Role)
+	require.Equal(t, "call_1", last.ToolCallID)
+	require.Equal(t, "package a", last.Content)
+	var prompts int
+	for _, m := range second {
+		if m.Role == llm.RoleUser && strings.Contains(m.Content, "inspect a.go") {
+			prompts++
+		}
+	}
+	require.Equal(t, 1, prompts, "task prompt should not be repeated")
+
+	// With the model speaking last, a continued turn adds a short nudge.
+	_, err = a.Run(context.Background(), Request{SessionID: "c1", Prompt: "inspect a.go", Continue: true})
+	require.NoError(t, err)
+	third := requests[2]
+	require.Equal(t, llm.RoleUser, third[len(third)-1].Role)
+	require.Contains(t, third[len(third)-1].Content, "Continue working")
+}
+
+func TestAgentAppendToolResultWithoutCallID(t *testing.T) {
+	a := New(llm.NewRegistry(), config.AgentConfig{})
+	require.NoError(t, a.AppendToolResult("obs", "", "terminal.exec", ""))
+
+	history := mustSession(t, a, "obs").History
+	require.Len(t, history, 1)
+	require.Equal(t, llm.RoleUser, history[0].Role)
+	require.Equal(t, "Tool terminal.exec returned:\n(no output)", history[0].Content)
+}
+
+func mustSession(t *testing.T, a *Agent, id string) *Session {
+	t.Helper()
+	s, err := a.ensureSession(id)
+	require.NoError(t, err)
+	return s
+}
+
+func TestAgentCompactsHistoryNearContextWindow(t *testing.T) {
+	reg := llm.NewRegistry()
+	var coderRequests [][]llm.ChatMessage
+	var summarized []string
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			switch req.Role {
+			case RoleSummarizer:
+				require.Equal(t, "cheap", req.Model)
+				summarized = append(summarized, req.Messages[1].Content)
+				return llm.ChatResponse{
+					Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "Read a.go and b.go; both compile."},
+					Usage:   llm.Usage{PromptTokens: 300, CompletionTokens: 20},
+				}, nil
+			case RolePlanner:
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "1. read files"}}, nil
+			}
+			coderRequests = append(coderRequests, req.Messages)
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "reading"}}, nil
+		},
+	})
+	reg.RegisterModel("coder", llm.ModelRoute{Provider: "mock", Model: "coder", ContextWindow: 400}, true)
+	reg.RegisterModel("cheap", llm.ModelRoute{Provider: "mock", Model: "cheap"}, false)
+
+	a := New(reg, config.AgentConfig{
+		EnablePlan: true,
+		Compaction: config.CompactionConfig{Model: "cheap", Threshold: 0.5, KeepRecent: 2},
+	})
+	ledger := NewUsageLedger()
+	req := Request{SessionID: "long", Prompt: "inspect the files", Usage: ledger}
+	_, err := a.Run(context.Background(), req)
+	require.NoError(t, err)
+	for _, file := range []string{"a.go", "b.go"} {
+		require.NoError(t, a.AppendToolResult("long", "", "fs.read_file", file+": "+strings.Repeat("x", 400)))
+		req.Continue = true
+		_, err = a.Run(context.Background(), req)
+		require.NoError(t, err)
+	}
+
+	require.NotEmpty(t, summarized)
+	require.Contains(t, summarized[0], "inspect the files")
+	session := mustSession(t, a, "long")
+	require.Equal(t, "Read a.go and b.go; both compile.", session.Summary)
+	require.Equal(t, "1. read files", session.Plan, "the plan is kept verbatim")
+	require.LessOrEqual(t, len(session.History), 4)
+
+	last := coderRequests[len(coderRequests)-1]
+	require.True(t, strings.HasPrefix(last[1].Content, "Planned steps:\n1. read files\n\nReport progress"))
+	require.Equal(t, "Summary of earlier steps:\nRead a.go and b.go; both compile.", last[2].Content)
+	require.Contains(t, last[len(last)-1].Content, "b.go: xxx", "the latest tool result stays verbatim")
+
+	var summarizerCalls int
+	for _, e := range ledger.Entries() {
+		if e.Role == RoleSummarizer {
+			summarizerCalls = e.Calls
+		}
+	}
+	require.Equal(t, len(summarized), summarizerCalls)
+}
+
+func TestCompactionCutKeepsToolResultsWithTheirCall(t *testing.T) {
+	history := []llm.ChatMessage{
+		{Role: llm.RoleUser, Content: "task"},
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "c1"}, {ID: "c2"}}},
+		{Role: llm.RoleTool, ToolCallID: "c1"},
+		{Role: llm.RoleTool, ToolCallID: "c2"},
+		{Role: llm.RoleAssistant, Content: "done"},
+	}
+	require.Equal(t, 1, compactionCut(history, 2))
+	require.Equal(t, 4, compactionCut(history, 1))
+	require.Equal(t, 0, compactionCut(history, 10))
+}
+
+func TestPlanProgressMarkersLinksAndRevisions(t *testing.T) {
+	steps := ParsePlan("Plan:\n1. Read main.go\n   - check flags\n2) Add the flag\n3. Run tests")
+	require.Len(t, steps, 3)
+	require.Equal(t, PlanStep{ID: 2, Description: "Add the flag", Status: StepPending}, steps[1])
+	require.Len(t, ParsePlan("- read\n- write"), 2)
+
+	steps, notes := applyPlanProgress(steps, PlanProgress{
+		Content:   "Read it. [step 1 done] [Step 2 in progress]",
+		ToolCalls: []PlanToolCall{{ID: "c1", Name: "fs.write_file"}},
+	})
+	require.Equal(t, []string{"Step 1 done: Read main.go", "Step 2 in progress: Add the flag", "Step 2 ran fs.write_file"}, notes)
+	require.Equal(t, []PlanToolCall{{ID: "c1", Name: "fs.write_file"}}, steps[1].ToolCalls)
+
+	steps, notes = applyPlanProgress(steps, PlanProgress{Test: &TestObservation{ExitCode: 1, Failing: []string{"TestFlag"}}})
+	require.Equal(t, []string{"Step 2 tests failed"}, notes)
+	require.Equal(t, []string{"TestFlag"}, steps[1].Tests[0].Failing)
+
+	steps, notes = applyPlanProgress(steps, PlanProgress{Content: "[plan]\n1. Read main.go\n2. Add the flag\n3. Fix TestFlag\n4. Run tests\n[/plan]"})
+	require.Equal(t, []string{"Plan revised (4 steps)"}, notes)
+	require.Equal(t, StepDone, steps[0].Status)
+	require.Len(t, steps[1].Tests, 1, "unchanged steps keep their links")
+	require.Equal(t, StepPending, steps[2].Status)
+	require.Equal(t, "1. [done] Read main.go\n2. [in_progress] Add the flag\n3. Fix TestFlag\n4. Run tests", RenderPlan(steps))
+
+	_, notes = applyPlanProgress(steps, PlanProgress{Content: "[step 1 done] [step 9 done]"})
+	require.Empty(t, notes, "repeated and unknown markers change nothing")
+}
+
+func TestAgentReplanKeepsProgressAndBumpsVersion(t *testing.T) {
+	var replanPrompt string
+	var roles []string
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			roles = append(roles, req.Role)
+			if req.Role == RoleReplanner {
+				replanPrompt = req.Messages[1].Content
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "1. [done] Read main.go\n2. Fix TestFlag\n3. Run tests"}}, nil
+			}
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "1. Read main.go\n2. Add the flag"}}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+	a := New(reg, config.AgentConfig{EnablePlan: true, EnableReflect: true})
+
+	_, err := a.Plan(context.Background(), Request{SessionID: "replan", Prompt: "add a flag"})
+	require.NoError(t, err)
+	update, err := a.UpdatePlan("replan", PlanProgress{Content: "[step 1 done]"})
+	require.NoError(t, err)
+	require.Equal(t, 1, update.Version)
+
+	usage := NewUsageLedger()
+	rev, err := a.Replan(context.Background(), Request{SessionID: "replan", Prompt: "add a flag", Usage: usage}, ReplanContext{
+		Test:     &TestObservation{ExitCode: 1, Summary: "FAIL", Failing: []string{"TestFlag"}},
+		Critique: `{"quality":"poor","issues":["flag is never parsed"]}`,
+	})
+	require.NoError(t, err)
+	require.Contains(t, replanPrompt, "1. [done] Read main.go")
+	require.Contains(t, replanPrompt, "Failing tests: TestFlag")
+	require.Contains(t, replanPrompt, "flag is never parsed")
+
+	require.Equal(t, 2, rev.Version)
+	require.Equal(t, []PlanStep{
+		{ID: 1, Description: "Read main.go", Status: StepDone},
+		{ID: 2, Description: "Fix TestFlag", Status: StepPending},
+		{ID: 3, Description: "Run tests", Status: StepPending},
+	}, rev.Steps)
+	entries := usage.Entries()
+	require.Len(t, entries, 1)
+	require.Equal(t, RolePlanner, entries[0].Role)
+
+	current, err := a.CurrentPlan("replan")
+	require.NoError(t, err)
+	require.Equal(t, rev, current)
+
+	last, err := a.Run(context.Background(), Request{SessionID: "replan", Prompt: "add a flag"})
+	require.NoError(t, err)
+	_, err = a.Reflect(context.Background(), Request{SessionID: "replan", Prompt: "add a flag"}, last, ReflectionContext{})
+	require.NoError(t, err)
+	require.Equal(t, []string{RolePlanner, RoleReplanner, RoleCoder, RoleCritic}, roles, "every call names its role")
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
+)
+
+// Compaction defaults, used when agent.compaction leaves them zero.
+const (
+	defaultCompactThreshold  = 0.75
+	defaultCompactKeepRecent = 6
+)
+
+// estimateTokens approximates the prompt size of messages at four bytes per token plus
+// a small per-message overhead; it only needs to be good enough to trigger compaction.
+func estimateTokens(msgs []llm.ChatMessage) int {
+	n := 0
+	for _, m := range msgs {
+		size := len(m.Content) + len(m.Name)
+		for _, tc := range m.ToolCalls {
+			size += len(tc.Function.Name) + len(tc.Function.Arguments)
+		}
+		n += size/4 + 4
+	}
+	return n
+}
+
+// needsCompaction reports whether messages fill more than the threshold share of the
+// route's context window. Routes without a known window are never compacted.
+func (a *Agent) needsCompaction(route llm.ModelRoute, messages []llm.ChatMessage) bool {
+	if route.ContextWindow <= 0 {
+		return false
+	}
+	threshold := a.cfg.Compaction.Threshold
+	if threshold <= 0 {
+		threshold = defaultCompactThreshold
+	}
+	return float64(estimateTokens(messages)) > threshold*float64(route.ContextWindow)
+}
+
+// compact folds the session history older than the most recent messages, together with
+// any previous summary, into a new pinned summary written by the compaction model. The
+// plan and latest reflection live outside history and are kept as they are. It reports
+// whether the history changed.
+func (a *Agent) compact(ctx context.Context, req Request, s *Session) (bool, error) {
+	keep := a.cfg.Compaction.KeepRecent
+	if keep <= 0 {
+		keep = defaultCompactKeepRecent
+	}
+	history := a.sessionHistory(s)
+	cut := compactionCut(history, keep)
+	if cut == 0 {
+		return false, nil
+	}
+
+	provider, route, err := a.registry.Resolve(firstNonEmpty(a.cfg.Compaction.Model, req.Model))
+	if err != nil {
+		return false, fmt.Errorf("compact history: %w", err)
+	}
+	system, err := a.systemPrompt(RoleSummarizer, s, nil)
+	if err != nil {
+		return false, fmt.Errorf("compact history: %w", err)
+	}
+	resp, err := provider.Chat(ctx, llm.ChatRequest{
+		Role:  RoleSummarizer,
+		Model: route.Model,
+		Messages: []llm.ChatMessage{
+			{Role: llm.RoleSystem, Content: system},
+			{Role: llm.RoleUser, Content: buildSummarizeUserPrompt(a.sessionSummary(s), history[:cut])},
+		},
+		MaxTokens:   pickMaxTokens(a.cfg.MaxTokens, route.MaxTokens),
+		Temperature: pickTemperature(a.cfg.Temperature, route.Temperature),
+	})
+	if err != nil {
+		return false, fmt.Errorf("compact history: %w", err)
+	}
+	req.Usage.Add(RoleSummarizer, route, resp.Usage)
+
+	summary := strings.TrimSpace(resp.Message.Content)
+	if summary == "" {
+		return false, fmt.Errorf("compact history: summarizer returned no summary")
+	}
+	if err := a.record(s, SessionRecord{Dropped: cut, Summary: &summary}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// compactionCut returns how many leading history messages to summarize so that the last
+// keep messages stay verbatim. The cut never separates tool results from the assistant
+// message that requested them.
+func compactionCut(history []llm.ChatMessage, keep int) int {
+	cut := len(history) - keep
+	for cut > 0 && history[cut].Role == llm.RoleTool {
+		cut--
+	}
+	return max(cut, 0)
+}
@@ -0,0 +1,185 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
+)
+
+// Complexity labels a task for initial route selection.
+type Complexity string
+
+// Complexity labels, mapped to coder routes by strategy.complexity.routes.
+const (
+	ComplexityTrivial  Complexity = "trivial"
+	ComplexityStandard Complexity = "standard"
+	ComplexityHard     Complexity = "hard"
+)
+
+// ParseComplexity returns the first label word in s, ignoring case and punctuation.
+func ParseComplexity(s string) (Complexity, bool) {
+	for _, w := range lowerWords(s) {
+		switch c := Complexity(w); c {
+		case ComplexityTrivial, ComplexityStandard, ComplexityHard:
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// lowerWords splits s into lower-case words of letters and hyphens.
+func lowerWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '-'
+	})
+}
+
+// TaskSignals are the inputs available to a classifier before the coder runs.
+type TaskSignals struct {
+	Prompt       string
+	ContextFiles int
+	ContextBytes int
+	PlanSteps    int // 0 when planning is disabled
+	// Usage, when set, records the tokens spent by classifiers that call a model.
+	Usage *UsageLedger
+}
+
+// Classifier estimates task complexity.
+type Classifier interface {
+	Classify(ctx context.Context, sig TaskSignals) (Complexity, error)
+}
+
+// Prompt wording hints, matched as whole words or phrases; a trailing "*" marks a stem
+// matched as a word prefix.
+var (
+	trivialHints = []string{"typo*", "renam*", "comment", "comments", "docstring*", "spelling", "wording", "bump", "log message", "format", "formatting"}
+	hardHints    = []string{"refactor*", "architecture", "redesign*", "migrat*", "concurren*", "race", "races", "performance", "across", "rewrit*", "security"}
+)
+
+// HeuristicClassifier scores the prompt length and wording, the context size and the
+// plan length; it needs no model call.
+type HeuristicClassifier struct{}
+
+// Classify implements Classifier.
+func (HeuristicClassifier) Classify(_ context.Context, sig TaskSignals) (Complexity, error) {
+	points := 0
+	words := len(strings.Fields(sig.Prompt))
+	switch {
+	case words <= 20:
+		points--
+	case words >= 120:
+		points++
+	}
+
+	prompt := lowerWords(sig.Prompt)
+	if hasHint(prompt, trivialHints) {
+		points--
+	}
+	if hasHint(prompt, hardHints) {
+		points++
+	}
+
+	switch {
+	case sig.PlanSteps >= 10:
+		points += 2
+	case sig.PlanSteps >= 6:
+		points++
+	case sig.PlanSteps > 0 && sig.PlanSteps <= 2:
+		points--
+	}
+
+	if sig.ContextFiles >= 8 || sig.ContextBytes >= 48*1024 {
+		points++
+	}
+
+	switch {
+	case points <= -2:
+		return ComplexityTrivial, nil
+	case points >= 2:
+		return ComplexityHard, nil
+	default:
+		return ComplexityStandard, nil
+	}
+}
+
+// LLMClassifier asks a model to judge complexity and uses Fallback when the call fails
+// or the answer names no label.
+type LLMClassifier struct {
+	Registry *llm.Registry
+	Model    string
+	Fallback Classifier
+}
+
+// Classify implements Classifier.
+func (c LLMClassifier) Classify(ctx context.Context, sig TaskSignals) (Complexity, error) {
+	label, err := c.judge(ctx, sig)
+	if err == nil {
+		return label, nil
+	}
+	if c.Fallback == nil {
+		return "", err
+	}
+	return c.Fallback.Classify(ctx, sig)
+}
+
+func (c LLMClassifier) judge(ctx context.Context, sig TaskSignals) (Complexity, error) {
+	if c.Registry == nil {
+		return "", fmt.Errorf("classifier registry is required")
+	}
+	provider, route, err := c.Registry.Resolve(c.Model)
+	if err != nil {
+		return "", err
+	}
+	resp, err := provider.Chat(ctx, llm.ChatRequest{
+		Role:  RoleClassifier,
+		Model: route.Model,
+		Messages: []llm.ChatMessage{
+			{Role: llm.RoleSystem, Content: buildClassifySystemPrompt()},
+			{Role: llm.RoleUser, Content: buildClassifyUserPrompt(sig)},
+		},
+		MaxTokens: 8,
+	})
+	if err != nil {
+		return "", err
+	}
+	sig.Usage.Add(RoleClassifier, route, resp.Usage)
+	label, ok := ParseComplexity(resp.Message.Content)
+	if !ok {
+		return "", fmt.Errorf("classifier answered %q", strings.TrimSpace(resp.Message.Content))
+	}
+	return label, nil
+}
+
+// CountPlanSteps counts the steps ParsePlan finds in a plan.
+func CountPlanSteps(plan string) int {
+	return len(ParsePlan(plan))
+}
+
+// hasHint reports whether one of hints occurs in words.
+func hasHint(words []string, hints []string) bool {
+	for _, hint := range hints {
+		parts := strings.Fields(hint)
+		for i := 0; i+len(parts) <= len(words); i++ {
+			if matchHint(words[i:i+len(parts)], parts) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchHint(words, parts []string) bool {
+	for i, part := range parts {
+		if stem, ok := strings.CutSuffix(part, "*"); ok {
+			if !strings.HasPrefix(words[i], stem) {
+				return false
+			}
+		} else if words[i] != part {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,229 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PlanStepStatus is the progress of one plan step.
+type PlanStepStatus string
+
+// Plan step statuses.
+const (
+	StepPending    PlanStepStatus = "pending"
+	StepInProgress PlanStepStatus = "in_progress"
+	StepDone       PlanStepStatus = "done"
+	StepSkipped    PlanStepStatus = "skipped"
+)
+
+// PlanStep is one parsed plan step with the tool calls and test runs made while it was active.
+type PlanStep struct {
+	ID          int              `json:"id"`
+	Description string           `json:"description"`
+	Status      PlanStepStatus   `json:"status"`
+	ToolCalls   []PlanToolCall   `json:"tool_calls,omitempty"`
+	Tests       []PlanTestResult `json:"tests,omitempty"`
+}
+
+// PlanToolCall links a tool call to a plan step.
+type PlanToolCall struct {
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// PlanTestResult links a test run to a plan step.
+type PlanTestResult struct {
+	ExitCode int      `json:"exit_code"`
+	Summary  string   `json:"summary,omitempty"`
+	Failing  []string `json:"failing,omitempty"`
+}
+
+// PlanProgress is what one coder step reports against the plan.
+type PlanProgress struct {
+	// Content is the coder message; it may carry [step N done|skipped|in progress] markers
+	// and a full revised plan inside [plan]...[/plan].
+	Content   string
+	ToolCalls []PlanToolCall
+	Test      *TestObservation
+}
+
+// PlanUpdate is the plan after applying progress; Notes describe the changes and are
+// empty when nothing changed.
+type PlanUpdate struct {
+	Steps   []PlanStep
+	Notes   []string
+	Version int
+}
+
+// PlanRevision is a session plan: its text, parsed steps and version, which starts at 1
+// and grows with every revision.
+type PlanRevision struct {
+	Text    string
+	Steps   []PlanStep
+	Version int
+}
+
+// ReplanContext is the evidence that the current plan no longer holds.
+type ReplanContext struct {
+	Test     *TestObservation // a run with failing tests after the coder claimed it was done
+	Critique string           // a critique judging the last step poor
+}
+
+var (
+	numberedStepRe = regexp.MustCompile(`^\s*\d+[.)]\s+(\S.*)$`)
+	bulletStepRe   = regexp.MustCompile(`^\s*[-*]\s+(\S.*)$`)
+	stepMarkerRe   = regexp.MustCompile(`(?i)\[step\s+(\d+)\s*:?\s*(done|skipped|in[ _-]?progress|started)\]`)
+	planBlockRe    = regexp.MustCompile(`(?is)\[plan\](.*?)\[/plan\]`)
+	statusTagRe    = regexp.MustCompile(`^\[(pending|in_progress|done|skipped)\]\s*`)
+)
+
+// ParsePlan splits plan text into steps numbered from 1: its numbered lines, or its bullet
+// lines when nothing is numbered. Other lines are ignored. Steps are pending unless they
+// start with a status tag as written by RenderPlan.
+func ParsePlan(text string) []PlanStep {
+	var numbered, bullets []string
+	for _, line := range strings.Split(text, "\n") {
+		if m := numberedStepRe.FindStringSubmatch(line); m != nil {
+			numbered = append(numbered, strings.TrimSpace(m[1]))
+		} else if m := bulletStepRe.FindStringSubmatch(line); m != nil {
+			bullets = append(bullets, strings.TrimSpace(m[1]))
+		}
+	}
+	if len(numbered) == 0 {
+		numbered = bullets
+	}
+	steps := make([]PlanStep, 0, len(numbered))
+	for i, desc := range numbered {
+		status := StepPending
+		if m := statusTagRe.FindStringSubmatch(desc); m != nil {
+			status = PlanStepStatus(m[1])
+			desc = desc[len(m[0]):]
+		}
+		steps = append(steps, PlanStep{ID: i + 1, Description: desc, Status: status})
+	}
+	return steps
+}
+
+// RenderPlan renders steps as a numbered checklist; pending steps carry no status tag.
+func RenderPlan(steps []PlanStep) string {
+	var b strings.Builder
+	for i, st := range steps {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		if st.Status == StepPending || st.Status == "" {
+			fmt.Fprintf(&b, "%d. %s", st.ID, st.Description)
+			continue
+		}
+		fmt.Fprintf(&b, "%d. [%s] %s", st.ID, st.Status, st.Description)
+	}
+	return b.String()
+}
+
+// applyPlanProgress applies a revision, then status markers, then links the tool calls
+// and test run to the active step. steps is not modified.
+func applyPlanProgress(steps []PlanStep, p PlanProgress) ([]PlanStep, []string) {
+	out := clonePlanSteps(steps)
+	var notes []string
+
+	if m := planBlockRe.FindStringSubmatch(p.Content); m != nil {
+		if revised := ParsePlan(m[1]); len(revised) > 0 {
+			out = mergePlanRevision(out, revised)
+			notes = append(notes, fmt.Sprintf("Plan revised (%d steps)", len(out)))
+		}
+	}
+
+	for _, m := range stepMarkerRe.FindAllStringSubmatch(p.Content, -1) {
+		id, _ := strconv.Atoi(m[1])
+		if id < 1 || id > len(out) {
+			continue
+		}
+		status := parseStepStatus(m[2])
+		if out[id-1].Status == status {
+			continue
+		}
+		out[id-1].Status = status
+		notes = append(notes, fmt.Sprintf("Step %d %s: %s", id, strings.ReplaceAll(string(status), "_", " "), out[id-1].Description))
+	}
+
+	if len(out) == 0 || (len(p.ToolCalls) == 0 && p.Test == nil) {
+		return out, notes
+	}
+	active := activePlanStep(out)
+	st := &out[active]
+	for _, tc := range p.ToolCalls {
+		st.ToolCalls = append(st.ToolCalls, tc)
+		notes = append(notes, fmt.Sprintf("Step %d ran %s", st.ID, tc.Name))
+	}
+	if p.Test != nil {
+		st.Tests = append(st.Tests, PlanTestResult{ExitCode: p.Test.ExitCode, Summary: p.Test.Summary, Failing: p.Test.Failing})
+		result := "passed"
+		if p.Test.ExitCode != 0 || p.Test.Error != "" {
+			result = "failed"
+		}
+		notes = append(notes, fmt.Sprintf("Step %d tests %s", st.ID, result))
+	}
+	return out, notes
+}
+
+// activePlanStep returns the index of the first step in progress, else the first pending
+// step, else the last step.
+func activePlanStep(steps []PlanStep) int {
+	pending := -1
+	for i, st := range steps {
+		if st.Status == StepInProgress {
+			return i
+		}
+		if pending < 0 && st.Status == StepPending {
+			pending = i
+		}
+	}
+	if pending >= 0 {
+		return pending
+	}
+	return len(steps) - 1
+}
+
+// mergePlanRevision keeps the status and links of revised steps whose description is
+// unchanged; the rest start pending.
+func mergePlanRevision(old, revised []PlanStep) []PlanStep {
+	byDesc := make(map[string]PlanStep, len(old))
+	for _, st := range old {
+		byDesc[strings.ToLower(st.Description)] = st
+	}
+	for i := range revised {
+		if prev, ok := byDesc[strings.ToLower(revised[i].Description)]; ok {
+			revised[i].Status = prev.Status
+			revised[i].ToolCalls = prev.ToolCalls
+			revised[i].Tests = prev.Tests
+		}
+	}
+	return revised
+}
+
+func parseStepStatus(s string) PlanStepStatus {
+	switch s = strings.ToLower(s); s {
+	case "done":
+		return StepDone
+	case "skipped":
+		return StepSkipped
+	default:
+		return StepInProgress
+	}
+}
+
+func clonePlanSteps(steps []PlanStep) []PlanStep {
+	if steps == nil {
+		return nil
+	}
+	out := make([]PlanStep, len(steps))
+	for i, st := range steps {
+		st.ToolCalls = append([]PlanToolCall(nil), st.ToolCalls...)
+		st.Tests = append([]PlanTestResult(nil), st.Tests...)
+		out[i] = st
+	}
+	return out
+}
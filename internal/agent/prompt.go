@@ -4,27 +4,42 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/animus-coder/animus-coder/internal/config"
+	"github.com/animus-coder/animus-coder/internal/llm"
 )
 
-// buildSystemPrompt returns the base system prompt given agent configuration.
-func buildSystemPrompt(cfg config.AgentConfig) string {
+// buildClassifySystemPrompt asks for a one-word complexity label.
+func buildClassifySystemPrompt() string {
 	return strings.TrimSpace(`
-You are MyCodex, a coding agent. Follow user instructions precisely, prefer minimal changes, and ask before destructive actions. Be concise in answers.`)
+You are MyCodex classification assistant. Judge how complex the user's coding task is and answer with exactly one word: trivial (a small, local edit such as a typo, rename or comment), standard (a typical change touching a few places) or hard (a multi-file refactor, tricky bug or design work).`)
 }
 
-// buildPlanSystemPrompt defines the planning instruction prompt.
-func buildPlanSystemPrompt(cfg config.AgentConfig) string {
-	return strings.TrimSpace(`
-You are MyCodex planning assistant. Draft a concise numbered plan (3-7 steps) to solve the user's task. Plans should include inspections, edits, validations, and tests when relevant. Do not execute actions; only outline the plan.`)
+// buildClassifyUserPrompt summarizes the task signals for the classifier.
+func buildClassifyUserPrompt(sig TaskSignals) string {
+	return fmt.Sprintf("Task:\n%s\n\nContext: %d files, %d bytes. Planned steps: %d.\n\nAnswer trivial, standard or hard.",
+		truncateForPrompt(sig.Prompt, 4000), sig.ContextFiles, sig.ContextBytes, sig.PlanSteps)
 }
 
-// buildReflectSystemPrompt instructs the model to critique the last action.
-func buildReflectSystemPrompt(cfg config.AgentConfig) string {
-	return strings.TrimSpace(`
-You are MyCodex reflection assistant. Briefly assess the last assistant response for issues, risks, or missing checks. Return a JSON object matching:
-{"quality":"good|ok|poor","issues":["..."],"recommendations":["..."],"block_apply":true|false,"notes":"optional free-text"}
-Be concise in text fields. Prefer block_apply=true only when you see critical risks.`)
+// buildSummarizeUserPrompt renders the previous summary and the messages being compacted.
+func buildSummarizeUserPrompt(previous string, msgs []llm.ChatMessage) string {
+	var b strings.Builder
+	if previous != "" {
+		b.WriteString("Summary so far:\n")
+		b.WriteString(previous)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Conversation to fold into the summary:\n")
+	for _, m := range msgs {
+		content := m.Content
+		if m.Role == llm.RoleAssistant {
+			content = describeAssistantMessage(m)
+		}
+		label := string(m.Role)
+		if m.Name != "" {
+			label += " " + m.Name
+		}
+		fmt.Fprintf(&b, "\n[%s]\n%s\n", label, truncateForPrompt(content, 4000))
+	}
+	return b.String()
 }
 
 // buildUserPrompt embeds user prompt with optional context files.
@@ -47,6 +62,49 @@ func buildUserPrompt(prompt string, ctx []ContextFile) string {
 	return b.String()
 }
 
+// buildContinuePrompt asks the model to proceed from the observations in history.
+func buildContinuePrompt() string {
+	return "Continue working on the task using the results above. Reply with [done] when the task is complete."
+}
+
+// buildToolResultPrompt formats a tool result that has no native call ID.
+func buildToolResultPrompt(toolName, output string) string {
+	if strings.TrimSpace(output) == "" {
+		output = "(no output)"
+	}
+	return fmt.Sprintf("Tool %s returned:\n%s", toolName, output)
+}
+
+// buildUserInputPrompt frames a message the user sent mid-run.
+func buildUserInputPrompt(text string) string {
+	return "Message from the user during the run (it takes precedence over earlier instructions):\n" + text
+}
+
+// buildPlanProgressPrompt tells the coder how to report plan progress.
+func buildPlanProgressPrompt() string {
+	return "Report progress with [step N in progress], [step N done] or [step N skipped]. If the plan must change, send the full revised numbered list inside [plan]...[/plan]."
+}
+
+// buildReplanUserPrompt shows the planner the current plan with progress and the evidence against it.
+func buildReplanUserPrompt(prompt, plan string, why ReplanContext) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Task:\n%s\n\nCurrent plan:\n%s\n", prompt, plan)
+	if t := why.Test; t != nil {
+		fmt.Fprintf(&b, "\nTests failed (exit %d).\n", t.ExitCode)
+		if len(t.Failing) > 0 {
+			fmt.Fprintf(&b, "Failing tests: %s\n", strings.Join(t.Failing, ", "))
+		}
+		if strings.TrimSpace(t.Output) != "" {
+			fmt.Fprintf(&b, "Output:\n%s\n", truncateForPrompt(t.Output, 2000))
+		}
+	}
+	if strings.TrimSpace(why.Critique) != "" {
+		fmt.Fprintf(&b, "\nCritique of the last step:\n%s\n", truncateForPrompt(why.Critique, 2000))
+	}
+	b.WriteString("\nReturn only the revised numbered plan.")
+	return b.String()
+}
+
 // buildPlanUserPrompt formats the user task for planning mode.
 func buildPlanUserPrompt(prompt string) string {
 	return fmt.Sprintf("Task:\n%s\n\nReturn only the numbered plan.", prompt)
@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/animus-coder/animus-coder/internal/config"
+	"github.com/animus-coder/animus-coder/internal/llm"
+)
+
+// RoleReplanner names the template used to revise a plan; its usage counts as planner.
+const RoleReplanner = llm.AgentRoleReplanner
+
+//go:embed prompts/*.tmpl
+var builtinPrompts embed.FS
+
+// PromptEnv describes the workspace the agent works in, as seen by prompt templates.
+type PromptEnv struct {
+	Workspace string               // workspace root
+	Structure string               // directory tree of the workspace root
+	Tools     []llm.ToolDefinition // tools offered to the coder
+	Sandbox   SandboxPolicy
+}
+
+// SandboxPolicy summarizes what tools may do in the workspace.
+type SandboxPolicy struct {
+	Enabled         bool
+	AllowWrite      bool
+	AllowExec       bool
+	AllowGit        bool
+	AllowNetwork    bool
+	AllowedCommands []string
+	DeniedCommands  []string
+}
+
+// PromptData is the input of a system prompt template.
+type PromptData struct {
+	PromptEnv
+	Role  string
+	Plan  string     // session plan with step statuses; empty before planning
+	Steps []PlanStep // the plan parsed into steps
+	// Instructions are the workspace instruction files of the run, outermost first.
+	Instructions []ContextFile
+}
+
+// PromptTemplates renders the system prompt of each role in config.PromptRoles.
+type PromptTemplates struct {
+	templates map[string]*template.Template
+	sources   map[string]string
+}
+
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// DefaultPromptTemplates returns the built-in templates.
+func DefaultPromptTemplates() *PromptTemplates {
+	p, err := LoadPromptTemplates("", config.PromptsConfig{})
+	if err != nil {
+		panic(err) // the built-in templates are compiled in and always parse
+	}
+	return p
+}
+
+// LoadPromptTemplates starts from the built-in templates and overrides a role with
+// cfg.Files[role] when set, else with <cfg.Dir>/<role>.tmpl when that file exists.
+// Relative paths are resolved against workspace. Every template, built-in or not, can
+// use the partials of prompts/partials.tmpl, e.g. {{template "instructions" .}}.
+func LoadPromptTemplates(workspace string, cfg config.PromptsConfig) (*PromptTemplates, error) {
+	partials, err := builtinPrompts.ReadFile("prompts/partials.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("built-in prompt partials: %w", err)
+	}
+	p := &PromptTemplates{templates: make(map[string]*template.Template), sources: make(map[string]string)}
+	for _, role := range config.PromptRoles {
+		text, err := builtinPrompts.ReadFile("prompts/" + role + ".tmpl")
+		if err != nil {
+			return nil, fmt.Errorf("built-in %s prompt: %w", role, err)
+		}
+		source := "built-in"
+
+		path, required := cfg.Files[role], true
+		if path == "" && cfg.Dir != "" {
+			path, required = filepath.Join(cfg.Dir, role+".tmpl"), false
+		}
+		if path != "" {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(workspace, path)
+			}
+			override, err := os.ReadFile(path)
+			switch {
+			case err == nil:
+				text, source = override, path
+			case errors.Is(err, os.ErrNotExist) && !required:
+			default:
+				return nil, fmt.Errorf("read %s prompt: %w", role, err)
+			}
+		}
+
+		tmpl := template.Must(template.New(role).Funcs(promptFuncs).Parse(string(partials)))
+		tmpl, err = tmpl.Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("parse %s prompt %s: %w", role, source, err)
+		}
+		p.templates[role] = tmpl
+		p.sources[role] = source
+	}
+	return p, nil
+}
+
+// Render executes the role's template; surrounding whitespace is trimmed.
+func (p *PromptTemplates) Render(role string, data PromptData) (string, error) {
+	tmpl, ok := p.templates[role]
+	if !ok {
+		return "", fmt.Errorf("no prompt template for role %q", role)
+	}
+	data.Role = role
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("render %s prompt: %w", role, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// Source reports where the role's template came from: "built-in" or a file path.
+func (p *PromptTemplates) Source(role string) string {
+	return p.sources[role]
+}
+
+// NewSandboxPolicy summarizes the sandbox and tool settings for prompts.
+func NewSandboxPolicy(sandbox config.SandboxConfig, tools config.ToolsConfig) SandboxPolicy {
+	return SandboxPolicy{
+		Enabled:         sandbox.Enabled,
+		AllowWrite:      sandbox.AllowWrite && tools.AllowFileWrite,
+		AllowExec:       sandbox.Enabled && tools.AllowExec,
+		AllowGit:        sandbox.Enabled && tools.AllowGit,
+		AllowNetwork:    sandbox.AllowNetwork,
+		AllowedCommands: sandbox.AllowedCommands,
+		DeniedCommands:  sandbox.DeniedCommands,
+	}
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/animus-coder/animus-coder/internal/config"
+	"github.com/animus-coder/animus-coder/internal/llm"
+	llmmock "github.com/animus-coder/animus-coder/internal/llm/mock"
+)
+
+func TestPromptTemplatesRenderBuiltinsWithWorkspace(t *testing.T) {
+	p := DefaultPromptTemplates()
+	for _, role := range config.PromptRoles {
+		require.Equal(t, "built-in", p.Source(role))
+	}
+
+	env := PromptEnv{
+		Structure: "./\n- go.mod\n- internal/",
+		Sandbox:   SandboxPolicy{Enabled: true, AllowExec: true, AllowedCommands: []string{"go", "git"}},
+	}
+	coder, err := p.Render(RoleCoder, PromptData{PromptEnv: env})
+	require.NoError(t, err)
+	require.Contains(t, coder, "Sandbox: file writes disabled, commands allowed (go, git), network disabled.")
+
+	planner, err := p.Render(RolePlanner, PromptData{PromptEnv: env})
+	require.NoError(t, err)
+	require.Contains(t, planner, "planning assistant")
+	require.Contains(t, planner, "Workspace structure:\n./\n- go.mod")
+
+	critic, err := p.Render(RoleCritic, PromptData{})
+	require.NoError(t, err)
+	require.Contains(t, critic, "reflection assistant")
+}
+
+func TestPromptTemplatesWorkspaceAndRoleOverrides(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(ws, ".mycodex", "prompts"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, ".mycodex", "prompts", "coder.tmpl"),
+		[]byte("Workspace coder for {{.Workspace}}.\n{{range .Tools}}- {{.Name}}: {{json .Parameters}}\n{{end}}{{with .Plan}}Plan:\n{{.}}{{end}}"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "critic.tmpl"), []byte("You are MyCodex reflection assistant for {{.Role}}.{{template \"instructions\" .}}"), 0o644))
+
+	p, err := LoadPromptTemplates(ws, config.PromptsConfig{Dir: ".mycodex/prompts", Files: map[string]string{RoleCritic: "critic.tmpl"}})
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(ws, ".mycodex/prompts/coder.tmpl"), p.Source(RoleCoder))
+	require.Equal(t, filepath.Join(ws, "critic.tmpl"), p.Source(RoleCritic))
+	require.Equal(t, "built-in", p.Source(RolePlanner), "roles without a workspace file keep the built-in template")
+
+	critic, err := p.Render(RoleCritic, PromptData{Instructions: []ContextFile{{Path: "AGENTS.md", Content: "Run go vet."}}})
+	require.NoError(t, err)
+	require.Equal(t, "You are MyCodex reflection assistant for critic.\n\nWorkspace instructions (files in deeper directories refine the ones above them):\n\nFrom AGENTS.md:\nRun go vet.", critic)
+
+	var system string
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			if llmmock.RequestRole(req) == llmmock.RolePlanner {
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "1. read\n2. fix"}}, nil
+			}
+			system = req.Messages[0].Content
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "ok"}}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+	a := New(reg, config.AgentConfig{EnablePlan: true})
+	a.SetPromptTemplates(p)
+	a.SetPromptEnv(PromptEnv{
+		Workspace: "/src/app",
+		Tools:     []llm.ToolDefinition{{Name: "fs.read_file", Parameters: map[string]interface{}{"type": "object"}}},
+	})
+
+	_, err = a.Run(context.Background(), Request{SessionID: "tmpl", Prompt: "fix it"})
+	require.NoError(t, err)
+	require.Equal(t, "Workspace coder for /src/app.\n- fs.read_file: {\"type\":\"object\"}\nPlan:\n1. read\n2. fix", system)
+
+	_, err = LoadPromptTemplates(ws, config.PromptsConfig{Files: map[string]string{RolePlanner: "missing.tmpl"}})
+	require.ErrorContains(t, err, "read planner prompt")
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "broken.tmpl"), []byte("{{.Plan"), 0o644))
+	_, err = LoadPromptTemplates(ws, config.PromptsConfig{Files: map[string]string{RoleCoder: "broken.tmpl"}})
+	require.ErrorContains(t, err, "parse coder prompt")
+}
+
+func TestPromptStructureFollowsWorkspaceChanges(t *testing.T) {
+	var plannerSystems []string
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			if req.Role == RolePlanner {
+				plannerSystems = append(plannerSystems, req.Messages[0].Content)
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "1. fix"}}, nil
+			}
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "ok"}}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	files := []string{"go.mod"}
+	a := New(reg, config.AgentConfig{EnablePlan: true})
+	a.SetPromptEnv(PromptEnv{Structure: "stale"})
+	a.SetPromptStructure(func() (string, error) { return "./\n- " + strings.Join(files, "\n- "), nil })
+
+	_, err := a.Run(context.Background(), Request{SessionID: "first", Prompt: "add main"})
+	require.NoError(t, err)
+	files = append(files, "main.go")
+	_, err = a.Run(context.Background(), Request{SessionID: "second", Prompt: "test main"})
+	require.NoError(t, err)
+
+	require.Len(t, plannerSystems, 2)
+	require.Contains(t, plannerSystems[0], "Workspace structure:\n./\n- go.mod")
+	require.NotContains(t, plannerSystems[0], "main.go")
+	require.Contains(t, plannerSystems[1], "- go.mod\n- main.go")
+}
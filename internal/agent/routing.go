@@ -0,0 +1,274 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults for adaptive routing statistics.
+const (
+	defaultStatsWindow   = 50
+	defaultMinSamples    = 5
+	defaultLatencyTarget = 30 * time.Second
+)
+
+// RouteStats keeps rolling outcome windows per role and model: call errors and latency,
+// test results after coder steps and critic verdicts. It is safe for concurrent use and a
+// nil *RouteStats ignores all records.
+type RouteStats struct {
+	mu     sync.Mutex
+	window int
+	path   string
+	routes map[[2]string]*routeWindow
+
+	saveMu sync.Mutex
+}
+
+// routeWindow holds the most recent outcomes of one role/model, oldest first.
+type routeWindow struct {
+	Role   string       `json:"role"`
+	Model  string       `json:"model"`
+	Calls  []callSample `json:"calls,omitempty"`
+	Tests  []bool       `json:"tests,omitempty"`
+	Blocks []bool       `json:"blocks,omitempty"`
+}
+
+type callSample struct {
+	Failed    bool  `json:"failed,omitempty"`
+	LatencyMS int64 `json:"latency_ms"`
+}
+
+// routeStatsFile is the persisted form of RouteStats.
+type routeStatsFile struct {
+	Version int            `json:"version"`
+	Routes  []*routeWindow `json:"routes"`
+}
+
+// RouteSummary is the aggregate view of one role/model window.
+type RouteSummary struct {
+	Role         string  `json:"role"`
+	Model        string  `json:"model"`
+	Calls        int     `json:"calls"`
+	ErrorRate    float64 `json:"error_rate"`
+	P95LatencyMS int64   `json:"p95_latency_ms"`
+	TestRuns     int     `json:"test_runs"`
+	TestPassRate float64 `json:"test_pass_rate"`
+	Critiques    int     `json:"critiques"`
+	BlockRate    float64 `json:"block_rate"`
+}
+
+// NewRouteStats creates in-memory statistics keeping window outcomes per role/model.
+func NewRouteStats(window int) *RouteStats {
+	if window <= 0 {
+		window = defaultStatsWindow
+	}
+	return &RouteStats{window: window, routes: make(map[[2]string]*routeWindow)}
+}
+
+// LoadRouteStats restores statistics persisted at path; a missing file starts empty.
+// Save writes back to the same path.
+func LoadRouteStats(path string, window int) (*RouteStats, error) {
+	s := NewRouteStats(window)
+	s.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read routing stats: %w", err)
+	}
+	var file routeStatsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decode routing stats %s: %w", path, err)
+	}
+	for _, w := range file.Routes {
+		if w == nil || w.Model == "" {
+			continue
+		}
+		w.Calls = tail(w.Calls, s.window)
+		w.Tests = tail(w.Tests, s.window)
+		w.Blocks = tail(w.Blocks, s.window)
+		s.routes[[2]string{w.Role, w.Model}] = w
+	}
+	return s, nil
+}
+
+// RecordCall records the outcome and latency of one model call in a role.
+func (s *RouteStats) RecordCall(role, model string, latency time.Duration, err error) {
+	s.update(role, model, func(w *routeWindow) {
+		w.Calls = tail(append(w.Calls, callSample{Failed: err != nil, LatencyMS: latency.Milliseconds()}), s.window)
+	})
+}
+
+// RecordTestResult records whether the tests passed after a step by the coder model.
+func (s *RouteStats) RecordTestResult(model string, passed bool) {
+	s.update(RoleCoder, model, func(w *routeWindow) {
+		w.Tests = tail(append(w.Tests, passed), s.window)
+	})
+}
+
+// RecordCritique records whether a critique by the critic model blocked the run.
+func (s *RouteStats) RecordCritique(model string, blocked bool) {
+	s.update(RoleCritic, model, func(w *routeWindow) {
+		w.Blocks = tail(append(w.Blocks, blocked), s.window)
+	})
+}
+
+func (s *RouteStats) update(role, model string, fn func(w *routeWindow)) {
+	if s == nil || model == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := [2]string{role, model}
+	w, ok := s.routes[key]
+	if !ok {
+		w = &routeWindow{Role: role, Model: model}
+		s.routes[key] = w
+	}
+	fn(w)
+}
+
+// Summary returns the aggregate statistics of one role/model.
+func (s *RouteStats) Summary(role, model string) RouteSummary {
+	if s == nil {
+		return RouteSummary{Role: role, Model: model}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.routes[[2]string{role, model}]
+	if !ok {
+		return RouteSummary{Role: role, Model: model}
+	}
+	return w.summary()
+}
+
+// Summaries returns every tracked role/model, sorted by role, then model.
+func (s *RouteStats) Summaries() []RouteSummary {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]RouteSummary, 0, len(s.routes))
+	for _, w := range s.routes {
+		out = append(out, w.summary())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Role != out[j].Role {
+			return out[i].Role < out[j].Role
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+// Save persists the statistics to the path they were loaded from; in-memory stats are not saved.
+func (s *RouteStats) Save() error {
+	if s == nil || s.path == "" {
+		return nil
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	file := routeStatsFile{Version: 1}
+	for _, w := range s.routes {
+		file.Routes = append(file.Routes, w)
+	}
+	sort.Slice(file.Routes, func(i, j int) bool {
+		if file.Routes[i].Role != file.Routes[j].Role {
+			return file.Routes[i].Role < file.Routes[j].Role
+		}
+		return file.Routes[i].Model < file.Routes[j].Model
+	})
+	data, err := json.MarshalIndent(file, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encode routing stats: %w", err)
+	}
+
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("create routing stats dir: %w", err)
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write routing stats: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("write routing stats: %w", err)
+	}
+	return nil
+}
+
+func (w *routeWindow) summary() RouteSummary {
+	out := RouteSummary{Role: w.Role, Model: w.Model, Calls: len(w.Calls), TestRuns: len(w.Tests), Critiques: len(w.Blocks)}
+	var (
+		failed    int
+		latencies []int64
+	)
+	for _, c := range w.Calls {
+		if c.Failed {
+			failed++
+			continue
+		}
+		latencies = append(latencies, c.LatencyMS)
+	}
+	if len(w.Calls) > 0 {
+		out.ErrorRate = float64(failed) / float64(len(w.Calls))
+	}
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		idx := int(math.Ceil(0.95*float64(len(latencies)))) - 1
+		out.P95LatencyMS = latencies[max(idx, 0)]
+	}
+	out.TestPassRate = share(w.Tests, true)
+	out.BlockRate = share(w.Blocks, true)
+	return out
+}
+
+// routeScore rates a summary between 0 and 1, higher is better. Statistics with fewer than
+// minSamples outcomes are ignored, so unproven models are ranked optimistically.
+func routeScore(sum RouteSummary, minSamples int, latencyTarget time.Duration) float64 {
+	score := 1.0
+	if sum.Calls >= minSamples {
+		score *= 1 - sum.ErrorRate
+		score /= 1 + float64(sum.P95LatencyMS)/float64(latencyTarget.Milliseconds())
+	}
+	if sum.TestRuns >= minSamples {
+		score *= sum.TestPassRate
+	}
+	if sum.Critiques >= minSamples {
+		score *= 1 - sum.BlockRate
+	}
+	return score
+}
+
+func share(vals []bool, want bool) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+	n := 0
+	for _, v := range vals {
+		if v == want {
+			n++
+		}
+	}
+	return float64(n) / float64(len(vals))
+}
+
+func tail[T any](vals []T, n int) []T {
+	if len(vals) <= n {
+		return vals
+	}
+	return append([]T(nil), vals[len(vals)-n:]...)
+}
@@ -0,0 +1,451 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/animus-coder/animus-coder/internal/config"
+	"github.com/animus-coder/animus-coder/internal/llm"
+)
+
+// SessionRecord is one append-only change to a session: new history messages, a new plan,
+// a new reflection or a compaction. Replaying a session's records in order rebuilds it.
+type SessionRecord struct {
+	Time     time.Time         `json:"time"`
+	Messages []llm.ChatMessage `json:"messages,omitempty"`
+	Plan     *string           `json:"plan,omitempty"`
+	// Steps is the structured plan; a record with Plan always replaces it.
+	Steps      []PlanStep `json:"steps,omitempty"`
+	Reflection *string    `json:"reflection,omitempty"`
+	// Dropped leading history messages are replaced by Summary.
+	Dropped int     `json:"dropped,omitempty"`
+	Summary *string `json:"summary,omitempty"`
+	// PlanVersion, set by snapshots, restores the plan count instead of counting Plan.
+	PlanVersion int `json:"plan_version,omitempty"`
+}
+
+// compacts reports whether the record replaces earlier history.
+func (rec SessionRecord) compacts() bool {
+	return rec.Dropped > 0 || rec.Summary != nil
+}
+
+// snapshotRecord returns a record that rebuilds s when applied to a new session.
+func snapshotRecord(s *Session) SessionRecord {
+	rec := SessionRecord{
+		Time:        time.Now(),
+		Messages:    append([]llm.ChatMessage(nil), s.History...),
+		Steps:       clonePlanSteps(s.Steps),
+		PlanVersion: s.PlanVersion,
+	}
+	if s.PlanVersion > 0 || s.Plan != "" {
+		rec.Plan = &s.Plan
+	}
+	if s.LastReflection != "" {
+		rec.Reflection = &s.LastReflection
+	}
+	if s.Summary != "" {
+		rec.Summary = &s.Summary
+	}
+	return rec
+}
+
+// apply folds the record into s.
+func (rec SessionRecord) apply(s *Session) {
+	if rec.Dropped > 0 {
+		s.History = append([]llm.ChatMessage(nil), s.History[min(rec.Dropped, len(s.History)):]...)
+	}
+	if rec.Summary != nil {
+		s.Summary = *rec.Summary
+	}
+	s.History = append(s.History, rec.Messages...)
+	if rec.Plan != nil {
+		s.Plan = *rec.Plan
+		s.Steps = rec.Steps
+		s.PlanVersion++
+		if rec.PlanVersion > 0 {
+			s.PlanVersion = rec.PlanVersion
+		}
+	} else if rec.Steps != nil {
+		s.Steps = rec.Steps
+	}
+	if rec.Reflection != nil {
+		s.LastReflection = *rec.Reflection
+	}
+}
+
+// SessionStore keeps sessions across runs and evicts them by idle time and size.
+// Implementations are safe for concurrent use.
+type SessionStore interface {
+	// Open returns the session with id, restoring it when stored and creating it otherwise.
+	// Repeated calls return the same *Session until it is evicted.
+	Open(id string) (*Session, error)
+	// Append persists rec, which the caller has already applied to s. The caller does not
+	// change s until Append returns.
+	Append(s *Session, rec SessionRecord) error
+	// Delete drops a session and its stored records.
+	Delete(id string) error
+}
+
+// SessionLimits bound a SessionStore; zero values disable a limit. The session being
+// opened or written is never evicted by its own call.
+type SessionLimits struct {
+	TTL         time.Duration // idle time after which a session is dropped
+	MaxSessions int           // sessions kept, least recently used evicted first
+	MaxBytes    int64         // total encoded record bytes kept across sessions
+}
+
+// NewSessionStore builds the store configured under agent.sessions.
+func NewSessionStore(cfg config.SessionsConfig) (SessionStore, error) {
+	limits := SessionLimits{TTL: cfg.TTL, MaxSessions: cfg.MaxSessions, MaxBytes: cfg.MaxBytes}
+	switch cfg.Backend {
+	case "", "memory":
+		return NewMemorySessionStore(limits), nil
+	case "disk":
+		return OpenDiskSessionStore(cfg.Dir, limits)
+	default:
+		return nil, fmt.Errorf("unknown session backend %q", cfg.Backend)
+	}
+}
+
+// sessionIndex tracks recency and size per session and decides evictions.
+type sessionIndex struct {
+	limits  SessionLimits
+	entries map[string]*sessionEntry
+	now     func() time.Time
+}
+
+type sessionEntry struct {
+	session *Session // nil until a stored session is loaded
+	used    time.Time
+	bytes   int64
+}
+
+func newSessionIndex(limits SessionLimits) sessionIndex {
+	return sessionIndex{limits: limits, entries: make(map[string]*sessionEntry), now: time.Now}
+}
+
+// touch marks id as used now, adding grown bytes, and creates the entry when missing.
+func (x *sessionIndex) touch(id string, grown int64) *sessionEntry {
+	e, ok := x.entries[id]
+	if !ok {
+		e = &sessionEntry{}
+		x.entries[id] = e
+	}
+	e.used = x.now()
+	e.bytes += grown
+	return e
+}
+
+// reset marks id as used now with size bytes stored, creating the entry when missing.
+func (x *sessionIndex) reset(id string, size int64) *sessionEntry {
+	e := x.touch(id, 0)
+	e.bytes = size
+	return e
+}
+
+// live reports whether s is the session the index holds for its id. It is not once s
+// was evicted (or deleted) while a run still used it.
+func (x *sessionIndex) live(s *Session) bool {
+	e, ok := x.entries[s.ID]
+	return ok && e.session == s
+}
+
+// evict removes expired sessions, then the least recently used ones until the count and
+// byte limits hold. keep is never evicted. The removed ids are returned.
+func (x *sessionIndex) evict(keep string) []string {
+	var removed []string
+	drop := func(id string) {
+		delete(x.entries, id)
+		removed = append(removed, id)
+	}
+
+	if x.limits.TTL > 0 {
+		cutoff := x.now().Add(-x.limits.TTL)
+		for id, e := range x.entries {
+			if id != keep && e.used.Before(cutoff) {
+				drop(id)
+			}
+		}
+	}
+	if x.limits.MaxSessions <= 0 && x.limits.MaxBytes <= 0 {
+		return removed
+	}
+
+	var total int64
+	ids := make([]string, 0, len(x.entries))
+	for id, e := range x.entries {
+		total += e.bytes
+		if id != keep {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := x.entries[ids[i]], x.entries[ids[j]]
+		if !a.used.Equal(b.used) {
+			return a.used.Before(b.used)
+		}
+		return ids[i] < ids[j]
+	})
+	for _, id := range ids {
+		overCount := x.limits.MaxSessions > 0 && len(x.entries) > x.limits.MaxSessions
+		overBytes := x.limits.MaxBytes > 0 && total > x.limits.MaxBytes
+		if !overCount && !overBytes {
+			break
+		}
+		total -= x.entries[id].bytes
+		drop(id)
+	}
+	return removed
+}
+
+// MemorySessionStore keeps sessions in process memory; they are lost on restart.
+type MemorySessionStore struct {
+	mu  sync.Mutex
+	idx sessionIndex
+}
+
+// NewMemorySessionStore creates an empty in-memory store.
+func NewMemorySessionStore(limits SessionLimits) *MemorySessionStore {
+	return &MemorySessionStore{idx: newSessionIndex(limits)}
+}
+
+// Open implements SessionStore.
+func (m *MemorySessionStore) Open(id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.idx.touch(id, 0)
+	if e.session == nil {
+		e.session = newSession(id)
+	}
+	m.idx.evict(id)
+	return e.session, nil
+}
+
+// Append implements SessionStore.
+func (m *MemorySessionStore) Append(s *Session, rec SessionRecord) error {
+	size, err := recordSize(rec)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.idx.live(s) || rec.compacts() {
+		// Evicted while in use, or compacted: count the session as it is now.
+		if size, err = recordSize(snapshotRecord(s)); err != nil {
+			return err
+		}
+		m.idx.reset(s.ID, size).session = s
+	} else {
+		m.idx.touch(s.ID, size)
+	}
+	m.idx.evict(s.ID)
+	return nil
+}
+
+// Delete implements SessionStore.
+func (m *MemorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.idx.entries, id)
+	return nil
+}
+
+// DiskSessionStore keeps one append-only JSON log per session (<dir>/<id>.jsonl) so
+// sessions survive daemon restarts. Loaded sessions are cached in memory.
+type DiskSessionStore struct {
+	dir string
+
+	mu  sync.Mutex
+	idx sessionIndex
+}
+
+const sessionLogExt = ".jsonl"
+
+// OpenDiskSessionStore indexes the session logs in dir, creating it when missing, and
+// removes logs that are already over the limits.
+func OpenDiskSessionStore(dir string, limits SessionLimits) (*DiskSessionStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("session store dir is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create session dir: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read session dir: %w", err)
+	}
+
+	d := &DiskSessionStore{dir: dir, idx: newSessionIndex(limits)}
+	for _, de := range entries {
+		name := de.Name()
+		if de.IsDir() || !strings.HasSuffix(name, sessionLogExt) {
+			continue
+		}
+		id, err := url.PathUnescape(strings.TrimSuffix(name, sessionLogExt))
+		if err != nil {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			return nil, fmt.Errorf("stat session log: %w", err)
+		}
+		d.idx.entries[id] = &sessionEntry{used: info.ModTime(), bytes: info.Size()}
+	}
+	if err := d.removeLogs(d.idx.evict("")); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Open implements SessionStore.
+func (d *DiskSessionStore) Open(id string) (*Session, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e := d.idx.touch(id, 0)
+	if e.session == nil {
+		s, err := d.load(id)
+		if err != nil {
+			return nil, err
+		}
+		e.session = s
+	}
+	return e.session, d.removeLogs(d.idx.evict(id))
+}
+
+// Append implements SessionStore.
+func (d *DiskSessionStore) Append(s *Session, rec SessionRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encode session record: %w", err)
+	}
+	line = append(line, '\n')
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.idx.live(s) || rec.compacts() {
+		// Evicted while in use, the log is gone; compacted, it holds dropped history.
+		// Either way it is replaced by the session as it is now.
+		size, err := d.rewrite(s)
+		if err != nil {
+			return err
+		}
+		d.idx.reset(s.ID, size).session = s
+		return d.removeLogs(d.idx.evict(s.ID))
+	}
+	f, err := os.OpenFile(d.path(s.ID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open session log: %w", err)
+	}
+	_, werr := f.Write(line)
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr != nil {
+		return fmt.Errorf("write session log: %w", werr)
+	}
+	d.idx.touch(s.ID, int64(len(line)))
+	return d.removeLogs(d.idx.evict(s.ID))
+}
+
+// Delete implements SessionStore.
+func (d *DiskSessionStore) Delete(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.idx.entries, id)
+	return d.removeLogs([]string{id})
+}
+
+// load replays the session log; a missing log yields a new session. A partial last line,
+// left by a crash mid-write, is ignored.
+func (d *DiskSessionStore) load(id string) (*Session, error) {
+	s := newSession(id)
+	f, err := os.Open(d.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open session log: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return s, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read session log: %w", err)
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var rec SessionRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("decode session %s record %d: %w", id, n, err)
+		}
+		rec.apply(s)
+	}
+}
+
+// rewrite replaces the session log with a snapshot of s and returns the log size.
+func (d *DiskSessionStore) rewrite(s *Session) (int64, error) {
+	line, err := json.Marshal(snapshotRecord(s))
+	if err != nil {
+		return 0, fmt.Errorf("encode session record: %w", err)
+	}
+	line = append(line, '\n')
+
+	path := d.path(s.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, line, 0o644); err != nil {
+		return 0, fmt.Errorf("write session log: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("replace session log: %w", err)
+	}
+	return int64(len(line)), nil
+}
+
+func (d *DiskSessionStore) removeLogs(ids []string) error {
+	for _, id := range ids {
+		if err := os.Remove(d.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove session log: %w", err)
+		}
+	}
+	return nil
+}
+
+func (d *DiskSessionStore) path(id string) string {
+	return filepath.Join(d.dir, url.PathEscape(id)+sessionLogExt)
+}
+
+func newSession(id string) *Session {
+	return &Session{ID: id, History: make([]llm.ChatMessage, 0, 8)}
+}
+
+func recordSize(rec SessionRecord) (int64, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return 0, fmt.Errorf("encode session record: %w", err)
+	}
+	return int64(len(data)) + 1, nil
+}
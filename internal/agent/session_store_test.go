@@ -0,0 +1,217 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/animus-coder/animus-coder/internal/config"
+	"github.com/animus-coder/animus-coder/internal/llm"
+	llmmock "github.com/animus-coder/animus-coder/internal/llm/mock"
+)
+
+func TestDiskSessionStoreSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "1. read\n2. fix"}}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	open := func() *Agent {
+		a := New(reg, config.AgentConfig{EnablePlan: true})
+		store, err := NewSessionStore(config.SessionsConfig{Backend: "disk", Dir: dir})
+		require.NoError(t, err)
+		a.SetSessionStore(store)
+		return a
+	}
+
+	a := open()
+	_, err := a.Run(context.Background(), Request{SessionID: "team/feature", Prompt: "fix the bug"})
+	require.NoError(t, err)
+	require.NoError(t, a.AppendToolResult("team/feature", "", "tests", "ok"))
+	before := mustSession(t, a, "team/feature")
+
+	restarted := open()
+	after := mustSession(t, restarted, "team/feature")
+	require.Equal(t, "1. read\n2. fix", after.Plan)
+	require.Len(t, after.Steps, 2)
+	require.Equal(t, before.History, after.History)
+	require.Len(t, after.History, 3)
+
+	// A crash mid-write leaves a partial line that is ignored on replay.
+	f, err := os.OpenFile(filepath.Join(dir, "team%2Ffeature.jsonl"), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"time":"2026-01-01T00:00:00Z","messa`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Len(t, mustSession(t, open(), "team/feature").History, 3)
+}
+
+func TestSessionStoreEvictsByTTLAndSize(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	write := func(store SessionStore, id string, rec SessionRecord) {
+		s, err := store.Open(id)
+		require.NoError(t, err)
+		rec.apply(s)
+		require.NoError(t, store.Append(s, rec))
+		now = now.Add(time.Minute)
+	}
+
+	mem := NewMemorySessionStore(SessionLimits{TTL: time.Hour, MaxSessions: 2})
+	mem.idx.now = clock
+	for _, id := range []string{"a", "b"} {
+		write(mem, id, SessionRecord{Messages: []llm.ChatMessage{{Role: llm.RoleUser, Content: id}}})
+	}
+	_, err := mem.Open("c")
+	require.NoError(t, err)
+	require.NotContains(t, mem.idx.entries, "a", "least recently used session is evicted")
+	require.Contains(t, mem.idx.entries, "b")
+
+	now = now.Add(2 * time.Hour)
+	s, err := mem.Open("b")
+	require.NoError(t, err)
+	require.Len(t, s.History, 1, "the session being opened is kept even when idle")
+	require.NotContains(t, mem.idx.entries, "c", "idle sessions expire")
+
+	dir := t.TempDir()
+	disk, err := OpenDiskSessionStore(dir, SessionLimits{MaxBytes: 300})
+	require.NoError(t, err)
+	disk.idx.now = clock
+	big := SessionRecord{Messages: []llm.ChatMessage{{Role: llm.RoleUser, Content: strings.Repeat("x", 200)}}}
+	write(disk, "old", big)
+	write(disk, "new", big)
+	_, err = os.Stat(filepath.Join(dir, "old.jsonl"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(filepath.Join(dir, "new.jsonl"))
+	require.NoError(t, err)
+}
+
+func TestDiskSessionStoreRewritesSessionEvictedInUse(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := OpenDiskSessionStore(dir, SessionLimits{MaxSessions: 1})
+	require.NoError(t, err)
+	record := func(s *Session, rec SessionRecord) {
+		rec.apply(s)
+		require.NoError(t, disk.Append(s, rec))
+	}
+
+	active, err := disk.Open("active")
+	require.NoError(t, err)
+	plan := "1. read\n2. fix"
+	record(active, SessionRecord{Plan: &plan, Steps: ParsePlan(plan)})
+	record(active, SessionRecord{Plan: &plan, Steps: ParsePlan(plan)})
+	record(active, SessionRecord{Messages: []llm.ChatMessage{{Role: llm.RoleUser, Content: "fix it"}}})
+
+	// Another run opens a session while "active" is still in use and evicts it.
+	_, err = disk.Open("other")
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "active.jsonl"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	record(active, SessionRecord{Messages: []llm.ChatMessage{{Role: llm.RoleAssistant, Content: "fixed"}}})
+
+	restarted, err := OpenDiskSessionStore(dir, SessionLimits{})
+	require.NoError(t, err)
+	s, err := restarted.Open("active")
+	require.NoError(t, err)
+	require.Equal(t, active.History, s.History, "the next write stores the whole session, not just its record")
+	require.Equal(t, plan, s.Plan)
+	require.Len(t, s.Steps, 2)
+	require.Equal(t, 2, s.PlanVersion)
+}
+
+func TestSessionStoresShrinkOnCompaction(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := OpenDiskSessionStore(dir, SessionLimits{})
+	require.NoError(t, err)
+	mem := NewMemorySessionStore(SessionLimits{})
+
+	for _, store := range []SessionStore{disk, mem} {
+		s, err := store.Open("long")
+		require.NoError(t, err)
+		for i := 0; i < 4; i++ {
+			rec := SessionRecord{Messages: []llm.ChatMessage{{Role: llm.RoleUser, Content: strings.Repeat("x", 500)}}}
+			rec.apply(s)
+			require.NoError(t, store.Append(s, rec))
+		}
+		summary := "earlier: four long messages"
+		rec := SessionRecord{Dropped: 3, Summary: &summary}
+		rec.apply(s)
+		require.NoError(t, store.Append(s, rec))
+
+		switch store := store.(type) {
+		case *DiskSessionStore:
+			info, err := os.Stat(filepath.Join(dir, "long.jsonl"))
+			require.NoError(t, err)
+			require.Less(t, info.Size(), int64(1000), "the log is rewritten without the dropped messages")
+			require.Equal(t, info.Size(), store.idx.entries["long"].bytes)
+		case *MemorySessionStore:
+			require.Less(t, store.idx.entries["long"].bytes, int64(1000))
+		}
+	}
+
+	s, err := OpenDiskSessionStore(dir, SessionLimits{})
+	require.NoError(t, err)
+	restored, err := s.Open("long")
+	require.NoError(t, err)
+	require.Equal(t, "earlier: four long messages", restored.Summary)
+	require.Len(t, restored.History, 1)
+}
+
+// blockingStore holds Append of one session until release is closed.
+type blockingStore struct {
+	*MemorySessionStore
+	slow    string
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingStore) Append(s *Session, rec SessionRecord) error {
+	if s.ID == b.slow {
+		close(b.entered)
+		<-b.release
+	}
+	return b.MemorySessionStore.Append(s, rec)
+}
+
+func TestSlowSessionWriteDoesNotBlockOtherSessions(t *testing.T) {
+	store := &blockingStore{MemorySessionStore: NewMemorySessionStore(SessionLimits{}), slow: "slow", entered: make(chan struct{}), release: make(chan struct{})}
+	a := New(llm.NewRegistry(), config.AgentConfig{})
+	a.SetSessionStore(store)
+
+	slowDone := make(chan error, 1)
+	go func() { slowDone <- a.AppendToolResult("slow", "", "tests", "ok") }()
+	<-store.entered
+
+	fastDone := make(chan error, 1)
+	go func() {
+		if err := a.AppendToolResult("fast", "", "tests", "ok"); err != nil {
+			fastDone <- err
+			return
+		}
+		s, err := a.ensureSession("slow")
+		if err == nil {
+			a.sessionHistory(s) // readers of the slow session do not wait either
+		}
+		fastDone <- err
+	}()
+	select {
+	case err := <-fastDone:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("a write of another session waited for the slow session's store write")
+	}
+
+	close(store.release)
+	require.NoError(t, <-slowDone)
+	require.Len(t, mustSession(t, a, "slow").History, 1)
+}
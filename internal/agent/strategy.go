@@ -1,6 +1,10 @@
 package agent
 
 import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
 	"strings"
 
 	"github.com/animus-coder/animus-coder/internal/config"
@@ -9,16 +13,66 @@ import (
 
 // StrategyEngine chooses models for different phases.
 type StrategyEngine struct {
-	registry *llm.Registry
-	cfg      config.StrategyConfig
+	registry   *llm.Registry
+	cfg        config.StrategyConfig
+	stats      *RouteStats
+	random     func() float64
+	classifier Classifier
 }
 
-// NewStrategyEngine builds a strategy selector.
+// NewStrategyEngine builds a strategy selector. Routing statistics are kept in memory
+// until SetStats installs persisted ones.
 func NewStrategyEngine(reg *llm.Registry, cfg config.StrategyConfig) *StrategyEngine {
-	return &StrategyEngine{registry: reg, cfg: cfg}
+	s := &StrategyEngine{registry: reg, cfg: cfg, stats: NewRouteStats(cfg.Adaptive.Window), random: rand.Float64}
+	switch cfg.Complexity.Classifier {
+	case "heuristic":
+		s.classifier = HeuristicClassifier{}
+	case "llm":
+		s.classifier = LLMClassifier{Registry: reg, Model: cfg.Complexity.Model, Fallback: HeuristicClassifier{}}
+	}
+	return s
+}
+
+// SetClassifier replaces the task complexity classifier; nil disables classification.
+func (s *StrategyEngine) SetClassifier(c Classifier) {
+	s.classifier = c
+}
+
+// ClassifyTask labels the task and returns the coder model mapped to the label, which is
+// empty when classification is disabled or the label has no route.
+func (s *StrategyEngine) ClassifyTask(ctx context.Context, sig TaskSignals) (Complexity, string, error) {
+	if s == nil || s.classifier == nil {
+		return "", "", nil
+	}
+	label, err := s.classifier.Classify(ctx, sig)
+	if err != nil {
+		return "", "", err
+	}
+	return label, s.cfg.Complexity.Routes[string(label)], nil
+}
+
+// SetStats replaces the routing statistics, e.g. with ones loaded by LoadRouteStats.
+func (s *StrategyEngine) SetStats(stats *RouteStats) {
+	s.stats = stats
+}
+
+// Stats returns the routing statistics fed by the runner; nil for a nil engine.
+func (s *StrategyEngine) Stats() *RouteStats {
+	if s == nil {
+		return nil
+	}
+	return s.stats
+}
+
+// Adaptive reports whether candidates are ranked by routing statistics.
+func (s *StrategyEngine) Adaptive() bool {
+	return s != nil && s.cfg.Mode == "adaptive"
 }
 
 // ResolveModel picks a model id based on role/hint; falls back to default registry resolution.
+// Models whose provider circuit is open are skipped while an available candidate remains.
+// In adaptive mode, without an explicit override, the role model and its fallback chain
+// are ranked by routing statistics first.
 func (s *StrategyEngine) ResolveModel(role string, override string) (llm.Provider, llm.ModelRoute, error) {
 	if s == nil || s.registry == nil {
 		return nil, llm.ModelRoute{}, nil
@@ -30,21 +84,50 @@ func (s *StrategyEngine) ResolveModel(role string, override string) (llm.Provide
 		roleModel(role, s.cfg),
 		s.cfg.DefaultModel,
 	)
-	if modelID != "" {
-		if p, route, err := s.registry.Resolve(modelID); err == nil {
+	candidates := append([]string{modelID}, s.FallbackChain(role)...)
+	if override == "" && s.Adaptive() {
+		candidates = s.rankCandidates(role, candidates)
+	}
+	for _, id := range candidates {
+		if id == "" || !s.registry.Available(id) {
+			continue
+		}
+		if p, route, err := s.registry.Resolve(id); err == nil {
 			return p, route, nil
 		}
 	}
-	for _, fb := range s.cfg.Fallbacks {
-		if p, route, err := s.registry.Resolve(fb); err == nil {
+	if s.registry.Available("") {
+		return s.registry.Resolve("")
+	}
+	// Every candidate is unhealthy: keep the first resolvable one so the call fails fast
+	// (or probes a half-open circuit) instead of failing selection.
+	for _, id := range candidates {
+		if id == "" {
+			continue
+		}
+		if p, route, err := s.registry.Resolve(id); err == nil {
 			return p, route, nil
 		}
 	}
 	return s.registry.Resolve("")
 }
 
-// PickWithBudget chooses a model honoring max_expensive; expensiveUsed is the count so far.
-func (s *StrategyEngine) PickWithBudget(role, override string, expensiveUsed int) (llm.Provider, llm.ModelRoute, string, bool, error) {
+// defaultDowngradeAt is the share of a budget limit after which cheaper routes are preferred.
+const defaultDowngradeAt = 0.8
+
+// Spend is what a run has consumed so far, checked against the strategy budgets.
+type Spend struct {
+	Expensive        int // picks of models marked expensive
+	PromptTokens     int
+	CompletionTokens int
+	CostUSD          float64
+}
+
+// PickWithBudget chooses a model honoring max_expensive and the run budget given what
+// has been spent so far. Once any budget limit is downgrade_at used, the cheapest
+// available route among the choice, the fallbacks and the default is picked instead.
+func (s *StrategyEngine) PickWithBudget(role, override string, spent Spend) (llm.Provider, llm.ModelRoute, string, bool, error) {
+	expensiveUsed := spent.Expensive
 	prov, route, err := s.ResolveModel(role, override)
 	if err != nil {
 		return nil, llm.ModelRoute{}, "", false, err
@@ -55,7 +138,10 @@ func (s *StrategyEngine) PickWithBudget(role, override string, expensiveUsed int
 	chosen := route.Name
 	isExp := s.registry.IsExpensive(chosen)
 	if s.cfg.MaxExpensive > 0 && isExp && expensiveUsed >= s.cfg.MaxExpensive {
-		for _, fb := range s.cfg.Fallbacks {
+		for _, fb := range s.FallbackChain(role) {
+			if !s.registry.Available(fb) {
+				continue
+			}
 			p, r, err := s.registry.Resolve(fb)
 			if err != nil {
 				continue
@@ -68,7 +154,7 @@ func (s *StrategyEngine) PickWithBudget(role, override string, expensiveUsed int
 		}
 	}
 	// If we still have an expensive model and budget exceeded with no fallback, drop to default if available.
-	if s.cfg.MaxExpensive > 0 && isExp && expensiveUsed >= s.cfg.MaxExpensive && s.cfg.DefaultModel != "" {
+	if s.cfg.MaxExpensive > 0 && isExp && expensiveUsed >= s.cfg.MaxExpensive && s.cfg.DefaultModel != "" && s.registry.Available(s.cfg.DefaultModel) {
 		if p, r, err := s.registry.Resolve(s.cfg.DefaultModel); err == nil {
 			chosen = r.Name
 			prov = p
@@ -76,25 +162,129 @@ func (s *StrategyEngine) PickWithBudget(role, override string, expensiveUsed int
 			isExp = s.registry.IsExpensive(chosen)
 		}
 	}
+	if s.budgetPressure(spent) >= s.downgradeAt() {
+		if p, r, ok := s.cheapestRoute(role, route); ok && r.Name != chosen {
+			chosen = r.Name
+			prov = p
+			route = r
+			isExp = s.registry.IsExpensive(chosen)
+		}
+	}
 	return prov, route, chosen, isExp, nil
 }
 
-// NextFallback returns the next fallback model id different from current.
-func (s *StrategyEngine) NextFallback(current string) string {
-	for _, fb := range s.cfg.Fallbacks {
-		if strings.TrimSpace(fb) == "" || fb == current {
+// defaultEscalateAfter is the number of consecutive failing test runs before escalation.
+const defaultEscalateAfter = 2
+
+// Escalation returns the coder escalation model and after how many consecutive failing
+// test runs to switch to it; the model is empty when escalation is disabled.
+func (s *StrategyEngine) Escalation() (string, int) {
+	if s == nil || s.cfg.Escalation.Model == "" {
+		return "", 0
+	}
+	after := s.cfg.Escalation.AfterFailures
+	if after <= 0 {
+		after = defaultEscalateAfter
+	}
+	return s.cfg.Escalation.Model, after
+}
+
+// ExpensiveAllowed reports whether max_expensive leaves room for another expensive pick.
+func (s *StrategyEngine) ExpensiveAllowed(spent Spend) bool {
+	return s == nil || s.cfg.MaxExpensive <= 0 || spent.Expensive < s.cfg.MaxExpensive
+}
+
+// BudgetExhausted reports whether spent has reached a run budget limit and names it.
+func (s *StrategyEngine) BudgetExhausted(spent Spend) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	b := s.cfg.Budget
+	switch {
+	case b.MaxCostUSD > 0 && spent.CostUSD >= b.MaxCostUSD:
+		return "max_cost_usd", true
+	case b.MaxPromptTokens > 0 && spent.PromptTokens >= b.MaxPromptTokens:
+		return "max_prompt_tokens", true
+	case b.MaxCompletionTokens > 0 && spent.CompletionTokens >= b.MaxCompletionTokens:
+		return "max_completion_tokens", true
+	}
+	return "", false
+}
+
+// budgetPressure returns the largest fraction of any budget limit already spent (0 when unlimited).
+func (s *StrategyEngine) budgetPressure(spent Spend) float64 {
+	b := s.cfg.Budget
+	var p float64
+	if b.MaxCostUSD > 0 {
+		p = math.Max(p, spent.CostUSD/b.MaxCostUSD)
+	}
+	if b.MaxPromptTokens > 0 {
+		p = math.Max(p, float64(spent.PromptTokens)/float64(b.MaxPromptTokens))
+	}
+	if b.MaxCompletionTokens > 0 {
+		p = math.Max(p, float64(spent.CompletionTokens)/float64(b.MaxCompletionTokens))
+	}
+	return p
+}
+
+func (s *StrategyEngine) downgradeAt() float64 {
+	if d := s.cfg.Budget.DowngradeAt; d > 0 {
+		return d
+	}
+	return defaultDowngradeAt
+}
+
+// cheapestRoute returns the available route with the lowest token prices among current,
+// the role's fallback chain and the default model; on equal prices non-expensive models
+// win, then the earlier candidate.
+func (s *StrategyEngine) cheapestRoute(role string, current llm.ModelRoute) (llm.Provider, llm.ModelRoute, bool) {
+	var (
+		bestProv  llm.Provider
+		bestRoute llm.ModelRoute
+		found     bool
+	)
+	better := func(a, b llm.ModelRoute) bool {
+		pa, pb := a.InputCostPerMTok+a.OutputCostPerMTok, b.InputCostPerMTok+b.OutputCostPerMTok
+		if pa != pb {
+			return pa < pb
+		}
+		return !s.registry.IsExpensive(a.Name) && s.registry.IsExpensive(b.Name)
+	}
+	candidates := append([]string{current.Name}, s.FallbackChain(role)...)
+	candidates = append(candidates, s.cfg.DefaultModel)
+	for _, id := range candidates {
+		if strings.TrimSpace(id) == "" || !s.registry.Available(id) {
 			continue
 		}
-		return fb
+		p, r, err := s.registry.Resolve(id)
+		if err != nil {
+			continue
+		}
+		if !found || better(r, bestRoute) {
+			bestProv, bestRoute, found = p, r, true
+		}
 	}
-	return ""
+	return bestProv, bestRoute, found
+}
+
+// FallbackChain returns the ordered fallback model ids for a role: its own chain from
+// role_fallbacks when configured, otherwise the global fallbacks.
+func (s *StrategyEngine) FallbackChain(role string) []string {
+	if s == nil {
+		return nil
+	}
+	role = strings.ToLower(strings.TrimSpace(role))
+	if chain, ok := s.cfg.RoleFallbacks[role]; ok {
+		return chain
+	}
+	return s.cfg.Fallbacks
 }
 
 func roleModel(role string, cfg config.StrategyConfig) string {
 	switch role {
-	case "planner":
+	case RolePlanner:
 		return cfg.PlannerModel
-	case "critic", "reflect":
+	case RoleCritic, "reflect":
 		return cfg.CriticModel
 	default:
 		return cfg.CoderModel
@@ -109,3 +299,65 @@ func firstNonEmpty(vals ...string) string {
 	}
 	return ""
 }
+
+// RouteRank is a candidate's routing statistics and adaptive score.
+type RouteRank struct {
+	RouteSummary
+	Score float64 `json:"score"`
+}
+
+// RoutingSnapshot lists the ranked candidates of each role from the tracked statistics.
+func (s *StrategyEngine) RoutingSnapshot() map[string][]RouteRank {
+	out := make(map[string][]RouteRank)
+	if s == nil {
+		return out
+	}
+	for _, sum := range s.stats.Summaries() {
+		out[sum.Role] = append(out[sum.Role], RouteRank{RouteSummary: sum, Score: s.score(sum)})
+	}
+	for _, ranks := range out {
+		sort.SliceStable(ranks, func(i, j int) bool { return ranks[i].Score > ranks[j].Score })
+	}
+	return out
+}
+
+// rankCandidates orders distinct candidates by adaptive score, keeping config order on
+// ties. With probability exploration a random candidate is moved to the front instead so
+// that models with stale or missing statistics keep getting sampled.
+func (s *StrategyEngine) rankCandidates(role string, candidates []string) []string {
+	var ids []string
+	seen := make(map[string]struct{}, len(candidates))
+	for _, id := range candidates {
+		if _, dup := seen[id]; dup || strings.TrimSpace(id) == "" {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	if len(ids) < 2 {
+		return ids
+	}
+	if s.cfg.Adaptive.Exploration > 0 && s.random() < s.cfg.Adaptive.Exploration {
+		i := int(s.random() * float64(len(ids)))
+		i = min(i, len(ids)-1)
+		return append([]string{ids[i]}, append(ids[:i:i], ids[i+1:]...)...)
+	}
+	scores := make(map[string]float64, len(ids))
+	for _, id := range ids {
+		scores[id] = s.score(s.stats.Summary(role, id))
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return scores[ids[i]] > scores[ids[j]] })
+	return ids
+}
+
+func (s *StrategyEngine) score(sum RouteSummary) float64 {
+	minSamples := s.cfg.Adaptive.MinSamples
+	if minSamples <= 0 {
+		minSamples = defaultMinSamples
+	}
+	target := s.cfg.Adaptive.LatencyTarget
+	if target <= 0 {
+		target = defaultLatencyTarget
+	}
+	return routeScore(sum, minSamples, target)
+}
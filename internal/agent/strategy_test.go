@@ -1,7 +1,12 @@
 package agent
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/animus-coder/animus-coder/internal/config"
 	"github.com/animus-coder/animus-coder/internal/llm"
@@ -49,7 +54,7 @@ func TestStrategyFallsBackWhenBudgetExceeded(t *testing.T) {
 		DefaultModel: "cheap-model",
 	})
 
-	_, _, chosen, isExp, err := engine.PickWithBudget("coder", "", 1)
+	_, _, chosen, isExp, err := engine.PickWithBudget("coder", "", Spend{Expensive: 1})
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -60,3 +65,288 @@ func TestStrategyFallsBackWhenBudgetExceeded(t *testing.T) {
 		t.Fatalf("expected fallback not expensive")
 	}
 }
+
+func TestStrategySkipsOpenCircuits(t *testing.T) {
+	reg := llm.NewRegistry()
+	down := &llmmock.Provider{ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+		return llm.ChatResponse{}, &llm.StatusError{Provider: "down", StatusCode: http.StatusServiceUnavailable}
+	}}
+	reg.RegisterProvider("down", down)
+	reg.RegisterProvider("up", &llmmock.Provider{})
+	reg.RegisterModel("primary", llm.ModelRoute{Provider: "down", Model: "m1"}, true)
+	reg.RegisterModel("backup", llm.ModelRoute{Provider: "up", Model: "m2"}, false)
+	if err := reg.SetCircuitBreaker("down", llm.BreakerPolicy{FailureThreshold: 1, Cooldown: time.Hour}, nil); err != nil {
+		t.Fatalf("set breaker: %v", err)
+	}
+
+	engine := NewStrategyEngine(reg, config.StrategyConfig{
+		CoderModel: "primary",
+		Fallbacks:  []string{"backup"},
+	})
+
+	p, route, err := engine.ResolveModel("coder", "")
+	if err != nil || route.Name != "primary" {
+		t.Fatalf("expected primary while circuit closed, got %s err=%v", route.Name, err)
+	}
+	_, _ = p.Chat(context.Background(), llm.ChatRequest{})
+
+	_, route, err = engine.ResolveModel("coder", "")
+	if err != nil || route.Name != "backup" {
+		t.Fatalf("expected backup while primary circuit open, got %s err=%v", route.Name, err)
+	}
+	_, _, chosen, _, err := engine.PickWithBudget("coder", "primary", Spend{})
+	if err != nil || chosen != "backup" {
+		t.Fatalf("expected budget pick to skip open circuit, got %s err=%v", chosen, err)
+	}
+}
+
+func TestStrategyDowngradesAsBudgetRunsDown(t *testing.T) {
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("p", &llmmock.Provider{})
+	reg.RegisterModel("premium", llm.ModelRoute{Provider: "p", Model: "m1", InputCostPerMTok: 3, OutputCostPerMTok: 15}, true)
+	reg.RegisterModel("mid", llm.ModelRoute{Provider: "p", Model: "m2", InputCostPerMTok: 1, OutputCostPerMTok: 4}, false)
+	reg.RegisterModel("budget", llm.ModelRoute{Provider: "p", Model: "m3", InputCostPerMTok: 0.1, OutputCostPerMTok: 0.4}, false)
+
+	engine := NewStrategyEngine(reg, config.StrategyConfig{
+		CoderModel: "premium",
+		Fallbacks:  []string{"mid", "budget"},
+		Budget:     config.BudgetConfig{MaxCostUSD: 1, MaxCompletionTokens: 10000},
+	})
+
+	_, _, chosen, _, err := engine.PickWithBudget("coder", "", Spend{CostUSD: 0.5, CompletionTokens: 1000})
+	if err != nil || chosen != "premium" {
+		t.Fatalf("expected premium with budget to spare, got %s err=%v", chosen, err)
+	}
+	_, _, chosen, _, err = engine.PickWithBudget("coder", "", Spend{CostUSD: 0.1, CompletionTokens: 8500})
+	if err != nil || chosen != "budget" {
+		t.Fatalf("expected cheapest route under budget pressure, got %s err=%v", chosen, err)
+	}
+
+	if limit, ok := engine.BudgetExhausted(Spend{CostUSD: 0.99}); ok {
+		t.Fatalf("budget should not be exhausted yet, got %s", limit)
+	}
+	if limit, ok := engine.BudgetExhausted(Spend{CostUSD: 1.2}); !ok || limit != "max_cost_usd" {
+		t.Fatalf("expected max_cost_usd exhausted, got %q ok=%v", limit, ok)
+	}
+	if limit, ok := engine.BudgetExhausted(Spend{CompletionTokens: 10000}); !ok || limit != "max_completion_tokens" {
+		t.Fatalf("expected max_completion_tokens exhausted, got %q ok=%v", limit, ok)
+	}
+}
+
+func TestStrategyUsesRoleFallbackChains(t *testing.T) {
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("p", &llmmock.Provider{})
+	reg.RegisterModel("big-coder", llm.ModelRoute{Provider: "p", Model: "m1"}, true)
+	reg.RegisterModel("small-critic", llm.ModelRoute{Provider: "p", Model: "m2"}, false)
+
+	engine := NewStrategyEngine(reg, config.StrategyConfig{
+		Fallbacks:     []string{"big-coder"},
+		RoleFallbacks: map[string][]string{"critic": {"small-critic"}},
+	})
+
+	if chain := engine.FallbackChain("Critic"); len(chain) != 1 || chain[0] != "small-critic" {
+		t.Fatalf("expected critic chain, got %v", chain)
+	}
+	if chain := engine.FallbackChain("planner"); len(chain) != 1 || chain[0] != "big-coder" {
+		t.Fatalf("expected global fallbacks for planner, got %v", chain)
+	}
+	_, route, err := engine.ResolveModel("critic", "missing-model")
+	if err != nil || route.Name != "small-critic" {
+		t.Fatalf("expected critic to resolve through its own chain, got %s err=%v", route.Name, err)
+	}
+}
+
+func TestStrategyAdaptiveRanksByObservedStats(t *testing.T) {
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("p", &llmmock.Provider{})
+	reg.RegisterModel("flaky", llm.ModelRoute{Provider: "p", Model: "m1"}, true)
+	reg.RegisterModel("slow", llm.ModelRoute{Provider: "p", Model: "m2"}, false)
+	reg.RegisterModel("steady", llm.ModelRoute{Provider: "p", Model: "m3"}, false)
+
+	cfg := config.StrategyConfig{
+		Mode:       "adaptive",
+		CoderModel: "flaky",
+		Fallbacks:  []string{"slow", "steady"},
+		Adaptive:   config.AdaptiveConfig{MinSamples: 2, LatencyTarget: 10 * time.Second},
+	}
+	engine := NewStrategyEngine(reg, cfg)
+
+	// Without enough samples the configured order stands.
+	_, route, err := engine.ResolveModel("coder", "")
+	if err != nil || route.Name != "flaky" {
+		t.Fatalf("expected configured model before stats exist, got %s err=%v", route.Name, err)
+	}
+
+	stats := engine.Stats()
+	for i := 0; i < 4; i++ {
+		var callErr error
+		if i%2 == 0 {
+			callErr = errors.New("boom")
+		}
+		stats.RecordCall("coder", "flaky", time.Second, callErr)
+		stats.RecordCall("coder", "slow", 20*time.Second, nil)
+		stats.RecordCall("coder", "steady", 2*time.Second, nil)
+		stats.RecordTestResult("steady", i != 0)
+	}
+	if sum := stats.Summary("coder", "flaky"); sum.ErrorRate != 0.5 || sum.P95LatencyMS != 1000 {
+		t.Fatalf("unexpected flaky summary: %+v", sum)
+	}
+
+	_, route, err = engine.ResolveModel("coder", "")
+	if err != nil || route.Name != "steady" {
+		t.Fatalf("expected best-scoring model, got %s err=%v", route.Name, err)
+	}
+	_, route, err = engine.ResolveModel("coder", "flaky")
+	if err != nil || route.Name != "flaky" {
+		t.Fatalf("explicit override must not be re-ranked, got %s err=%v", route.Name, err)
+	}
+
+	cfg.Adaptive.Exploration = 0.5
+	explorer := NewStrategyEngine(reg, cfg)
+	explorer.SetStats(stats)
+	rolls := []float64{0.1, 0.5} // explore, then pick the middle candidate
+	explorer.random = func() float64 {
+		v := rolls[0]
+		rolls = rolls[1:]
+		return v
+	}
+	_, route, err = explorer.ResolveModel("coder", "")
+	if err != nil || route.Name != "slow" {
+		t.Fatalf("expected exploration to pick slow, got %s err=%v", route.Name, err)
+	}
+
+	ranks := engine.RoutingSnapshot()["coder"]
+	if len(ranks) != 3 || ranks[0].Model != "steady" || ranks[0].TestPassRate != 0.75 {
+		t.Fatalf("unexpected routing snapshot: %+v", ranks)
+	}
+}
+
+func TestRouteStatsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "routing.json")
+	stats, err := LoadRouteStats(path, 3)
+	if err != nil {
+		t.Fatalf("load missing stats: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		stats.RecordCall("critic", "judge", time.Duration(i)*time.Second, nil)
+	}
+	stats.RecordCritique("judge", true)
+	if err := stats.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	reloaded, err := LoadRouteStats(path, 3)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	sum := reloaded.Summary("critic", "judge")
+	if sum.Calls != 3 || sum.P95LatencyMS != 4000 || sum.Critiques != 1 || sum.BlockRate != 1 {
+		t.Fatalf("unexpected reloaded summary: %+v", sum)
+	}
+}
+
+func TestHeuristicClassifierLabels(t *testing.T) {
+	cases := []struct {
+		name string
+		sig  TaskSignals
+		want Complexity
+	}{
+		{"typo", TaskSignals{Prompt: "fix the typo in the README", PlanSteps: 1}, ComplexityTrivial},
+		{"feature", TaskSignals{Prompt: "add a --json flag to the status command", PlanSteps: 4}, ComplexityStandard},
+		{"refactor", TaskSignals{Prompt: "refactor the session store across packages", PlanSteps: 11, ContextFiles: 9}, ComplexityHard},
+	}
+	for _, tc := range cases {
+		got, err := HeuristicClassifier{}.Classify(context.Background(), tc.sig)
+		if err != nil {
+			t.Fatalf("%s: classify: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: expected %s, got %s", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestHeuristicClassifierMatchesHintsOnWordBoundaries(t *testing.T) {
+	cases := []struct {
+		prompt        string
+		trivial, hard bool
+	}{
+		{"show the stack trace in the information panel", false, false},
+		{"embrace the bracket syntax", false, false},
+		{"uncomment the recommended settings", false, false},
+		{"fix the race in the log message writer", true, true},
+		{"Refactoring: migrating the concurrency code", false, true},
+		{"Fix typos and rename the flag", true, false},
+	}
+	for _, tc := range cases {
+		words := lowerWords(tc.prompt)
+		if got := hasHint(words, trivialHints); got != tc.trivial {
+			t.Fatalf("%q: trivial hint = %v, want %v", tc.prompt, got, tc.trivial)
+		}
+		if got := hasHint(words, hardHints); got != tc.hard {
+			t.Fatalf("%q: hard hint = %v, want %v", tc.prompt, got, tc.hard)
+		}
+	}
+}
+
+func TestLLMClassifierParsesAnswerAndFallsBack(t *testing.T) {
+	reg := llm.NewRegistry()
+	answer := "Hard."
+	reg.RegisterProvider("p", &llmmock.Provider{ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+		if req.Role != RoleClassifier {
+			t.Fatalf("classifier request has role %q", req.Role)
+		}
+		if answer == "" {
+			return llm.ChatResponse{}, errors.New("boom")
+		}
+		return llm.ChatResponse{
+			Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: answer},
+			Usage:   llm.Usage{PromptTokens: 40, CompletionTokens: 1},
+		}, nil
+	}})
+	reg.RegisterModel("judge", llm.ModelRoute{Provider: "p", Model: "judge"}, true)
+
+	ledger := NewUsageLedger()
+	c := LLMClassifier{Registry: reg, Model: "judge", Fallback: HeuristicClassifier{}}
+	got, err := c.Classify(context.Background(), TaskSignals{Prompt: "fix the typo", Usage: ledger})
+	if err != nil || got != ComplexityHard {
+		t.Fatalf("expected hard from the model, got %s (%v)", got, err)
+	}
+	if entries := ledger.Entries(); len(entries) != 1 || entries[0].Role != RoleClassifier {
+		t.Fatalf("expected classifier usage to be recorded, got %+v", entries)
+	}
+
+	answer = ""
+	got, err = c.Classify(context.Background(), TaskSignals{Prompt: "fix the typo"})
+	if err != nil || got != ComplexityTrivial {
+		t.Fatalf("expected heuristic fallback to answer trivial, got %s (%v)", got, err)
+	}
+
+	if _, ok := ParseComplexity("non-trivial"); ok {
+		t.Fatalf("non-trivial should not parse as a label")
+	}
+}
+
+func TestStrategyClassifyTaskMapsRoutes(t *testing.T) {
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("p", &llmmock.Provider{})
+	reg.RegisterModel("local-coder", llm.ModelRoute{Provider: "p", Model: "small"}, true)
+
+	s := NewStrategyEngine(reg, config.StrategyConfig{})
+	if label, model, err := s.ClassifyTask(context.Background(), TaskSignals{Prompt: "fix typo"}); label != "" || model != "" || err != nil {
+		t.Fatalf("expected classification to be disabled, got %q %q %v", label, model, err)
+	}
+
+	s = NewStrategyEngine(reg, config.StrategyConfig{Complexity: config.ComplexityConfig{
+		Classifier: "heuristic",
+		Routes:     map[string]string{"trivial": "local-coder"},
+	}})
+	label, model, err := s.ClassifyTask(context.Background(), TaskSignals{Prompt: "fix typo"})
+	if err != nil || label != ComplexityTrivial || model != "local-coder" {
+		t.Fatalf("expected trivial -> local-coder, got %q %q %v", label, model, err)
+	}
+	label, model, _ = s.ClassifyTask(context.Background(), TaskSignals{Prompt: "add a flag to the status command", PlanSteps: 4})
+	if label != ComplexityStandard || model != "" {
+		t.Fatalf("expected standard without a route, got %q %q", label, model)
+	}
+}
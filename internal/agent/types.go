@@ -14,6 +14,17 @@ type Request struct {
 	Model     string
 	Prompt    string
 	Context   []ContextFile
+	// Instructions are workspace instruction files (AGENTS.md and the like) merged into
+	// the system prompt, outermost directory first.
+	Instructions []ContextFile
+	// Tools are offered to the model for native function calling.
+	Tools []llm.ToolDefinition
+	// Continue resumes the session conversation (tool results, reflections)
+	// instead of sending Prompt again as a new user turn.
+	Continue bool
+	// Usage, when set, accumulates the token usage and cost of every model call
+	// made for this request, including planning and reflection.
+	Usage *UsageLedger
 }
 
 // Response wraps the model response and route metadata.
@@ -21,6 +32,7 @@ type Response struct {
 	Message           llm.ChatMessage
 	Route             llm.ModelRoute
 	FinishReason      string
+	Usage             llm.Usage
 	PreviousAssistant string
 }
 
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
+)
+
+// Roles under which model usage is accounted.
+const (
+	RolePlanner = llm.AgentRolePlanner
+	RoleCoder   = llm.AgentRoleCoder
+	RoleCritic  = llm.AgentRoleCritic
+	// RoleClassifier accounts the optional model-judged complexity classification.
+	RoleClassifier = llm.AgentRoleClassifier
+	// RoleSummarizer accounts history compaction.
+	RoleSummarizer = llm.AgentRoleSummarizer
+)
+
+// UsageEntry is the accumulated usage of one model in one role.
+type UsageEntry struct {
+	Role    string
+	Model   string
+	Calls   int
+	Usage   llm.Usage
+	CostUSD float64
+}
+
+// UsageLedger sums provider-reported token usage and cost per role and model
+// across the calls of a run. A nil ledger ignores all records.
+type UsageLedger struct {
+	mu      sync.Mutex
+	entries map[[2]string]*UsageEntry
+}
+
+// NewUsageLedger creates an empty ledger.
+func NewUsageLedger() *UsageLedger {
+	return &UsageLedger{entries: make(map[[2]string]*UsageEntry)}
+}
+
+// Add records one call's usage; cost is derived from the route's prices.
+func (l *UsageLedger) Add(role string, route llm.ModelRoute, usage llm.Usage) {
+	if l == nil {
+		return
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	model := firstNonEmpty(route.Name, route.Model)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := [2]string{role, model}
+	e, ok := l.entries[key]
+	if !ok {
+		e = &UsageEntry{Role: role, Model: model}
+		l.entries[key] = e
+	}
+	e.Calls++
+	e.Usage.PromptTokens += usage.PromptTokens
+	e.Usage.CompletionTokens += usage.CompletionTokens
+	e.Usage.TotalTokens += usage.TotalTokens
+	e.CostUSD += route.Cost(usage)
+}
+
+// Entries returns the per-role/model totals sorted by role, then model.
+func (l *UsageLedger) Entries() []UsageEntry {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]UsageEntry, 0, len(l.entries))
+	for _, e := range l.entries {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Role != out[j].Role {
+			return out[i].Role < out[j].Role
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+// Total returns the usage and cost summed over all entries.
+func (l *UsageLedger) Total() (llm.Usage, float64) {
+	var (
+		total llm.Usage
+		cost  float64
+	)
+	for _, e := range l.Entries() {
+		total.PromptTokens += e.Usage.PromptTokens
+		total.CompletionTokens += e.Usage.CompletionTokens
+		total.TotalTokens += e.Usage.TotalTokens
+		cost += e.CostUSD
+	}
+	return total, cost
+}
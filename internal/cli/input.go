@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/animus-coder/animus-coder/internal/rpc"
+)
+
+const approvalHint = `answer "y", "n [reason]" or "edit {json args}"`
+
+// stdinRouter turns stdin lines into messages for the run: a decision while an approval
+// request is open, else user input when the run is interactive.
+type stdinRouter struct {
+	req rpc.RunTaskRequest
+
+	mu         sync.Mutex
+	approvalID string // open approval request, if any
+}
+
+// setApproval records the open approval request; "" clears it.
+func (s *stdinRouter) setApproval(id string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.approvalID = id
+}
+
+// route reads lines from in until EOF and hands each non-empty one to decide or input;
+// errors are reported to errOut and the line is skipped. send errors stop the loop.
+func (s *stdinRouter) route(in io.Reader, errOut io.Writer, decide func(rpc.ApprovalDecision) error, input func(rpc.UserInput) error) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		s.mu.Lock()
+		id := s.approvalID
+		s.mu.Unlock()
+
+		var err error
+		switch {
+		case id != "":
+			var d rpc.ApprovalDecision
+			if d, err = parseApprovalDecision(line); err != nil {
+				fmt.Fprintf(errOut, "%v; %s\n", err, approvalHint)
+				continue
+			}
+			d.SessionID, d.CorrelationID, d.ApprovalID = s.req.SessionID, s.req.CorrelationID, id
+			s.setApproval("")
+			err = decide(d)
+		case s.req.Interactive:
+			err = input(rpc.UserInput{SessionID: s.req.SessionID, Text: line})
+		default:
+			fmt.Fprintln(errOut, "no approval request is open; run with --interactive to send input")
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(errOut, "send: %v\n", err)
+			return
+		}
+	}
+}
+
+// parseApprovalDecision reads "y", "n [reason]" or "edit {json args}".
+func parseApprovalDecision(line string) (rpc.ApprovalDecision, error) {
+	word, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	switch strings.ToLower(word) {
+	case "y", "yes", "approve":
+		return rpc.ApprovalDecision{Decision: "approve"}, nil
+	case "n", "no", "deny":
+		return rpc.ApprovalDecision{Decision: "deny", Reason: rest}, nil
+	case "edit":
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(rest), &args); err != nil || len(args) == 0 {
+			return rpc.ApprovalDecision{}, errors.New("edit needs the tool arguments as a JSON object")
+		}
+		return rpc.ApprovalDecision{Decision: "edit", Args: args}, nil
+	default:
+		return rpc.ApprovalDecision{}, fmt.Errorf("unknown answer %q", word)
+	}
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/animus-coder/animus-coder/internal/rpc"
+)
+
+func TestStdinRouterAnswersApprovalsBeforeInput(t *testing.T) {
+	var decisions []rpc.ApprovalDecision
+	var inputs []rpc.UserInput
+	decide := func(d rpc.ApprovalDecision) error { decisions = append(decisions, d); return nil }
+	input := func(in rpc.UserInput) error { inputs = append(inputs, in); return nil }
+
+	errOut := &bytes.Buffer{}
+	router := &stdinRouter{req: rpc.RunTaskRequest{SessionID: "s", CorrelationID: "c"}}
+	router.setApproval("call_1")
+	router.route(strings.NewReader("maybe\n\nn too risky\nhello\n"), errOut, decide, input)
+
+	require.Equal(t, []rpc.ApprovalDecision{{SessionID: "s", CorrelationID: "c", ApprovalID: "call_1", Decision: "deny", Reason: "too risky"}}, decisions)
+	require.Empty(t, inputs, "non-interactive runs take no input")
+	require.Contains(t, errOut.String(), `unknown answer "maybe"`)
+	require.Contains(t, errOut.String(), "run with --interactive")
+
+	router = &stdinRouter{req: rpc.RunTaskRequest{SessionID: "s", CorrelationID: "c", Interactive: true}}
+	router.setApproval("call_2")
+	router.route(strings.NewReader("edit {\"path\":\"docs/a.md\"}\nuse docs instead\n"), errOut, decide, input)
+	require.Len(t, decisions, 2)
+	require.Equal(t, "edit", decisions[1].Decision)
+	require.Equal(t, map[string]interface{}{"path": "docs/a.md"}, decisions[1].Args)
+	require.Equal(t, []rpc.UserInput{{SessionID: "s", Text: "use docs instead"}}, inputs)
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/animus-coder/animus-coder/internal/agent"
+	"github.com/animus-coder/animus-coder/internal/config"
+	agentrpc "github.com/animus-coder/animus-coder/internal/rpc/agent"
+	"github.com/animus-coder/animus-coder/internal/tools"
+)
+
+// NewPromptsCmd groups prompt template commands.
+func NewPromptsCmd(opts *Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prompts",
+		Short: "Inspect system prompt templates",
+	}
+	cmd.AddCommand(newPromptsRenderCmd(opts))
+	return cmd
+}
+
+func newPromptsRenderCmd(opts *Options) *cobra.Command {
+	var roles []string
+	var plan string
+	var contextPaths []string
+
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render the final system prompts for this workspace",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(opts)
+			if err != nil {
+				return err
+			}
+			if len(roles) == 0 {
+				roles = config.PromptRoles
+			}
+			for _, role := range roles {
+				if !slices.Contains(config.PromptRoles, role) {
+					return fmt.Errorf("unknown role %q (want one of %s)", role, strings.Join(config.PromptRoles, ", "))
+				}
+			}
+
+			templates, err := agent.LoadPromptTemplates(cfg.Sandbox.WorkingDir, cfg.Agent.Prompts)
+			if err != nil {
+				return err
+			}
+			toolRegistry, err := tools.NewRegistryFromConfig(cfg)
+			if err != nil {
+				return err
+			}
+			env, err := agentrpc.NewPromptEnv(cfg, toolRegistry)
+			if err != nil {
+				return fmt.Errorf("describe workspace: %w", err)
+			}
+			instructions, err := agentrpc.DiscoverInstructions(toolRegistry, "", contextPaths, cfg.Agent.Instructions)
+			if err != nil {
+				return err
+			}
+			data := agent.PromptData{PromptEnv: env, Instructions: instructions}
+			if steps := agent.ParsePlan(plan); len(steps) > 0 {
+				data.Plan, data.Steps = agent.RenderPlan(steps), steps
+			} else {
+				data.Plan = strings.TrimSpace(plan)
+			}
+
+			out := cmd.OutOrStdout()
+			for i, role := range roles {
+				text, err := templates.Render(role, data)
+				if err != nil {
+					return err
+				}
+				if i > 0 {
+					fmt.Fprintln(out)
+				}
+				fmt.Fprintf(out, "=== %s (%s) ===\n%s\n", role, templates.Source(role), text)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&roles, "role", nil, "Roles to render: "+strings.Join(config.PromptRoles, ", ")+" (default: all)")
+	cmd.Flags().StringVar(&plan, "plan", "", "Session plan to render the templates with")
+	cmd.Flags().StringSliceVar(&contextPaths, "context", nil, "Paths a run would touch; instruction files on the way to them are included")
+	return cmd
+}
@@ -32,6 +32,7 @@ func NewRootCmd() *cobra.Command {
 	cmd.AddCommand(NewDoctorCmd(opts))
 	cmd.AddCommand(NewVersionCmd())
 	cmd.AddCommand(NewRunCmd(opts))
+	cmd.AddCommand(NewPromptsCmd(opts))
 
 	return cmd
 }
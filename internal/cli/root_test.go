@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bytes"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -35,3 +36,43 @@ func TestDoctorWithExampleConfig(t *testing.T) {
 	require.NoError(t, err)
 	require.Contains(t, buf.String(), "Config OK")
 }
+
+func TestPromptsRenderUsesWorkspaceOverrides(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(ws, ".mycodex", "prompts"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, ".mycodex", "prompts", "planner.tmpl"),
+		[]byte("You are MyCodex planning assistant in {{.Workspace}}.\n{{.Structure}}\n{{.Plan}}"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "main.go"), []byte("package main\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(ws, "pkg"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "pkg", "AGENTS.md"), []byte("Keep pkg dependency-free."), 0o644))
+	configPath := filepath.Join(ws, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+providers:
+  local:
+    type: ollama
+    base_url: http://localhost:11434
+models:
+  main:
+    provider: local
+    model: qwen
+    default: true
+sandbox:
+  working_dir: `+ws+`
+`), 0o644))
+
+	cmd := NewRootCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"prompts", "render", "--config", configPath, "--role", "planner,critic", "--plan", "1. read\n2. fix", "--context", "pkg"})
+
+	require.NoError(t, cmd.Execute())
+	planner := filepath.Join(ws, ".mycodex", "prompts", "planner.tmpl")
+	require.Contains(t, buf.String(), "=== planner ("+planner+") ===\nYou are MyCodex planning assistant in "+ws+".\n./\n")
+	require.Contains(t, buf.String(), "- main.go\n- pkg/\n  - AGENTS.md\n1. read\n2. fix\n")
+	require.Contains(t, buf.String(), "=== critic (built-in) ===\nYou are MyCodex reflection assistant.")
+	require.Contains(t, buf.String(), "From pkg/AGENTS.md:\nKeep pkg dependency-free.\n")
+
+	cmd.SetArgs([]string{"prompts", "render", "--config", configPath, "--role", "bogus"})
+	require.ErrorContains(t, cmd.Execute(), `unknown role "bogus"`)
+}
@@ -12,6 +12,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bufbuild/connect-go"
@@ -30,6 +31,8 @@ func NewRunCmd(opts *Options) *cobra.Command {
 	var modelOverride string
 	var plannerModel string
 	var criticModel string
+	var sessionID string
+	var interactive bool
 
 	cmd := &cobra.Command{
 		Use:   "run \"<prompt>\"",
@@ -49,7 +52,9 @@ func NewRunCmd(opts *Options) *cobra.Command {
 			ctx, cancel := context.WithCancel(cmd.Context())
 			defer cancel()
 
-			sessionID := fmt.Sprintf("cli-%d", time.Now().UnixNano())
+			if sessionID == "" {
+				sessionID = fmt.Sprintf("cli-%d", time.Now().UnixNano())
+			}
 			corrID := fmt.Sprintf("%s-%d", sessionID, time.Now().UnixNano())
 
 			reqBody := rpc.RunTaskRequest{
@@ -61,12 +66,16 @@ func NewRunCmd(opts *Options) *cobra.Command {
 				ContextPaths:  contextPaths,
 				PlannerModel:  plannerModel,
 				CriticModel:   criticModel,
+				Interactive:   interactive,
 			}
 
 			baseURL := daemonURL(cfg.Server.Addr)
 			switch strings.ToLower(strings.TrimSpace(cfg.Server.Transport)) {
 			case "ndjson":
-				return runNDJSON(ctx, cmd, baseURL+"/agent/run", reqBody)
+				if interactive {
+					return fmt.Errorf("--interactive requires the connect transport")
+				}
+				return runNDJSON(ctx, cmd, baseURL, reqBody)
 			default:
 				return runConnect(ctx, cmd, baseURL+agentrpc.ConnectRunTaskProcedure, reqBody)
 			}
@@ -78,6 +87,8 @@ func NewRunCmd(opts *Options) *cobra.Command {
 	cmd.Flags().StringVar(&modelOverride, "model", "", "Override coder model id for this run")
 	cmd.Flags().StringVar(&plannerModel, "planner-model", "", "Override planner model id for this run")
 	cmd.Flags().StringVar(&criticModel, "critic-model", "", "Override critic model id for this run")
+	cmd.Flags().StringVar(&sessionID, "session", "", "Session id to create or resume (default: a new session)")
+	cmd.Flags().BoolVar(&interactive, "interactive", false, "Send stdin lines that do not answer an approval request to the run as input")
 	return cmd
 }
 
@@ -102,13 +113,20 @@ func parseToolCalls(raw string) []rpc.ToolCall {
 	return calls
 }
 
-func runNDJSON(ctx context.Context, cmd *cobra.Command, url string, reqBody rpc.RunTaskRequest) error {
+// runNDJSON streams the run from /agent/run; stdin lines answering approval requests are
+// posted to /agent/approve.
+func runNDJSON(ctx context.Context, cmd *cobra.Command, baseURL string, reqBody rpc.RunTaskRequest) error {
 	data, err := json.Marshal(reqBody)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	router := &stdinRouter{req: reqBody}
+	go router.route(cmd.InOrStdin(), cmd.ErrOrStderr(), func(d rpc.ApprovalDecision) error {
+		return postApproval(ctx, baseURL+"/agent/approve", d)
+	}, nil)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/agent/run", bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
@@ -124,34 +142,77 @@ func runNDJSON(ctx context.Context, cmd *cobra.Command, url string, reqBody rpc.
 		return fmt.Errorf("daemon returned status %d", resp.StatusCode)
 	}
 
+	renderer := &eventRenderer{out: cmd.OutOrStdout(), approvals: router}
 	scanner := bufio.NewScanner(resp.Body)
 	for scanner.Scan() {
 		var evt rpc.RunTaskEvent
 		if err := json.Unmarshal(scanner.Bytes(), &evt); err != nil {
 			return fmt.Errorf("decode event: %w", err)
 		}
-		if err := renderEvent(cmd, evt); err != nil {
+		if err := renderer.render(evt); err != nil {
 			return err
 		}
 	}
 	return scanner.Err()
 }
 
+func postApproval(ctx context.Context, url string, d rpc.ApprovalDecision) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("daemon returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// runConnect streams the run over the Connect bidi stream. Stdin lines answer open
+// approval requests and, for interactive runs, are otherwise sent as user input.
 func runConnect(ctx context.Context, cmd *cobra.Command, url string, reqBody rpc.RunTaskRequest) error {
 	client := connect.NewClient[rpc.RunTaskStreamRequest, rpc.RunTaskEvent](buildH2CClient(), url, connect.WithCodec(connectjson.Codec{}))
 	stream := client.CallBidiStream(ctx)
 
-	if err := stream.Send(&rpc.RunTaskStreamRequest{Run: &reqBody}); err != nil {
+	// Sends come from the stdin router and the cancel goroutine.
+	var sendMu sync.Mutex
+	send := func(msg *rpc.RunTaskStreamRequest) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return stream.Send(msg)
+	}
+
+	if err := send(&rpc.RunTaskStreamRequest{Run: &reqBody}); err != nil {
 		return err
 	}
 
 	// propagate cancellation to the daemon.
 	go func() {
 		<-ctx.Done()
-		_ = stream.Send(&rpc.RunTaskStreamRequest{Cancel: true, SessionID: reqBody.SessionID, CorrelationID: reqBody.CorrelationID})
+		_ = send(&rpc.RunTaskStreamRequest{Cancel: true, SessionID: reqBody.SessionID, CorrelationID: reqBody.CorrelationID})
+		sendMu.Lock()
 		_ = stream.CloseRequest()
+		sendMu.Unlock()
 	}()
 
+	router := &stdinRouter{req: reqBody}
+	go router.route(cmd.InOrStdin(), cmd.ErrOrStderr(), func(d rpc.ApprovalDecision) error {
+		return send(&rpc.RunTaskStreamRequest{SessionID: reqBody.SessionID, CorrelationID: reqBody.CorrelationID, Approval: &d})
+	}, func(in rpc.UserInput) error {
+		return send(&rpc.RunTaskStreamRequest{SessionID: reqBody.SessionID, CorrelationID: reqBody.CorrelationID, Input: &in})
+	})
+
+	renderer := &eventRenderer{out: cmd.OutOrStdout(), approvals: router}
 	for {
 		evt, err := stream.Receive()
 		if errors.Is(err, io.EOF) {
@@ -160,25 +221,43 @@ func runConnect(ctx context.Context, cmd *cobra.Command, url string, reqBody rpc
 		if err != nil {
 			return err
 		}
-		if err := renderEvent(cmd, *evt); err != nil {
+		if err := renderer.render(*evt); err != nil {
 			return err
 		}
 	}
+	sendMu.Lock()
 	_ = stream.CloseRequest()
+	sendMu.Unlock()
 	return stream.CloseResponse()
 }
 
-func renderEvent(cmd *cobra.Command, evt rpc.RunTaskEvent) error {
+// eventRenderer prints RunTaskEvents; it remembers which step streamed tokens so
+// the step's final message is not printed twice, and tells approvals which approval
+// request is open.
+type eventRenderer struct {
+	out          io.Writer
+	streamedStep int
+	approvals    *stdinRouter
+}
+
+func (r *eventRenderer) render(evt rpc.RunTaskEvent) error {
+	out := r.out
 	switch evt.Type {
 	case "tool":
-		fmt.Fprintf(cmd.OutOrStdout(), "[tool %s] %s\n", evt.ToolName, evt.ToolOutput)
+		fmt.Fprintf(out, "[tool %s] %s\n", evt.ToolName, evt.ToolOutput)
 	case "plan":
-		fmt.Fprintf(cmd.OutOrStdout(), "[plan]\n%s\n", evt.Message)
+		if evt.PlanVersion > 1 {
+			fmt.Fprintf(out, "[plan v%d]\n%s\n", evt.PlanVersion, evt.Message)
+		} else {
+			fmt.Fprintf(out, "[plan]\n%s\n", evt.Message)
+		}
+	case "plan_update":
+		fmt.Fprintf(out, "[plan] %s\n", evt.Message)
 	case "reflect":
-		fmt.Fprintf(cmd.OutOrStdout(), "[reflect]\n%s\n", evt.Message)
+		fmt.Fprintf(out, "[reflect]\n%s\n", evt.Message)
 		if evt.Critique != nil {
 			if data, err := json.MarshalIndent(evt.Critique, "", "  "); err == nil {
-				fmt.Fprintf(cmd.OutOrStdout(), "[critique]\n%s\n", string(data))
+				fmt.Fprintf(out, "[critique]\n%s\n", string(data))
 			}
 		}
 	case "test":
@@ -186,23 +265,58 @@ func renderEvent(cmd *cobra.Command, evt rpc.RunTaskEvent) error {
 		if evt.ExitCode != 0 || evt.Error != "" {
 			status = "fail"
 		}
-		fmt.Fprintf(cmd.OutOrStdout(), "[test %s exit=%d attempts=%d]\n", status, evt.ExitCode, evt.TestAttempts)
+		fmt.Fprintf(out, "[test %s exit=%d attempts=%d]\n", status, evt.ExitCode, evt.TestAttempts)
 		if len(evt.FailingTests) > 0 {
-			fmt.Fprintf(cmd.OutOrStdout(), "Failing: %s\n", strings.Join(evt.FailingTests, ", "))
+			fmt.Fprintf(out, "Failing: %s\n", strings.Join(evt.FailingTests, ", "))
 		}
 		if evt.TestSummary != "" {
-			fmt.Fprintf(cmd.OutOrStdout(), "Summary: %s\n", evt.TestSummary)
+			fmt.Fprintf(out, "Summary: %s\n", evt.TestSummary)
 		}
-		fmt.Fprintln(cmd.OutOrStdout(), evt.Message)
+		fmt.Fprintln(out, evt.Message)
 		if evt.Error != "" {
-			fmt.Fprintf(cmd.OutOrStdout(), "[test error] %s\n", evt.Error)
+			fmt.Fprintf(out, "[test error] %s\n", evt.Error)
+		}
+	case "escalate":
+		fmt.Fprintf(out, "[escalate] %s\n", evt.Message)
+	case "question":
+		fmt.Fprintf(out, "[question] %s\n", evt.Message)
+	case "input":
+		fmt.Fprintf(out, "[you] %s\n", evt.Message)
+	case "approval_request":
+		fmt.Fprintf(out, "[approval %s] %s\n", evt.ToolName, evt.Message)
+		if data, err := json.MarshalIndent(evt.ToolArgs, "", "  "); err == nil {
+			fmt.Fprintf(out, "%s\n", string(data))
 		}
+		if evt.Diff != "" {
+			fmt.Fprintln(out, strings.TrimRight(evt.Diff, "\n"))
+		}
+		fmt.Fprintln(out, "Approve? "+approvalHint)
+		r.approvals.setApproval(evt.ApprovalID)
+	case "approval":
+		if evt.Message != "" {
+			fmt.Fprintf(out, "[approval %s: %s] %s\n", evt.ToolName, evt.Decision, evt.Message)
+		} else {
+			fmt.Fprintf(out, "[approval %s: %s]\n", evt.ToolName, evt.Decision)
+		}
+		r.approvals.setApproval("")
+	case "classify":
+		fmt.Fprintf(out, "[classify] %s\n", evt.Message)
 	case "token":
-		fmt.Fprint(cmd.OutOrStdout(), evt.Token+" ")
+		fmt.Fprint(out, evt.Token)
+		r.streamedStep = evt.Step
 	case "message":
-		fmt.Fprintln(cmd.OutOrStdout(), evt.Message)
+		if evt.Step != 0 && evt.Step == r.streamedStep {
+			// Content already arrived as tokens; just terminate the line.
+			fmt.Fprintln(out)
+			r.streamedStep = 0
+			return nil
+		}
+		fmt.Fprintln(out, evt.Message)
 	case "done":
-		fmt.Fprintln(cmd.OutOrStdout(), "\n[done]")
+		fmt.Fprintln(out, "\n[done]")
+		if u := evt.Usage; u != nil && u.TotalTokens > 0 {
+			fmt.Fprintf(out, "[usage] %d tokens (%d prompt, %d completion), $%.4f\n", u.TotalTokens, u.PromptTokens, u.CompletionTokens, u.CostUSD)
+		}
 	case "error":
 		return fmt.Errorf("daemon error: %s", evt.Error)
 	}
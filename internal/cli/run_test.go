@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/animus-coder/animus-coder/internal/rpc"
+)
+
+func TestRendererPrintsTokensWithoutRepeatingMessage(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := &eventRenderer{out: buf}
+
+	for _, evt := range []rpc.RunTaskEvent{
+		{Type: "token", Token: "Hel", Step: 1},
+		{Type: "token", Token: "lo", Step: 1},
+		{Type: "message", Message: "Hello", Step: 1},
+		{Type: "message", Message: "Run halted", Step: 1},
+		{Type: "plan", Message: "1. fix it", PlanVersion: 2},
+		{Type: "plan_update", Message: "Step 1 done: inspect", PlanSteps: []rpc.PlanStep{{ID: 1, Description: "inspect", Status: "done"}}},
+		{Type: "classify", Message: "Task classified as trivial", Complexity: "trivial"},
+		{Type: "escalate", Message: "escalating coder to strong", Model: "strong", Step: 1},
+		{Type: "question", Message: "Which database?", QuestionID: "call_1", Step: 1},
+		{Type: "input", Message: "use postgres", Step: 2},
+		{Type: "approval_request", Message: "terminal.exec requires approval", ApprovalID: "call_2", ToolName: "terminal.exec", ToolArgs: map[string]interface{}{"command": "make"}},
+		{Type: "approval", ApprovalID: "call_2", ToolName: "terminal.exec", Decision: "deny", Message: "no decision within 5m0s"},
+		{Type: "done", Done: true, Usage: &rpc.RunUsage{PromptTokens: 1200, CompletionTokens: 300, TotalTokens: 1500, CostUSD: 0.0081}},
+	} {
+		require.NoError(t, r.render(evt))
+	}
+
+	require.Equal(t, "Hello\nRun halted\n[plan v2]\n1. fix it\n[plan] Step 1 done: inspect\n[classify] Task classified as trivial\n[escalate] escalating coder to strong\n[question] Which database?\n[you] use postgres\n[approval terminal.exec] terminal.exec requires approval\n{\n  \"command\": \"make\"\n}\nApprove? answer \"y\", \"n [reason]\" or \"edit {json args}\"\n[approval terminal.exec: deny] no decision within 5m0s\n\n[done]\n[usage] 1500 tokens (1200 prompt, 300 completion), $0.0081\n", buf.String())
+}
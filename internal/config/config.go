@@ -3,10 +3,14 @@ package config
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
 )
 
 // Config describes the top-level application configuration loaded from YAML and ENV.
@@ -24,12 +28,31 @@ type Config struct {
 
 // ProviderConfig represents LLM provider configuration such as OpenAI, Ollama, or custom gateways.
 type ProviderConfig struct {
-	Type      string        `mapstructure:"type"`       // openai, openrouter, ollama, vllm, lmstudio, custom
+	Type      string        `mapstructure:"type"`       // openai, openrouter, ollama, vllm, lmstudio, custom, anthropic, gemini, replay, mock
 	Model     string        `mapstructure:"model"`      // default model for the provider
 	BaseURL   string        `mapstructure:"base_url"`   // API base URL
 	APIKey    string        `mapstructure:"api_key"`    // optional API key
-	Timeout   time.Duration `mapstructure:"timeout"`    // request timeout
+	Timeout   time.Duration `mapstructure:"timeout"`    // wait for response headers; streamed bodies are not cut off
 	MaxTokens int           `mapstructure:"max_tokens"` // optional provider-level token cap
+	Retry     RetryConfig   `mapstructure:"retry"`      // retry policy for transient failures
+	Breaker   BreakerConfig `mapstructure:"circuit_breaker"`
+	Replay    ReplayConfig  `mapstructure:"replay"`   // only for type: replay
+	Scenario  string        `mapstructure:"scenario"` // YAML scenario file, only for type: mock
+}
+
+// ReplayConfig configures the record/replay cassette provider.
+type ReplayConfig struct {
+	Cassette string `mapstructure:"cassette"` // cassette file path
+	Mode     string `mapstructure:"mode"`     // replay (default) or record
+	Upstream string `mapstructure:"upstream"` // provider whose exchanges are recorded (record mode)
+}
+
+// RetryConfig controls retries of rate-limited, overloaded or timed-out provider calls.
+// Zero values use the defaults (3 attempts, 500ms initial backoff, 30s max backoff).
+type RetryConfig struct {
+	MaxAttempts    int           `mapstructure:"max_attempts"`    // total attempts including the first; 1 disables retries
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"` // delay before the first retry, doubled each time
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`     // backoff cap; longer Retry-After values are not waited out
 }
 
 // ModelConfig binds a logical model name to a provider entry and model parameters.
@@ -40,6 +63,19 @@ type ModelConfig struct {
 	MaxTokens   int     `mapstructure:"max_tokens"`
 	Default     bool    `mapstructure:"default"`
 	Expensive   bool    `mapstructure:"expensive"`
+	// Prices in USD per million tokens, used to report run cost; zero leaves the model unpriced.
+	InputCostPerMTok  float64 `mapstructure:"input_cost_per_mtok"`
+	OutputCostPerMTok float64 `mapstructure:"output_cost_per_mtok"`
+	// ContextWindow is the model's context size in tokens; history is compacted as it fills up.
+	// Zero disables compaction for the model.
+	ContextWindow int `mapstructure:"context_window"`
+}
+
+// BreakerConfig controls the per-provider circuit breaker. Zero values use the defaults
+// (open after 5 consecutive failures, probe again after 30s).
+type BreakerConfig struct {
+	FailureThreshold int           `mapstructure:"failure_threshold"` // consecutive failures (after retries) that open the circuit
+	Cooldown         time.Duration `mapstructure:"cooldown"`          // time the circuit stays open before a probe call
 }
 
 // SandboxConfig controls command and filesystem restrictions.
@@ -55,29 +91,86 @@ type SandboxConfig struct {
 
 // ToolsConfig configures tool behaviour.
 type ToolsConfig struct {
-	AllowExec            bool `mapstructure:"allow_exec"`
-	AllowGit             bool `mapstructure:"allow_git"`
-	AllowFileWrite       bool `mapstructure:"allow_file_write"`
-	ExecTimeoutSeconds   int  `mapstructure:"exec_timeout_seconds"`
-	EnableSemantic       bool `mapstructure:"enable_semantic"`
-	SemanticMaxFiles     int  `mapstructure:"semantic_max_files"`
-	SemanticMaxFileBytes int  `mapstructure:"semantic_max_file_bytes"`
+	AllowExec            bool           `mapstructure:"allow_exec"`
+	AllowGit             bool           `mapstructure:"allow_git"`
+	AllowFileWrite       bool           `mapstructure:"allow_file_write"`
+	ExecTimeoutSeconds   int            `mapstructure:"exec_timeout_seconds"`
+	EnableSemantic       bool           `mapstructure:"enable_semantic"`
+	SemanticMaxFiles     int            `mapstructure:"semantic_max_files"`
+	SemanticMaxFileBytes int            `mapstructure:"semantic_max_file_bytes"`
+	Approval             ApprovalConfig `mapstructure:"approval"`
+}
+
+// ApprovalConfig lists the model tool calls that wait for a human decision before they run.
+type ApprovalConfig struct {
+	Rules   []ApprovalRule `mapstructure:"rules"`
+	Timeout time.Duration  `mapstructure:"timeout"` // unanswered requests are denied after it; 0 waits until the run ends
+}
+
+// ApprovalRule matches tool calls by name and arguments; every set condition must hold.
+type ApprovalRule struct {
+	Tool        string                 `mapstructure:"tool"`         // tool name, or * for every tool
+	Paths       []string               `mapstructure:"paths"`        // only calls whose path argument is under one of these directories
+	ExceptPaths []string               `mapstructure:"except_paths"` // not calls whose path argument is under one of these directories
+	Args        map[string]interface{} `mapstructure:"args"`         // argument values; a missing argument counts as its zero value
 }
 
 // AgentConfig describes Agent Core runtime parameters.
 type AgentConfig struct {
-	MaxSteps           int     `mapstructure:"max_steps"`
-	MaxTokens          int     `mapstructure:"max_tokens"`
-	Temperature        float64 `mapstructure:"temperature"`
-	EnablePlan         bool    `mapstructure:"enable_plan"`
-	EnableReflect      bool    `mapstructure:"enable_reflect"`
-	ReflectionPolicy   string  `mapstructure:"reflection_policy"`
-	EnableSelfDiff     bool    `mapstructure:"enable_self_diff"`
-	EnableTestRun      bool    `mapstructure:"enable_test_run"`
-	TestCommand        string  `mapstructure:"test_command"`
-	TestRetries        int     `mapstructure:"test_retries"`
-	TestTimeoutSeconds int     `mapstructure:"test_timeout_seconds"`
-	MaxContextBytes    int     `mapstructure:"max_context_bytes"`
+	MaxSteps           int                `mapstructure:"max_steps"`
+	MaxTokens          int                `mapstructure:"max_tokens"`
+	Temperature        float64            `mapstructure:"temperature"`
+	EnablePlan         bool               `mapstructure:"enable_plan"`
+	EnableReflect      bool               `mapstructure:"enable_reflect"`
+	ReflectionPolicy   string             `mapstructure:"reflection_policy"`
+	EnableSelfDiff     bool               `mapstructure:"enable_self_diff"`
+	EnableTestRun      bool               `mapstructure:"enable_test_run"`
+	TestCommand        string             `mapstructure:"test_command"`
+	TestRetries        int                `mapstructure:"test_retries"`
+	TestTimeoutSeconds int                `mapstructure:"test_timeout_seconds"`
+	MaxContextBytes    int                `mapstructure:"max_context_bytes"`
+	Sessions           SessionsConfig     `mapstructure:"sessions"`
+	Compaction         CompactionConfig   `mapstructure:"compaction"`
+	MaxReplans         int                `mapstructure:"max_replans"` // automatic plan revisions per run; 0 disables
+	Prompts            PromptsConfig      `mapstructure:"prompts"`
+	Instructions       InstructionsConfig `mapstructure:"instructions"`
+	QuestionTimeout    time.Duration      `mapstructure:"question_timeout"` // wait for an answer to the agent's question; 0 waits until the run ends
+}
+
+// InstructionsConfig selects the workspace instruction files merged into system prompts.
+// They are looked up in every directory from the workspace root down to the directories
+// a run touches, and are budgeted separately from max_context_bytes.
+type InstructionsConfig struct {
+	Files    []string `mapstructure:"files"`     // file names looked up per directory; empty disables
+	MaxBytes int      `mapstructure:"max_bytes"` // total instruction bytes per run; 0 = unlimited
+}
+
+// PromptRoles are the roles whose system prompt comes from a template.
+var PromptRoles = []string{llm.AgentRoleCoder, llm.AgentRolePlanner, llm.AgentRoleReplanner, llm.AgentRoleCritic, llm.AgentRoleSummarizer}
+
+// PromptsConfig overrides the built-in system prompt templates. A role uses Files[role]
+// when set, else <Dir>/<role>.tmpl when that file exists; relative paths are resolved
+// against the sandbox working directory.
+type PromptsConfig struct {
+	Dir   string            `mapstructure:"dir"`   // workspace template directory
+	Files map[string]string `mapstructure:"files"` // role -> template file
+}
+
+// CompactionConfig controls summarizing older history once a session nears the coder
+// model's context_window.
+type CompactionConfig struct {
+	Model      string  `mapstructure:"model"`       // cheap summarizer model; empty uses the coder model
+	Threshold  float64 `mapstructure:"threshold"`   // share of the context window that triggers compaction; 0 means 0.75
+	KeepRecent int     `mapstructure:"keep_recent"` // most recent history messages kept verbatim; 0 means 6
+}
+
+// SessionsConfig selects where agent sessions are kept and when they are evicted.
+type SessionsConfig struct {
+	Backend     string        `mapstructure:"backend"`      // memory or disk
+	Dir         string        `mapstructure:"dir"`          // disk: one <session>.jsonl log per session
+	TTL         time.Duration `mapstructure:"ttl"`          // idle time before a session is dropped; 0 keeps it
+	MaxSessions int           `mapstructure:"max_sessions"` // least recently used evicted beyond this; 0 = unlimited
+	MaxBytes    int64         `mapstructure:"max_bytes"`    // total stored session bytes; 0 = unlimited
 }
 
 // LoggingConfig controls logger behaviour.
@@ -153,6 +246,7 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("tools.enable_semantic", false)
 	v.SetDefault("tools.semantic_max_files", 200)
 	v.SetDefault("tools.semantic_max_file_bytes", 65536)
+	v.SetDefault("tools.approval.timeout", "5m")
 
 	v.SetDefault("agent.max_steps", 8)
 	v.SetDefault("agent.max_tokens", 1024)
@@ -166,6 +260,13 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("agent.test_retries", 0)
 	v.SetDefault("agent.test_timeout_seconds", 0)
 	v.SetDefault("agent.max_context_bytes", 32768)
+	v.SetDefault("agent.max_replans", 2)
+	v.SetDefault("agent.sessions.backend", "memory")
+	v.SetDefault("agent.sessions.dir", ".mycodex/sessions")
+	v.SetDefault("agent.prompts.dir", ".mycodex/prompts")
+	v.SetDefault("agent.instructions.files", []string{"AGENTS.md", "CONTRIBUTING.md", ".mycodex/instructions.md"})
+	v.SetDefault("agent.instructions.max_bytes", 16384)
+	v.SetDefault("agent.question_timeout", "5m")
 
 	v.SetDefault("strategy.default_model", "")
 	v.SetDefault("strategy.planner_model", "")
@@ -173,7 +274,9 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("strategy.critic_model", "")
 	v.SetDefault("strategy.overrides", map[string]string{})
 	v.SetDefault("strategy.fallbacks", []string{})
+	v.SetDefault("strategy.role_fallbacks", map[string][]string{})
 	v.SetDefault("strategy.max_expensive", 0)
+	v.SetDefault("strategy.mode", "static")
 
 	v.SetDefault("server.addr", ":8080")
 	v.SetDefault("server.metrics_enabled", true)
@@ -195,6 +298,20 @@ func (c *Config) Validate() error {
 		if p.Type == "" {
 			return fmt.Errorf("provider %q must define type", name)
 		}
+		if p.Retry.MaxAttempts < 0 || p.Retry.InitialBackoff < 0 || p.Retry.MaxBackoff < 0 {
+			return fmt.Errorf("provider %q retry settings must not be negative", name)
+		}
+		if p.Breaker.FailureThreshold < 0 || p.Breaker.Cooldown < 0 {
+			return fmt.Errorf("provider %q circuit_breaker settings must not be negative", name)
+		}
+		if p.Type == "replay" {
+			if err := c.validateReplay(name, p.Replay); err != nil {
+				return err
+			}
+		}
+		if p.Type == "mock" && strings.TrimSpace(p.Scenario) == "" {
+			return fmt.Errorf("provider %q of type mock must set scenario", name)
+		}
 	}
 
 	for name, m := range c.Models {
@@ -214,6 +331,14 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("model %q max_tokens cannot be negative", name)
 		}
 
+		if m.InputCostPerMTok < 0 || m.OutputCostPerMTok < 0 {
+			return fmt.Errorf("model %q token prices cannot be negative", name)
+		}
+
+		if m.ContextWindow < 0 {
+			return fmt.Errorf("model %q context_window cannot be negative", name)
+		}
+
 		if m.Default {
 			defaultFound = true
 		}
@@ -240,11 +365,53 @@ func (c *Config) Validate() error {
 	if c.Agent.TestTimeoutSeconds < 0 {
 		return errors.New("agent.test_timeout_seconds must be >= 0")
 	}
+	if c.Agent.MaxReplans < 0 {
+		return errors.New("agent.max_replans must be >= 0")
+	}
 	switch strings.ToLower(strings.TrimSpace(c.Agent.ReflectionPolicy)) {
 	case "", "block_on_critical", "never_block", "warn_only":
 	default:
 		return fmt.Errorf("agent.reflection_policy must be one of block_on_critical, never_block, warn_only")
 	}
+	switch c.Agent.Sessions.Backend {
+	case "", "memory":
+	case "disk":
+		if strings.TrimSpace(c.Agent.Sessions.Dir) == "" {
+			return errors.New("agent.sessions.dir must be set when agent.sessions.backend is disk")
+		}
+	default:
+		return fmt.Errorf("agent.sessions.backend must be memory or disk, got %q", c.Agent.Sessions.Backend)
+	}
+	if s := c.Agent.Sessions; s.TTL < 0 || s.MaxSessions < 0 || s.MaxBytes < 0 {
+		return errors.New("agent.sessions ttl, max_sessions and max_bytes must be >= 0")
+	}
+	if cc := c.Agent.Compaction; cc.Threshold < 0 || cc.Threshold > 1 || cc.KeepRecent < 0 {
+		return errors.New("agent.compaction.threshold must be within 0-1 and keep_recent >= 0")
+	}
+	if m := c.Agent.Compaction.Model; m != "" {
+		if _, ok := c.Models[m]; !ok {
+			return fmt.Errorf("agent.compaction.model references unknown model %q", m)
+		}
+	}
+	if c.Agent.QuestionTimeout < 0 {
+		return errors.New("agent.question_timeout must be >= 0")
+	}
+	if c.Agent.Instructions.MaxBytes < 0 {
+		return errors.New("agent.instructions.max_bytes must be >= 0")
+	}
+	for _, name := range c.Agent.Instructions.Files {
+		if strings.TrimSpace(name) == "" || filepath.IsAbs(name) || strings.HasPrefix(filepath.Clean(name), "..") {
+			return fmt.Errorf("agent.instructions.files entry %q must be a relative path inside the directory", name)
+		}
+	}
+	for role, path := range c.Agent.Prompts.Files {
+		if !slices.Contains(PromptRoles, role) {
+			return fmt.Errorf("agent.prompts.files has unknown role %q (want one of %s)", role, strings.Join(PromptRoles, ", "))
+		}
+		if strings.TrimSpace(path) == "" {
+			return fmt.Errorf("agent.prompts.files.%s is empty", role)
+		}
+	}
 
 	if c.Sandbox.TimeoutSeconds <= 0 {
 		return errors.New("sandbox.timeout_seconds must be > 0")
@@ -253,6 +420,19 @@ func (c *Config) Validate() error {
 	if c.Tools.ExecTimeoutSeconds <= 0 {
 		return errors.New("tools.exec_timeout_seconds must be > 0")
 	}
+	if c.Tools.Approval.Timeout < 0 {
+		return errors.New("tools.approval.timeout must be >= 0")
+	}
+	for i, rule := range c.Tools.Approval.Rules {
+		if strings.TrimSpace(rule.Tool) == "" {
+			return fmt.Errorf("tools.approval.rules[%d] must set tool", i)
+		}
+		for _, dir := range append(append([]string(nil), rule.Paths...), rule.ExceptPaths...) {
+			if strings.TrimSpace(dir) == "" || filepath.IsAbs(dir) || strings.HasPrefix(filepath.Clean(dir), "..") {
+				return fmt.Errorf("tools.approval.rules[%d] path %q must be a relative path inside the workspace", i, dir)
+			}
+		}
+	}
 
 	for _, modelID := range []string{
 		c.Strategy.DefaultModel, c.Strategy.PlannerModel, c.Strategy.CoderModel, c.Strategy.CriticModel,
@@ -264,9 +444,19 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("strategy references unknown model %q", modelID)
 		}
 	}
-	for _, modelID := range c.Strategy.Fallbacks {
-		if _, ok := c.Models[modelID]; !ok {
-			return fmt.Errorf("strategy fallback references unknown model %q", modelID)
+	if err := c.validateFallbackChain("strategy.fallbacks", c.Strategy.Fallbacks); err != nil {
+		return err
+	}
+	for role, chain := range c.Strategy.RoleFallbacks {
+		switch role {
+		case llm.AgentRolePlanner, llm.AgentRoleCoder, llm.AgentRoleCritic:
+		default:
+			if _, ok := c.Strategy.Overrides[role]; !ok {
+				return fmt.Errorf("strategy.role_fallbacks key %q must be planner, coder, critic or an overrides key", role)
+			}
+		}
+		if err := c.validateFallbackChain("strategy.role_fallbacks."+role, chain); err != nil {
+			return err
 		}
 	}
 	for _, modelID := range c.Strategy.Overrides {
@@ -277,6 +467,34 @@ func (c *Config) Validate() error {
 	if c.Strategy.MaxExpensive < 0 {
 		return fmt.Errorf("strategy.max_expensive must be >= 0")
 	}
+	if b := c.Strategy.Budget; b.MaxPromptTokens < 0 || b.MaxCompletionTokens < 0 || b.MaxCostUSD < 0 {
+		return fmt.Errorf("strategy.budget limits must be >= 0")
+	}
+	if d := c.Strategy.Budget.DowngradeAt; d < 0 || d > 1 {
+		return fmt.Errorf("strategy.budget.downgrade_at must be within [0,1]")
+	}
+	if m := c.Strategy.Escalation.Model; m != "" {
+		if _, ok := c.Models[m]; !ok {
+			return fmt.Errorf("strategy.escalation references unknown model %q", m)
+		}
+	}
+	if c.Strategy.Escalation.AfterFailures < 0 {
+		return fmt.Errorf("strategy.escalation.after_failures must be >= 0")
+	}
+	if err := c.validateComplexity(); err != nil {
+		return err
+	}
+	switch c.Strategy.Mode {
+	case "", "static", "adaptive":
+	default:
+		return fmt.Errorf("strategy.mode must be static or adaptive, got %q", c.Strategy.Mode)
+	}
+	if a := c.Strategy.Adaptive; a.Exploration < 0 || a.Exploration > 1 {
+		return fmt.Errorf("strategy.adaptive.exploration must be within [0,1]")
+	}
+	if a := c.Strategy.Adaptive; a.Window < 0 || a.MinSamples < 0 || a.LatencyTarget < 0 {
+		return fmt.Errorf("strategy.adaptive window, min_samples and latency_target must be >= 0")
+	}
 	if c.Tools.SemanticMaxFiles < 0 {
 		return errors.New("tools.semantic_max_files must be >= 0")
 	}
@@ -292,3 +510,66 @@ func (c *Config) Validate() error {
 
 	return nil
 }
+
+// validateComplexity checks the classifier kind, its judge model and the label routes.
+func (c *Config) validateComplexity() error {
+	cc := c.Strategy.Complexity
+	switch cc.Classifier {
+	case "", "heuristic":
+	case "llm":
+		if _, ok := c.Models[cc.Model]; !ok {
+			return fmt.Errorf("strategy.complexity.model must name a configured model for the llm classifier, got %q", cc.Model)
+		}
+	default:
+		return fmt.Errorf("strategy.complexity.classifier must be heuristic or llm, got %q", cc.Classifier)
+	}
+	for label, modelID := range cc.Routes {
+		switch label {
+		case "trivial", "standard", "hard":
+		default:
+			return fmt.Errorf("strategy.complexity.routes key %q must be trivial, standard or hard", label)
+		}
+		if _, ok := c.Models[modelID]; !ok {
+			return fmt.Errorf("strategy.complexity.routes.%s references unknown model %q", label, modelID)
+		}
+	}
+	return nil
+}
+
+// validateFallbackChain checks that every link of a fallback chain names a known model once.
+func (c *Config) validateFallbackChain(name string, chain []string) error {
+	seen := make(map[string]struct{}, len(chain))
+	for _, modelID := range chain {
+		if _, ok := c.Models[modelID]; !ok {
+			return fmt.Errorf("%s references unknown model %q", name, modelID)
+		}
+		if _, dup := seen[modelID]; dup {
+			return fmt.Errorf("%s lists model %q more than once", name, modelID)
+		}
+		seen[modelID] = struct{}{}
+	}
+	return nil
+}
+
+// validateReplay checks a replay provider: it needs a cassette, and record mode needs
+// a non-replay upstream provider to forward requests to.
+func (c *Config) validateReplay(name string, r ReplayConfig) error {
+	if strings.TrimSpace(r.Cassette) == "" {
+		return fmt.Errorf("provider %q of type replay must set replay.cassette", name)
+	}
+	switch r.Mode {
+	case "", "replay":
+		return nil
+	case "record":
+	default:
+		return fmt.Errorf("provider %q replay.mode must be replay or record, got %q", name, r.Mode)
+	}
+	up, ok := c.Providers[r.Upstream]
+	if !ok {
+		return fmt.Errorf("provider %q records from unknown upstream provider %q", name, r.Upstream)
+	}
+	if up.Type == "replay" {
+		return fmt.Errorf("provider %q cannot record from replay provider %q", name, r.Upstream)
+	}
+	return nil
+}
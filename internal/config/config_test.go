@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -40,6 +41,11 @@ agent:
 	require.NoError(t, err)
 	require.Equal(t, "openai", cfg.Models["main"].Provider)
 	require.Equal(t, 6, cfg.Agent.MaxSteps)
+	require.Equal(t, 2, cfg.Agent.MaxReplans)
+	require.Equal(t, 5*time.Minute, cfg.Agent.QuestionTimeout)
+	require.Equal(t, 5*time.Minute, cfg.Tools.Approval.Timeout)
+	require.Equal(t, []string{"AGENTS.md", "CONTRIBUTING.md", ".mycodex/instructions.md"}, cfg.Agent.Instructions.Files)
+	require.Equal(t, 16384, cfg.Agent.Instructions.MaxBytes)
 	require.Equal(t, true, cfg.Sandbox.Enabled)
 }
 
@@ -88,3 +94,206 @@ func TestValidateFailsOnUnknownProvider(t *testing.T) {
 	err := cfg.Validate()
 	require.Error(t, err)
 }
+
+func TestValidateRejectsInvalidBudget(t *testing.T) {
+	cfg := Config{
+		Providers: map[string]ProviderConfig{"openai": {Type: "openai"}},
+		Models:    map[string]ModelConfig{"default": {Provider: "openai", Default: true}},
+		Agent:     AgentConfig{MaxSteps: 1},
+		Sandbox:   SandboxConfig{TimeoutSeconds: 10},
+		Tools:     ToolsConfig{ExecTimeoutSeconds: 10},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Strategy.Budget = BudgetConfig{MaxCostUSD: -1}
+	require.ErrorContains(t, cfg.Validate(), "strategy.budget")
+
+	cfg.Strategy.Budget = BudgetConfig{MaxCostUSD: 5, DowngradeAt: 1.5}
+	require.ErrorContains(t, cfg.Validate(), "downgrade_at")
+}
+
+func TestValidateChecksEveryFallbackChain(t *testing.T) {
+	cfg := Config{
+		Providers: map[string]ProviderConfig{"openai": {Type: "openai"}},
+		Models: map[string]ModelConfig{
+			"default": {Provider: "openai", Default: true},
+			"small":   {Provider: "openai"},
+		},
+		Agent:   AgentConfig{MaxSteps: 1},
+		Sandbox: SandboxConfig{TimeoutSeconds: 10},
+		Tools:   ToolsConfig{ExecTimeoutSeconds: 10},
+		Strategy: StrategyConfig{
+			Overrides:     map[string]string{"docs": "small"},
+			RoleFallbacks: map[string][]string{"critic": {"small", "default"}, "docs": {"default"}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Strategy.RoleFallbacks["planner"] = []string{"missing"}
+	require.ErrorContains(t, cfg.Validate(), `strategy.role_fallbacks.planner references unknown model "missing"`)
+
+	cfg.Strategy.RoleFallbacks["planner"] = []string{"small", "small"}
+	require.ErrorContains(t, cfg.Validate(), "more than once")
+
+	delete(cfg.Strategy.RoleFallbacks, "planner")
+	cfg.Strategy.RoleFallbacks["reviewer"] = []string{"small"}
+	require.ErrorContains(t, cfg.Validate(), `key "reviewer"`)
+}
+
+func TestValidateChecksAdaptiveSettings(t *testing.T) {
+	cfg := Config{
+		Providers: map[string]ProviderConfig{"openai": {Type: "openai"}},
+		Models:    map[string]ModelConfig{"default": {Provider: "openai", Default: true}},
+		Agent:     AgentConfig{MaxSteps: 1},
+		Sandbox:   SandboxConfig{TimeoutSeconds: 10},
+		Tools:     ToolsConfig{ExecTimeoutSeconds: 10},
+		Strategy:  StrategyConfig{Mode: "adaptive", Adaptive: AdaptiveConfig{Exploration: 0.1}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Strategy.Mode = "random"
+	require.ErrorContains(t, cfg.Validate(), "strategy.mode")
+
+	cfg.Strategy.Mode = "adaptive"
+	cfg.Strategy.Adaptive.Exploration = 2
+	require.ErrorContains(t, cfg.Validate(), "exploration")
+}
+
+func TestValidateChecksComplexityRoutes(t *testing.T) {
+	cfg := Config{
+		Providers: map[string]ProviderConfig{"openai": {Type: "openai"}},
+		Models:    map[string]ModelConfig{"default": {Provider: "openai", Default: true}, "local-coder": {Provider: "openai"}},
+		Agent:     AgentConfig{MaxSteps: 1},
+		Sandbox:   SandboxConfig{TimeoutSeconds: 10},
+		Tools:     ToolsConfig{ExecTimeoutSeconds: 10},
+		Strategy: StrategyConfig{Complexity: ComplexityConfig{
+			Classifier: "heuristic",
+			Routes:     map[string]string{"trivial": "local-coder"},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Strategy.Complexity.Routes["easy"] = "default"
+	require.ErrorContains(t, cfg.Validate(), "must be trivial, standard or hard")
+
+	delete(cfg.Strategy.Complexity.Routes, "easy")
+	cfg.Strategy.Complexity.Routes["hard"] = "missing"
+	require.ErrorContains(t, cfg.Validate(), "unknown model")
+
+	delete(cfg.Strategy.Complexity.Routes, "hard")
+	cfg.Strategy.Complexity.Classifier = "llm"
+	require.ErrorContains(t, cfg.Validate(), "strategy.complexity.model")
+	cfg.Strategy.Complexity.Model = "local-coder"
+	require.NoError(t, cfg.Validate())
+}
+
+func TestValidateChecksSessionStore(t *testing.T) {
+	cfg := Config{
+		Providers: map[string]ProviderConfig{"openai": {Type: "openai"}},
+		Models:    map[string]ModelConfig{"default": {Provider: "openai", Default: true}},
+		Agent:     AgentConfig{MaxSteps: 1, Sessions: SessionsConfig{Backend: "disk", Dir: ".mycodex/sessions", TTL: time.Hour}},
+		Sandbox:   SandboxConfig{TimeoutSeconds: 10},
+		Tools:     ToolsConfig{ExecTimeoutSeconds: 10},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Agent.Sessions.Dir = ""
+	require.ErrorContains(t, cfg.Validate(), "agent.sessions.dir")
+
+	cfg.Agent.Sessions.Backend = "redis"
+	require.ErrorContains(t, cfg.Validate(), "agent.sessions.backend")
+
+	cfg.Agent.Sessions = SessionsConfig{MaxSessions: -1}
+	require.ErrorContains(t, cfg.Validate(), "max_sessions")
+}
+
+func TestValidateChecksCompaction(t *testing.T) {
+	cfg := Config{
+		Providers: map[string]ProviderConfig{"openai": {Type: "openai"}},
+		Models: map[string]ModelConfig{
+			"default": {Provider: "openai", Default: true, ContextWindow: 128000},
+			"cheap":   {Provider: "openai"},
+		},
+		Agent:   AgentConfig{MaxSteps: 1, Compaction: CompactionConfig{Model: "cheap", Threshold: 0.8, KeepRecent: 4}},
+		Sandbox: SandboxConfig{TimeoutSeconds: 10},
+		Tools:   ToolsConfig{ExecTimeoutSeconds: 10},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Agent.Compaction.Model = "missing"
+	require.ErrorContains(t, cfg.Validate(), "agent.compaction.model")
+
+	cfg.Agent.Compaction.Model = ""
+	cfg.Agent.Compaction.Threshold = 1.5
+	require.ErrorContains(t, cfg.Validate(), "agent.compaction.threshold")
+
+	cfg.Agent.Compaction.Threshold = 0
+	cfg.Models["cheap"] = ModelConfig{Provider: "openai", ContextWindow: -1}
+	require.ErrorContains(t, cfg.Validate(), "context_window")
+}
+
+func TestValidateRejectsNegativeMaxReplans(t *testing.T) {
+	cfg := Config{
+		Providers: map[string]ProviderConfig{"openai": {Type: "openai"}},
+		Models:    map[string]ModelConfig{"default": {Provider: "openai", Default: true}},
+		Agent:     AgentConfig{MaxSteps: 1, MaxReplans: -1},
+		Sandbox:   SandboxConfig{TimeoutSeconds: 10},
+		Tools:     ToolsConfig{ExecTimeoutSeconds: 10},
+	}
+	require.ErrorContains(t, cfg.Validate(), "agent.max_replans")
+}
+
+func TestValidateChecksPromptFiles(t *testing.T) {
+	cfg := Config{
+		Providers: map[string]ProviderConfig{"openai": {Type: "openai"}},
+		Models:    map[string]ModelConfig{"default": {Provider: "openai", Default: true}},
+		Agent:     AgentConfig{MaxSteps: 1, Prompts: PromptsConfig{Files: map[string]string{"critic": "prompts/critic.tmpl"}}},
+		Sandbox:   SandboxConfig{TimeoutSeconds: 10},
+		Tools:     ToolsConfig{ExecTimeoutSeconds: 10},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Agent.Prompts.Files["reviewer"] = "reviewer.tmpl"
+	require.ErrorContains(t, cfg.Validate(), `unknown role "reviewer"`)
+}
+
+func TestValidateChecksInstructionFiles(t *testing.T) {
+	cfg := Config{
+		Providers: map[string]ProviderConfig{"openai": {Type: "openai"}},
+		Models:    map[string]ModelConfig{"default": {Provider: "openai", Default: true}},
+		Agent:     AgentConfig{MaxSteps: 1, Instructions: InstructionsConfig{Files: []string{"AGENTS.md", ".mycodex/instructions.md"}}},
+		Sandbox:   SandboxConfig{TimeoutSeconds: 10},
+		Tools:     ToolsConfig{ExecTimeoutSeconds: 10},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Agent.Instructions.Files = []string{"../AGENTS.md"}
+	require.ErrorContains(t, cfg.Validate(), "agent.instructions.files")
+
+	cfg.Agent.Instructions.Files = nil
+	cfg.Agent.Instructions.MaxBytes = -1
+	require.ErrorContains(t, cfg.Validate(), "agent.instructions.max_bytes")
+}
+
+func TestValidateChecksApprovalRules(t *testing.T) {
+	cfg := Config{
+		Providers: map[string]ProviderConfig{"openai": {Type: "openai"}},
+		Models:    map[string]ModelConfig{"default": {Provider: "openai", Default: true}},
+		Agent:     AgentConfig{MaxSteps: 1},
+		Sandbox:   SandboxConfig{TimeoutSeconds: 10},
+		Tools: ToolsConfig{ExecTimeoutSeconds: 10, Approval: ApprovalConfig{Rules: []ApprovalRule{
+			{Tool: "fs.write_file", ExceptPaths: []string{"docs/"}},
+		}}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Tools.Approval.Rules = append(cfg.Tools.Approval.Rules, ApprovalRule{Tool: "fs.write_file", Paths: []string{"../outside"}})
+	require.ErrorContains(t, cfg.Validate(), "tools.approval.rules[1] path")
+
+	cfg.Tools.Approval.Rules[1] = ApprovalRule{Args: map[string]interface{}{"dry_run": false}}
+	require.ErrorContains(t, cfg.Validate(), "tools.approval.rules[1] must set tool")
+
+	cfg.Tools.Approval.Rules = nil
+	cfg.Tools.Approval.Timeout = -time.Second
+	require.ErrorContains(t, cfg.Validate(), "tools.approval.timeout")
+}
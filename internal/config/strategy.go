@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 // StrategyConfig defines per-role model selections and fallbacks.
 type StrategyConfig struct {
 	DefaultModel string            `mapstructure:"default_model"`
@@ -8,5 +10,48 @@ type StrategyConfig struct {
 	CriticModel  string            `mapstructure:"critic_model"`
 	Overrides    map[string]string `mapstructure:"overrides"` // arbitrary step->model id
 	Fallbacks    []string          `mapstructure:"fallbacks"` // ordered fallback model ids
+	// RoleFallbacks gives a role (planner, coder, critic or an override key) its own ordered
+	// fallback chain; roles without one use Fallbacks.
+	RoleFallbacks map[string][]string `mapstructure:"role_fallbacks"`
 	MaxExpensive int               `mapstructure:"max_expensive"` // limit expensive model uses per run (0=unlimited)
+	Budget       BudgetConfig      `mapstructure:"budget"`
+	Mode         string            `mapstructure:"mode"` // static (default) or adaptive
+	Adaptive     AdaptiveConfig    `mapstructure:"adaptive"`
+	Escalation   EscalationConfig  `mapstructure:"escalation"`
+	Complexity   ComplexityConfig  `mapstructure:"complexity"`
+}
+
+// ComplexityConfig enables the pre-run task classifier whose label (trivial, standard,
+// hard) picks the coder's initial route.
+type ComplexityConfig struct {
+	Classifier string            `mapstructure:"classifier"` // "" (off), heuristic or llm
+	Model      string            `mapstructure:"model"`      // judge model for the llm classifier
+	Routes     map[string]string `mapstructure:"routes"`     // label -> coder model id
+}
+
+// EscalationConfig switches the coder to a stronger model for the rest of a run once
+// tests have failed after AfterFailures consecutive steps.
+type EscalationConfig struct {
+	Model         string `mapstructure:"model"`          // escalation model id; empty disables escalation
+	AfterFailures int    `mapstructure:"after_failures"` // consecutive failing test runs before switching; 0 means 2
+}
+
+// AdaptiveConfig tunes adaptive routing, which ranks a role's candidates by rolling
+// statistics (error rate, p95 latency, test pass rate, critic block rate).
+type AdaptiveConfig struct {
+	Exploration   float64       `mapstructure:"exploration"`    // probability (0-1) of trying a random candidate instead of the best
+	Window        int           `mapstructure:"window"`         // outcomes kept per role/model; 0 means 50
+	MinSamples    int           `mapstructure:"min_samples"`    // outcomes needed before a statistic affects ranking; 0 means 5
+	LatencyTarget time.Duration `mapstructure:"latency_target"` // p95 latency that halves a model's score; 0 means 30s
+	StatsPath     string        `mapstructure:"stats_path"`     // JSON file persisting statistics across restarts; empty keeps them in memory
+}
+
+// BudgetConfig caps what a single run may consume; zero limits are unlimited.
+// Selection downgrades to cheaper routes once any limit is DowngradeAt used, and the
+// run stops with finish reason budget_exhausted when a limit is reached.
+type BudgetConfig struct {
+	MaxPromptTokens     int     `mapstructure:"max_prompt_tokens"`
+	MaxCompletionTokens int     `mapstructure:"max_completion_tokens"`
+	MaxCostUSD          float64 `mapstructure:"max_cost_usd"`
+	DowngradeAt         float64 `mapstructure:"downgrade_at"` // fraction of a limit (0-1) that triggers downgrades; 0 means 0.8
 }
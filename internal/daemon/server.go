@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -14,51 +15,66 @@ import (
 
 	"github.com/animus-coder/animus-coder/internal/agent"
 	"github.com/animus-coder/animus-coder/internal/config"
+	"github.com/animus-coder/animus-coder/internal/llm"
 	"github.com/animus-coder/animus-coder/internal/llm/configbuilder"
 	"github.com/animus-coder/animus-coder/internal/observability"
 	agentrpc "github.com/animus-coder/animus-coder/internal/rpc/agent"
 	toolrpc "github.com/animus-coder/animus-coder/internal/rpc/tools"
-	"github.com/animus-coder/animus-coder/internal/semantic"
 	"github.com/animus-coder/animus-coder/internal/tools"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Server hosts lightweight daemon endpoints (health/metrics) and will later expose Agent RPC.
 type Server struct {
-	cfg     *config.Config
-	logger  *zap.Logger
-	runner  agentrpc.Runner
-	metrics *observability.Metrics
-	tools   *tools.Registry
+	cfg      *config.Config
+	logger   *zap.Logger
+	runner   agentrpc.Runner
+	metrics  *observability.Metrics
+	tools    *tools.Registry
+	llm      *llm.Registry
+	strategy *agent.StrategyEngine
 }
 
 // NewServer constructs a daemon instance.
 func NewServer(cfg *config.Config, logger *zap.Logger) (*Server, error) {
-	registry, err := configbuilder.BuildRegistryFromConfig(cfg)
+	metrics := observability.NewMetrics()
+	registry, err := configbuilder.BuildRegistryFromConfig(cfg, metrics)
 	if err != nil {
 		return nil, fmt.Errorf("build registry: %w", err)
 	}
 
 	agentCore := agent.New(registry, cfg.Agent)
-	metrics := observability.NewMetrics()
-	sandbox, err := tools.NewSandbox(cfg.Sandbox.WorkingDir, cfg.Sandbox, cfg.Tools)
+	sessions, err := agent.NewSessionStore(cfg.Agent.Sessions)
 	if err != nil {
-		return nil, fmt.Errorf("build sandbox: %w", err)
+		return nil, fmt.Errorf("open session store: %w", err)
 	}
-	gitTool := &tools.GitTool{
-		WorkingDir: cfg.Sandbox.WorkingDir,
-		AllowExec:  cfg.Tools.AllowGit && cfg.Sandbox.Enabled,
-		DryRunOnly: !cfg.Sandbox.AllowWrite || !cfg.Tools.AllowFileWrite,
+	agentCore.SetSessionStore(sessions)
+	toolRegistry, err := tools.NewRegistryFromConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
-	var semanticEngine *semantic.Engine
-	if cfg.Tools.EnableSemantic {
-		semanticEngine = semantic.NewEngine(sandbox.FS, cfg.Tools.SemanticMaxFiles, cfg.Tools.SemanticMaxFileBytes)
+	prompts, err := agent.LoadPromptTemplates(cfg.Sandbox.WorkingDir, cfg.Agent.Prompts)
+	if err != nil {
+		return nil, fmt.Errorf("load prompt templates: %w", err)
 	}
-	toolRegistry := tools.NewRegistry(sandbox.FS, sandbox.Terminal, gitTool, semanticEngine)
+	agentCore.SetPromptTemplates(prompts)
+	promptEnv, err := agentrpc.NewPromptEnv(cfg, toolRegistry)
+	if err != nil {
+		return nil, fmt.Errorf("describe workspace for prompts: %w", err)
+	}
+	agentCore.SetPromptEnv(promptEnv)
+	agentCore.SetPromptStructure(func() (string, error) { return agentrpc.DescribeWorkspace(toolRegistry) })
 	strategy := agent.NewStrategyEngine(registry, cfg.Strategy)
-	runner := &agentrpc.AgentRunner{Agent: agentCore, Metrics: metrics, Tools: toolRegistry, Strategy: strategy, Logger: logger}
+	if path := cfg.Strategy.Adaptive.StatsPath; path != "" {
+		stats, err := agent.LoadRouteStats(path, cfg.Strategy.Adaptive.Window)
+		if err != nil {
+			return nil, fmt.Errorf("load routing stats: %w", err)
+		}
+		strategy.SetStats(stats)
+	}
+	runner := &agentrpc.AgentRunner{Agent: agentCore, Metrics: metrics, Tools: toolRegistry, Approvals: tools.NewApprovalPolicy(cfg.Tools.Approval), Strategy: strategy, Logger: logger}
 
-	return &Server{cfg: cfg, logger: logger, runner: runner, metrics: metrics, tools: toolRegistry}, nil
+	return &Server{cfg: cfg, logger: logger, runner: runner, metrics: metrics, tools: toolRegistry, llm: registry, strategy: strategy}, nil
 }
 
 // Run starts the HTTP server and blocks until context cancellation or fatal error.
@@ -66,16 +82,19 @@ func (s *Server) Run(ctx context.Context) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", s.healthHandler)
 	mux.HandleFunc("/metrics", s.metricsHandler)
+	mux.HandleFunc("/debug/routing", s.routingHandler)
 	mux.Handle("/tools/schemas", toolrpc.SchemaHandler{Registry: s.tools})
 
 	switch strings.ToLower(strings.TrimSpace(s.cfg.Server.Transport)) {
 	case "ndjson":
 		mux.Handle("/agent/run", agentrpc.NewHandler(s.runner, s.metrics))
+		mux.Handle("/agent/approve", agentrpc.NewApprovalHandler(s.runner, s.metrics))
 	default:
 		path, handler := agentrpc.NewConnectHandler(s.runner, s.metrics)
 		mux.Handle(path, handler)
 		// keep legacy NDJSON path available during migration
 		mux.Handle("/agent/run", agentrpc.NewHandler(s.runner, s.metrics))
+		mux.Handle("/agent/approve", agentrpc.NewApprovalHandler(s.runner, s.metrics))
 	}
 
 	handler := http.Handler(mux)
@@ -96,6 +115,13 @@ func (s *Server) Run(ctx context.Context) error {
 			errCh <- err
 		}
 	}()
+	// Runs save the routing stats as they finish; save them once more however the
+	// daemon stops, including a failed graceful shutdown.
+	defer func() {
+		if err := s.strategy.Stats().Save(); err != nil {
+			s.logger.Warn("save routing stats", zap.Error(err))
+		}
+	}()
 
 	select {
 	case <-ctx.Done():
@@ -112,9 +138,39 @@ func (s *Server) Run(ctx context.Context) error {
 	return nil
 }
 
+// healthHandler reports "ok" while every provider circuit is closed and "degraded" otherwise,
+// together with the per-provider breaker states.
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	status := "ok"
+	var providers map[string]llm.CircuitState
+	if s.llm != nil {
+		providers = s.llm.ProviderStates()
+	}
+	for _, state := range providers {
+		if state != llm.CircuitClosed {
+			status = "degraded"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Status    string                      `json:"status"`
+		Providers map[string]llm.CircuitState `json:"providers,omitempty"`
+	}{Status: status, Providers: providers})
+}
+
+// routingHandler exposes the routing statistics and adaptive scores per role.
+func (s *Server) routingHandler(w http.ResponseWriter, r *http.Request) {
+	mode := s.cfg.Strategy.Mode
+	if mode == "" {
+		mode = "static"
+	}
 	w.Header().Set("Content-Type", "application/json")
-	_, _ = w.Write([]byte(`{"status":"ok"}`))
+	_ = json.NewEncoder(w).Encode(struct {
+		Mode        string                       `json:"mode"`
+		Exploration float64                      `json:"exploration"`
+		Roles       map[string][]agent.RouteRank `json:"roles"`
+	}{Mode: mode, Exploration: s.cfg.Strategy.Adaptive.Exploration, Roles: s.strategy.RoutingSnapshot()})
 }
 
 func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,197 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without contacting the provider while its circuit is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitState is the health state of a provider circuit breaker.
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// BreakerPolicy configures when a circuit opens and how long it stays open.
+type BreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit.
+	FailureThreshold int
+	// Cooldown is how long the circuit stays open before a single probe call is let through.
+	Cooldown time.Duration
+}
+
+func (p BreakerPolicy) withDefaults() BreakerPolicy {
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = defaultBreakerThreshold
+	}
+	if p.Cooldown <= 0 {
+		p.Cooldown = defaultBreakerCooldown
+	}
+	return p
+}
+
+// CircuitObserver is notified on every breaker state change (implemented by observability.Metrics).
+type CircuitObserver interface {
+	RecordCircuitState(provider, state string)
+}
+
+// CircuitBreaker tracks provider health: closed lets calls through, open rejects them
+// until the cooldown elapses, half-open admits one probe whose outcome closes or reopens it.
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	name     string
+	policy   BreakerPolicy
+	observer CircuitObserver
+	now      func() time.Time
+
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker creates a closed breaker; zero policy fields fall back to defaults.
+func NewCircuitBreaker(name string, policy BreakerPolicy, observer CircuitObserver) *CircuitBreaker {
+	b := &CircuitBreaker{
+		name:     name,
+		policy:   policy.withDefaults(),
+		observer: observer,
+		now:      time.Now,
+		state:    CircuitClosed,
+	}
+	if observer != nil {
+		observer.RecordCircuitState(name, string(CircuitClosed))
+	}
+	return b
+}
+
+// State returns the current state, moving an open circuit to half-open once its cooldown elapsed.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	return b.state
+}
+
+// Allow reports whether a call may proceed; in half-open only one probe is admitted at a time.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	switch b.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// Record updates the breaker with the outcome of an allowed call. Only errors that point at
+// provider health (rate limits, overload, timeouts, 5xx, network) count as failures; caller
+// cancellations are ignored.
+func (b *CircuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err != nil && errors.Is(err, context.Canceled) {
+		return
+	}
+	if err == nil || !ClassifyError(err).Retryable() {
+		b.failures = 0
+		b.setState(CircuitClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.policy.FailureThreshold {
+		b.openedAt = b.now()
+		b.setState(CircuitOpen)
+	}
+}
+
+func (b *CircuitBreaker) refresh() {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.policy.Cooldown {
+		b.setState(CircuitHalfOpen)
+	}
+}
+
+func (b *CircuitBreaker) setState(state CircuitState) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	if b.observer != nil {
+		b.observer.RecordCircuitState(b.name, string(state))
+	}
+}
+
+// breakerProvider fails fast while the circuit is open and feeds call outcomes to the breaker.
+type breakerProvider struct {
+	inner   Provider
+	breaker *CircuitBreaker
+}
+
+func (p *breakerProvider) Name() string {
+	return p.inner.Name()
+}
+
+func (p *breakerProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if !p.breaker.Allow() {
+		return ChatResponse{}, fmt.Errorf("%s: %w", p.inner.Name(), ErrCircuitOpen)
+	}
+	resp, err := p.inner.Chat(ctx, req)
+	p.breaker.Record(err)
+	return resp, err
+}
+
+func (p *breakerProvider) Stream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, <-chan error) {
+	ch := make(chan StreamChunk, 16)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+		defer close(errCh)
+
+		if !p.breaker.Allow() {
+			errCh <- fmt.Errorf("%s: %w", p.inner.Name(), ErrCircuitOpen)
+			return
+		}
+		err := p.forward(ctx, req, ch)
+		p.breaker.Record(err)
+		if err != nil {
+			errCh <- err
+		}
+	}()
+
+	return ch, errCh
+}
+
+func (p *breakerProvider) forward(ctx context.Context, req ChatRequest, ch chan<- StreamChunk) error {
+	chunks, errs := p.inner.Stream(ctx, req)
+	for chunk := range chunks {
+		select {
+		case ch <- chunk:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return <-errs
+}
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stateRecorder struct {
+	states []string
+}
+
+func (r *stateRecorder) RecordCircuitState(provider, state string) {
+	r.states = append(r.states, provider+":"+state)
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	rec := &stateRecorder{}
+	b := NewCircuitBreaker("openai", BreakerPolicy{FailureThreshold: 2, Cooldown: time.Minute}, rec)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+
+	unavailable := &StatusError{StatusCode: http.StatusServiceUnavailable}
+	require.True(t, b.Allow())
+	b.Record(unavailable)
+	require.Equal(t, CircuitClosed, b.State())
+
+	// Client errors and cancellations say nothing about provider health.
+	b.Record(&StatusError{StatusCode: http.StatusBadRequest})
+	b.Record(unavailable)
+	b.Record(context.Canceled)
+	require.Equal(t, CircuitClosed, b.State())
+
+	b.Record(unavailable)
+	require.Equal(t, CircuitOpen, b.State())
+	require.False(t, b.Allow())
+
+	now = now.Add(time.Minute)
+	require.Equal(t, CircuitHalfOpen, b.State())
+	require.True(t, b.Allow(), "one probe is admitted")
+	require.False(t, b.Allow(), "concurrent calls wait for the probe")
+	b.Record(unavailable)
+	require.Equal(t, CircuitOpen, b.State())
+
+	now = now.Add(time.Minute)
+	require.True(t, b.Allow())
+	b.Record(nil)
+	require.Equal(t, CircuitClosed, b.State())
+
+	require.Equal(t, []string{
+		"openai:closed", "openai:open", "openai:half_open", "openai:open", "openai:half_open", "openai:closed",
+	}, rec.states)
+}
+
+func TestRegistryCircuitBreakerFailsFast(t *testing.T) {
+	inner := &flakyProvider{errs: []error{&StatusError{StatusCode: http.StatusBadGateway}}}
+	reg := NewRegistry()
+	reg.RegisterProvider("p", inner)
+	reg.RegisterModel("m", ModelRoute{Provider: "p", Model: "x"}, true)
+	require.NoError(t, reg.SetCircuitBreaker("p", BreakerPolicy{FailureThreshold: 1, Cooldown: time.Hour}, nil))
+	require.Error(t, reg.SetCircuitBreaker("missing", BreakerPolicy{}, nil))
+
+	p, _, err := reg.Resolve("m")
+	require.NoError(t, err)
+	require.Equal(t, "flaky", p.Name())
+	require.True(t, reg.Available("m"))
+
+	_, err = p.Chat(context.Background(), ChatRequest{})
+	require.Error(t, err)
+	require.False(t, reg.Available("m"))
+	require.Equal(t, map[string]CircuitState{"p": CircuitOpen}, reg.ProviderStates())
+
+	_, err = collectStream(p.Stream(context.Background(), ChatRequest{}))
+	require.True(t, errors.Is(err, ErrCircuitOpen))
+	require.Equal(t, 1, inner.calls, "open circuit must not reach the provider")
+}
@@ -5,28 +5,58 @@ import (
 
 	"github.com/animus-coder/animus-coder/internal/config"
 	"github.com/animus-coder/animus-coder/internal/llm"
+	llmmock "github.com/animus-coder/animus-coder/internal/llm/mock"
+	llmanthropic "github.com/animus-coder/animus-coder/internal/llm/providers/anthropic"
+	llmgemini "github.com/animus-coder/animus-coder/internal/llm/providers/gemini"
 	llmollama "github.com/animus-coder/animus-coder/internal/llm/providers/ollama"
 	llmopenai "github.com/animus-coder/animus-coder/internal/llm/providers/openai"
+	llmreplay "github.com/animus-coder/animus-coder/internal/llm/providers/replay"
+	"github.com/animus-coder/animus-coder/internal/observability"
 )
 
 // BuildRegistryFromConfig constructs a registry and providers from config.
-func BuildRegistryFromConfig(cfg *config.Config) (*llm.Registry, error) {
+// Every provider is wrapped with its retry policy and guarded by a circuit breaker; metrics may be nil.
+func BuildRegistryFromConfig(cfg *config.Config, metrics *observability.Metrics) (*llm.Registry, error) {
 	reg := llm.NewRegistry()
 
+	built := make(map[string]llm.Provider, len(cfg.Providers))
 	for name, pCfg := range cfg.Providers {
+		if pCfg.Type == "replay" {
+			continue
+		}
 		p, err := buildProvider(name, pCfg)
 		if err != nil {
 			return nil, err
 		}
+		built[name] = llm.NewRetryProvider(p, retryPolicy(pCfg.Retry), retryObserver(metrics))
+		reg.RegisterProvider(name, built[name])
+		policy := llm.BreakerPolicy{FailureThreshold: pCfg.Breaker.FailureThreshold, Cooldown: pCfg.Breaker.Cooldown}
+		if err := reg.SetCircuitBreaker(name, policy, circuitObserver(metrics)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Replay providers are built last because record mode wraps another provider.
+	for name, pCfg := range cfg.Providers {
+		if pCfg.Type != "replay" {
+			continue
+		}
+		p, err := llmreplay.NewProvider(name, pCfg.Replay.Cassette, llmreplay.Mode(pCfg.Replay.Mode), built[pCfg.Replay.Upstream])
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", name, err)
+		}
 		reg.RegisterProvider(name, p)
 	}
 
 	for name, mCfg := range cfg.Models {
 		reg.RegisterModel(name, llm.ModelRoute{
-			Provider:    mCfg.Provider,
-			Model:       mCfg.Model,
-			Temperature: mCfg.Temperature,
-			MaxTokens:   mCfg.MaxTokens,
+			Provider:          mCfg.Provider,
+			Model:             mCfg.Model,
+			Temperature:       mCfg.Temperature,
+			MaxTokens:         mCfg.MaxTokens,
+			InputCostPerMTok:  mCfg.InputCostPerMTok,
+			OutputCostPerMTok: mCfg.OutputCostPerMTok,
+			ContextWindow:     mCfg.ContextWindow,
 		}, mCfg.Default)
 		if mCfg.Expensive {
 			reg.MarkExpensive(name, true)
@@ -46,7 +76,40 @@ func buildProvider(name string, cfg config.ProviderConfig) (llm.Provider, error)
 		return llmopenai.NewProvider(name, cfg.BaseURL, cfg.APIKey, cfg.Timeout), nil
 	case "ollama":
 		return llmollama.NewProvider(name, cfg.BaseURL, cfg.Timeout), nil
+	case "anthropic":
+		return llmanthropic.NewProvider(name, cfg.BaseURL, cfg.APIKey, cfg.Timeout), nil
+	case "gemini":
+		return llmgemini.NewProvider(name, cfg.BaseURL, cfg.APIKey, cfg.Timeout), nil
+	case "mock":
+		sc, err := llmmock.LoadScenario(cfg.Scenario)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", name, err)
+		}
+		return llmmock.NewScenarioProvider(name, sc), nil
 	default:
 		return nil, fmt.Errorf("unknown provider type %q for provider %s", cfg.Type, name)
 	}
 }
+
+func retryPolicy(cfg config.RetryConfig) llm.RetryPolicy {
+	return llm.RetryPolicy{
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   cfg.InitialBackoff,
+		MaxDelay:    cfg.MaxBackoff,
+	}
+}
+
+// retryObserver and circuitObserver avoid handing a typed nil *Metrics to llm as a non-nil interface.
+func retryObserver(metrics *observability.Metrics) llm.RetryObserver {
+	if metrics == nil {
+		return nil
+	}
+	return metrics
+}
+
+func circuitObserver(metrics *observability.Metrics) llm.CircuitObserver {
+	if metrics == nil {
+		return nil
+	}
+	return metrics
+}
@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatusError is returned by HTTP providers when the API answers with a non-2xx status.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	// RetryAfter is the server-requested delay parsed from the Retry-After header (zero if absent).
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// NewStatusError builds a StatusError from a failed response, consuming its body.
+func NewStatusError(provider string, res *http.Response) *StatusError {
+	b, _ := io.ReadAll(res.Body)
+	return &StatusError{
+		Provider:   provider,
+		StatusCode: res.StatusCode,
+		Body:       string(b),
+		RetryAfter: ParseRetryAfter(res.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// ParseRetryAfter interprets a Retry-After header given either as delay seconds or an HTTP date.
+func ParseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// ErrorClass groups provider failures by how they should be handled.
+type ErrorClass string
+
+const (
+	ErrorRateLimited ErrorClass = "rate_limit"
+	ErrorOverloaded  ErrorClass = "overloaded"
+	ErrorTimeout     ErrorClass = "timeout"
+	ErrorServer      ErrorClass = "server"
+	ErrorNetwork     ErrorClass = "network"
+	ErrorClient      ErrorClass = "client"
+	ErrorUnknown     ErrorClass = "unknown"
+)
+
+// Retryable reports whether a failure of this class is worth another attempt.
+func (c ErrorClass) Retryable() bool {
+	switch c {
+	case ErrorRateLimited, ErrorOverloaded, ErrorTimeout, ErrorServer, ErrorNetwork:
+		return true
+	default:
+		return false
+	}
+}
+
+// ClassifyError maps a provider error to an ErrorClass.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch code := statusErr.StatusCode; {
+		case code == http.StatusTooManyRequests:
+			return ErrorRateLimited
+		case code == http.StatusServiceUnavailable || code == 529:
+			return ErrorOverloaded
+		case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+			return ErrorTimeout
+		case code >= 500:
+			return ErrorServer
+		default:
+			return ErrorClient
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorTimeout
+		}
+		return ErrorNetwork
+	}
+
+	// In-stream errors (e.g. Anthropic's "overloaded_error" event) only carry a message.
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "overloaded"):
+		return ErrorOverloaded
+	case strings.Contains(msg, "rate_limit") || strings.Contains(msg, "rate limit"):
+		return ErrorRateLimited
+	}
+	return ErrorUnknown
+}
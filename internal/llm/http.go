@@ -0,0 +1,15 @@
+package llm
+
+import (
+	"net/http"
+	"time"
+)
+
+// NewHTTPClient returns the HTTP client used by provider APIs. timeout bounds the wait
+// for the response headers only: a streamed body may take as long as generation needs,
+// and the caller's context still cancels the whole exchange.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+	return &http.Client{Transport: transport}
+}
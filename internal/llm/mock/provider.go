@@ -33,12 +33,22 @@ func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (llm.ChatRespo
 	}, nil
 }
 
+// Stream emits StreamChunks; when none are configured it replays Chat as a single chunk.
 func (p *Provider) Stream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, <-chan error) {
-	ch := make(chan llm.StreamChunk, len(p.StreamChunks))
+	ch := make(chan llm.StreamChunk, len(p.StreamChunks)+1)
 	errCh := make(chan error, 1)
 	go func() {
 		defer close(ch)
 		defer close(errCh)
+		if len(p.StreamChunks) == 0 && p.StreamErr == nil {
+			resp, err := p.Chat(ctx, req)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			ch <- llm.StreamChunk{Content: resp.Message.Content, ToolCalls: resp.Message.ToolCalls, FinishReason: resp.FinishReason, Usage: resp.Usage}
+			return
+		}
 		for _, c := range p.StreamChunks {
 			ch <- c
 		}
@@ -0,0 +1,281 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
+)
+
+// Roles recognised in scenarios, llm.AgentRoles; a request's role is llm.ChatRequest.Role.
+const (
+	RolePlanner    = llm.AgentRolePlanner
+	RoleReplanner  = llm.AgentRoleReplanner
+	RoleCoder      = llm.AgentRoleCoder
+	RoleCritic     = llm.AgentRoleCritic
+	RoleClassifier = llm.AgentRoleClassifier
+	RoleSummarizer = llm.AgentRoleSummarizer
+)
+
+// Scenario scripts a ScenarioProvider: each role answers its calls with the next
+// step in order, and the last step repeats once the list is exhausted.
+type Scenario struct {
+	// Latency is added to every step that does not set its own.
+	Latency time.Duration     `yaml:"latency"`
+	Roles   map[string][]Step `yaml:"roles"`
+}
+
+// Step is one scripted model answer.
+type Step struct {
+	Content      string         `yaml:"content"`
+	ToolCalls    []StepToolCall `yaml:"tool_calls"`
+	FinishReason string         `yaml:"finish_reason"`
+	Usage        StepUsage      `yaml:"usage"`
+	Latency      time.Duration  `yaml:"latency"`
+	// Error makes the step fail; with Status it is returned as an HTTP status error so
+	// retries, circuit breakers and fallbacks treat it like a real provider failure.
+	Error  string `yaml:"error"`
+	Status int    `yaml:"status"`
+}
+
+// StepToolCall is a scripted native tool call.
+type StepToolCall struct {
+	ID   string                 `yaml:"id"`
+	Name string                 `yaml:"name"`
+	Args map[string]interface{} `yaml:"args"`
+}
+
+// StepUsage is scripted token accounting; total defaults to prompt+completion.
+type StepUsage struct {
+	PromptTokens     int `yaml:"prompt_tokens"`
+	CompletionTokens int `yaml:"completion_tokens"`
+	TotalTokens      int `yaml:"total_tokens"`
+}
+
+// LoadScenario reads and validates a YAML scenario file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario: %w", err)
+	}
+	var sc Scenario
+	if err := yaml.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("decode scenario %s: %w", path, err)
+	}
+	if err := sc.Validate(); err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+// Validate checks role names and step consistency.
+func (s *Scenario) Validate() error {
+	if len(s.Roles) == 0 {
+		return fmt.Errorf("at least one role must be scripted")
+	}
+	for role, steps := range s.Roles {
+		if !slices.Contains(llm.AgentRoles, role) {
+			return fmt.Errorf("unknown role %q (want one of %s)", role, strings.Join(llm.AgentRoles, ", "))
+		}
+		if len(steps) == 0 {
+			return fmt.Errorf("role %q has no steps", role)
+		}
+		for i, st := range steps {
+			if st.Status != 0 && st.Error == "" {
+				return fmt.Errorf("role %q step %d: status requires error", role, i+1)
+			}
+			for _, tc := range st.ToolCalls {
+				if tc.Name == "" {
+					return fmt.Errorf("role %q step %d: tool call without name", role, i+1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ScenarioProvider is an llm.Provider that plays a Scenario, letting the daemon run
+// fully offline for demos, load tests and reproducing runner behaviour.
+type ScenarioProvider struct {
+	name     string
+	scenario *Scenario
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// NewScenarioProvider creates a provider for a loaded scenario.
+func NewScenarioProvider(name string, sc *Scenario) *ScenarioProvider {
+	return &ScenarioProvider{name: name, scenario: sc, calls: make(map[string]int)}
+}
+
+// Name returns provider identifier.
+func (p *ScenarioProvider) Name() string {
+	return p.name
+}
+
+// Chat returns the next scripted step for the request's role.
+func (p *ScenarioProvider) Chat(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+	step, callID, err := p.next(ctx, req)
+	if err != nil {
+		return llm.ChatResponse{}, err
+	}
+	return llm.ChatResponse{
+		Message: llm.ChatMessage{
+			Role:      llm.RoleAssistant,
+			Content:   step.Content,
+			ToolCalls: step.toolCalls(callID),
+		},
+		FinishReason: step.FinishReason,
+		Usage:        step.usage(),
+		ProviderName: p.name,
+		Model:        req.Model,
+	}, nil
+}
+
+// Stream emits the scripted content word by word, then a final chunk with tool calls,
+// finish reason and usage.
+func (p *ScenarioProvider) Stream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, <-chan error) {
+	ch := make(chan llm.StreamChunk, 16)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+		defer close(errCh)
+
+		step, callID, err := p.next(ctx, req)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		chunks := splitWords(step.Content)
+		chunks = append(chunks, llm.StreamChunk{
+			ToolCalls:    step.toolCalls(callID),
+			FinishReason: step.FinishReason,
+			Usage:        step.usage(),
+		})
+		for _, c := range chunks {
+			select {
+			case ch <- c:
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return ch, errCh
+}
+
+// Calls returns how many steps each role has consumed.
+func (p *ScenarioProvider) Calls() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make(map[string]int, len(p.calls))
+	for role, n := range p.calls {
+		out[role] = n
+	}
+	return out
+}
+
+// next picks the role's next step, waits out its latency and returns its error, if any.
+// The returned prefix is unique per call and used for default tool call IDs.
+func (p *ScenarioProvider) next(ctx context.Context, req llm.ChatRequest) (Step, string, error) {
+	role := RequestRole(req)
+	steps := p.scenario.Roles[role]
+	if len(steps) == 0 && role == RoleReplanner {
+		// Plan revisions fall back to the planner script.
+		role, steps = RolePlanner, p.scenario.Roles[RolePlanner]
+	}
+	if len(steps) == 0 {
+		return Step{}, "", fmt.Errorf("mock scenario: no steps scripted for role %q", role)
+	}
+
+	p.mu.Lock()
+	call := p.calls[role]
+	p.calls[role]++
+	p.mu.Unlock()
+	step := steps[min(call, len(steps)-1)]
+	prefix := fmt.Sprintf("call_%s_%d", role, call+1)
+
+	latency := step.Latency
+	if latency == 0 {
+		latency = p.scenario.Latency
+	}
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return Step{}, "", ctx.Err()
+		}
+	}
+
+	if step.Error != "" {
+		if step.Status != 0 {
+			return Step{}, "", &llm.StatusError{Provider: p.name, StatusCode: step.Status, Body: step.Error}
+		}
+		return Step{}, "", fmt.Errorf("mock scenario: %s", step.Error)
+	}
+	return step, prefix, nil
+}
+
+// RequestRole returns the agent role that issued a request; requests without one are
+// treated as coder calls.
+func RequestRole(req llm.ChatRequest) string {
+	if req.Role == "" {
+		return RoleCoder
+	}
+	return req.Role
+}
+
+func (s Step) toolCalls(prefix string) []llm.ToolCall {
+	var out []llm.ToolCall
+	for i, tc := range s.ToolCalls {
+		args, err := json.Marshal(tc.Args)
+		if err != nil || tc.Args == nil {
+			args = []byte("{}")
+		}
+		id := tc.ID
+		if id == "" {
+			id = fmt.Sprintf("%s_%d", prefix, i+1)
+		}
+		out = append(out, llm.ToolCall{
+			ID:       id,
+			Type:     "function",
+			Function: llm.ToolFunctionCall{Name: tc.Name, Arguments: args},
+		})
+	}
+	return out
+}
+
+func (s Step) usage() llm.Usage {
+	total := s.Usage.TotalTokens
+	if total == 0 {
+		total = s.Usage.PromptTokens + s.Usage.CompletionTokens
+	}
+	return llm.Usage{PromptTokens: s.Usage.PromptTokens, CompletionTokens: s.Usage.CompletionTokens, TotalTokens: total}
+}
+
+// splitWords breaks content into word chunks that keep their trailing whitespace.
+func splitWords(content string) []llm.StreamChunk {
+	var chunks []llm.StreamChunk
+	start := 0
+	for i := 1; i <= len(content); i++ {
+		if i == len(content) || (content[i-1] == ' ' || content[i-1] == '\n') && content[i] != ' ' && content[i] != '\n' {
+			chunks = append(chunks, llm.StreamChunk{Content: content[start:i]})
+			start = i
+		}
+	}
+	return chunks
+}
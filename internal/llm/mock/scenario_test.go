@@ -0,0 +1,111 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
+)
+
+func TestScenarioProviderPlaysStepsPerRole(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+roles:
+  planner:
+    - content: "1) read"
+  coder:
+    - error: overloaded
+      status: 529
+    - content: "reading file"
+      tool_calls:
+        - name: fs.read_file
+          args: {path: a.go}
+      finish_reason: tool_calls
+      usage: {prompt_tokens: 10, completion_tokens: 4}
+    - content: "[done]"
+      finish_reason: stop
+      latency: 5ms
+`), 0o644))
+
+	sc, err := LoadScenario(path)
+	require.NoError(t, err)
+	p := NewScenarioProvider("offline", sc)
+
+	// The role comes from the request, never from prompt text.
+	coderReq := llm.ChatRequest{Role: RoleCoder, Messages: []llm.ChatMessage{{Role: llm.RoleSystem, Content: "You are MyCodex planning assistant."}}}
+	planReq := llm.ChatRequest{Role: RolePlanner, Messages: []llm.ChatMessage{{Role: llm.RoleSystem, Content: "Draft a plan."}}}
+
+	_, err = p.Chat(context.Background(), coderReq)
+	var statusErr *llm.StatusError
+	require.True(t, errors.As(err, &statusErr))
+	require.Equal(t, 529, statusErr.StatusCode)
+	require.Equal(t, llm.ErrorOverloaded, llm.ClassifyError(err))
+
+	plan, err := p.Chat(context.Background(), planReq)
+	require.NoError(t, err)
+	require.Equal(t, "1) read", plan.Message.Content)
+
+	resp, err := p.Chat(context.Background(), coderReq)
+	require.NoError(t, err)
+	require.Equal(t, "tool_calls", resp.FinishReason)
+	require.Equal(t, llm.Usage{PromptTokens: 10, CompletionTokens: 4, TotalTokens: 14}, resp.Usage)
+	require.Len(t, resp.Message.ToolCalls, 1)
+	require.Equal(t, "call_coder_2_1", resp.Message.ToolCalls[0].ID)
+	require.JSONEq(t, `{"path":"a.go"}`, string(resp.Message.ToolCalls[0].Function.Arguments))
+
+	start := time.Now()
+	ch, errCh := p.Stream(context.Background(), coderReq)
+	var chunks []llm.StreamChunk
+	for c := range ch {
+		chunks = append(chunks, c)
+	}
+	require.NoError(t, <-errCh)
+	require.GreaterOrEqual(t, time.Since(start), 5*time.Millisecond)
+	require.Equal(t, "[done]", chunks[0].Content)
+	require.Equal(t, "stop", chunks[len(chunks)-1].FinishReason)
+
+	// The last step repeats once the script is exhausted.
+	resp, err = p.Chat(context.Background(), coderReq)
+	require.NoError(t, err)
+	require.Equal(t, "[done]", resp.Message.Content)
+	require.Equal(t, map[string]int{RolePlanner: 1, RoleCoder: 4}, p.Calls())
+
+	// Plan revisions use the planner script unless the replanner role is scripted.
+	replan, err := p.Chat(context.Background(), llm.ChatRequest{Role: RoleReplanner})
+	require.NoError(t, err)
+	require.Equal(t, "1) read", replan.Message.Content)
+
+	// Side calls never consume coder steps.
+	_, err = p.Chat(context.Background(), llm.ChatRequest{Role: RoleSummarizer, Messages: coderReq.Messages})
+	require.ErrorContains(t, err, `no steps scripted for role "summarizer"`)
+	_, err = p.Chat(context.Background(), llm.ChatRequest{Role: RoleCritic})
+	require.ErrorContains(t, err, `no steps scripted for role "critic"`)
+	require.Equal(t, 4, p.Calls()[RoleCoder])
+}
+
+func TestLoadScenarioValidates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("roles:\n  reviewer:\n    - content: hi\n"), 0o644))
+	_, err := LoadScenario(path)
+	require.ErrorContains(t, err, `unknown role "reviewer"`)
+
+	sc, err := LoadScenario(filepath.Join("..", "..", "..", "configs", "scenario.example.yaml"))
+	require.NoError(t, err)
+	require.Len(t, sc.Roles[RoleCoder], 3)
+}
+
+func TestSplitWordsKeepsWhitespace(t *testing.T) {
+	var out string
+	chunks := splitWords("hello  big\nworld")
+	for _, c := range chunks {
+		out += c.Content
+	}
+	require.Equal(t, "hello  big\nworld", out)
+	require.Len(t, chunks, 3)
+}
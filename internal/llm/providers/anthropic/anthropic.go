@@ -0,0 +1,437 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
+	"github.com/animus-coder/animus-coder/internal/llm/sse"
+)
+
+const (
+	apiVersion = "2023-06-01"
+	// defaultMaxTokens is used when the request leaves max_tokens unset; the
+	// Messages API requires it.
+	defaultMaxTokens = 4096
+)
+
+// Provider implements the Anthropic Messages API.
+type Provider struct {
+	name    string
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+// NewProvider constructs an Anthropic provider.
+func NewProvider(name, baseURL, apiKey string, timeout time.Duration) *Provider {
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com"
+	}
+	if timeout == 0 {
+		timeout = 60 * time.Second
+	}
+
+	return &Provider{
+		name:    name,
+		client:  llm.NewHTTPClient(timeout),
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+	}
+}
+
+// Name returns provider identifier.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Chat executes a non-streaming Messages API call.
+func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+	model := req.Model
+	if model == "" {
+		return llm.ChatResponse{}, fmt.Errorf("model is required")
+	}
+
+	res, err := p.send(ctx, newMessagesRequest(req, false))
+	if err != nil {
+		return llm.ChatResponse{}, err
+	}
+	defer res.Body.Close()
+
+	var resp messagesResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return llm.ChatResponse{}, fmt.Errorf("decode response: %w", err)
+	}
+
+	msg := llm.ChatMessage{Role: llm.RoleAssistant}
+	var text strings.Builder
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			msg.ToolCalls = append(msg.ToolCalls, toToolCall(block.ID, block.Name, block.Input))
+		}
+	}
+	msg.Content = text.String()
+
+	return llm.ChatResponse{
+		Message:      msg,
+		FinishReason: finishReason(resp.StopReason),
+		Usage:        resp.Usage.toUsage(),
+		RawResponse:  resp,
+		ProviderName: p.name,
+		Model:        model,
+	}, nil
+}
+
+// Stream decodes the Messages API SSE stream, emitting one chunk per text delta.
+// Tool-use blocks are assembled and returned on the final chunk with the stop
+// reason and usage.
+func (p *Provider) Stream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, <-chan error) {
+	ch := make(chan llm.StreamChunk, 16)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+		defer close(errCh)
+
+		if err := p.stream(ctx, req, ch); err != nil {
+			errCh <- err
+		}
+	}()
+
+	return ch, errCh
+}
+
+func (p *Provider) stream(ctx context.Context, req llm.ChatRequest, ch chan<- llm.StreamChunk) error {
+	if req.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+
+	res, err := p.send(ctx, newMessagesRequest(req, true))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var (
+		final  llm.StreamChunk
+		usage  apiUsage
+		blocks = map[int]*contentBlock{}
+		order  []int
+		reader = sse.NewReader(res.Body)
+	)
+	for {
+		evt, err := reader.Next()
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("anthropic: stream ended before message_stop: %w", io.ErrUnexpectedEOF)
+			}
+			return fmt.Errorf("anthropic: read stream: %w", err)
+		}
+
+		var payload streamEvent
+		if err := json.Unmarshal([]byte(evt.Data), &payload); err != nil {
+			return fmt.Errorf("anthropic: decode stream event: %w", err)
+		}
+		if payload.Type == "" {
+			payload.Type = evt.Name
+		}
+
+		switch payload.Type {
+		case "message_start":
+			usage.InputTokens = payload.Message.Usage.InputTokens
+		case "content_block_start":
+			block := payload.ContentBlock
+			blocks[payload.Index] = &block
+			order = append(order, payload.Index)
+		case "content_block_delta":
+			switch payload.Delta.Type {
+			case "text_delta":
+				if payload.Delta.Text == "" {
+					continue
+				}
+				select {
+				case ch <- llm.StreamChunk{Content: payload.Delta.Text}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			case "input_json_delta":
+				if block, ok := blocks[payload.Index]; ok {
+					block.partialJSON += payload.Delta.PartialJSON
+				}
+			}
+		case "message_delta":
+			final.FinishReason = finishReason(payload.Delta.StopReason)
+			usage.OutputTokens = payload.Usage.OutputTokens
+		case "message_stop":
+			for _, idx := range order {
+				block := blocks[idx]
+				if block.Type != "tool_use" {
+					continue
+				}
+				input := json.RawMessage(block.partialJSON)
+				if strings.TrimSpace(block.partialJSON) == "" {
+					input = block.Input
+				}
+				final.ToolCalls = append(final.ToolCalls, toToolCall(block.ID, block.Name, input))
+			}
+			final.Usage = usage.toUsage()
+			select {
+			case ch <- final:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case "error":
+			return fmt.Errorf("anthropic: stream error: %s: %s", payload.Error.Type, payload.Error.Message)
+		}
+	}
+}
+
+func (p *Provider) send(ctx context.Context, body messagesRequest) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("anthropic-version", apiVersion)
+	if body.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	if p.apiKey != "" {
+		httpReq.Header.Set("x-api-key", p.apiKey)
+	}
+
+	res, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		return nil, llm.NewStatusError("anthropic", res)
+	}
+	return res, nil
+}
+
+type messagesRequest struct {
+	Model       string       `json:"model"`
+	System      string       `json:"system,omitempty"`
+	Messages    []apiMessage `json:"messages"`
+	Tools       []apiTool    `json:"tools,omitempty"`
+	MaxTokens   int          `json:"max_tokens"`
+	Temperature float64      `json:"temperature,omitempty"`
+	Stream      bool         `json:"stream,omitempty"`
+}
+
+type apiMessage struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+
+	partialJSON string
+}
+
+type apiTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+type apiUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (u apiUsage) toUsage() llm.Usage {
+	return llm.Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+type messagesResponse struct {
+	ID         string         `json:"id"`
+	Role       string         `json:"role"`
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      apiUsage       `json:"usage"`
+}
+
+type streamEvent struct {
+	Type         string       `json:"type"`
+	Index        int          `json:"index"`
+	ContentBlock contentBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Message struct {
+		Usage apiUsage `json:"usage"`
+	} `json:"message"`
+	Usage apiUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func newMessagesRequest(req llm.ChatRequest, stream bool) messagesRequest {
+	system, messages := toAPIMessages(req.Messages)
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	return messagesRequest{
+		Model:       req.Model,
+		System:      system,
+		Messages:    messages,
+		Tools:       toAPITools(req.Tools),
+		MaxTokens:   maxTokens,
+		Temperature: req.Temperature,
+		Stream:      stream,
+	}
+}
+
+// toAPIMessages lifts system messages into the separate system field and folds
+// the rest into strictly alternating user/assistant turns. Assistant context that
+// precedes the first user turn (plans, reflections) is moved into the system
+// prompt because the API requires the conversation to start with a user turn.
+func toAPIMessages(msgs []llm.ChatMessage) (string, []apiMessage) {
+	var (
+		system []string
+		out    []apiMessage
+	)
+	for _, m := range msgs {
+		role := "user"
+		var blocks []contentBlock
+		switch m.Role {
+		case llm.RoleSystem:
+			if strings.TrimSpace(m.Content) != "" {
+				system = append(system, m.Content)
+			}
+			continue
+		case llm.RoleAssistant:
+			role = "assistant"
+			if strings.TrimSpace(m.Content) != "" {
+				blocks = append(blocks, contentBlock{Type: "text", Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				input := tc.Function.Arguments
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, contentBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  llm.FunctionName(tc.Function.Name),
+					Input: input,
+				})
+			}
+		case llm.RoleTool:
+			blocks = append(blocks, contentBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content})
+		default:
+			if strings.TrimSpace(m.Content) != "" {
+				blocks = append(blocks, contentBlock{Type: "text", Text: m.Content})
+			}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if role == "assistant" && len(out) == 0 {
+			for _, b := range blocks {
+				if b.Type == "text" {
+					system = append(system, b.Text)
+				}
+			}
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content = append(out[n-1].Content, blocks...)
+			continue
+		}
+		out = append(out, apiMessage{Role: role, Content: blocks})
+	}
+	return strings.Join(system, "\n\n"), out
+}
+
+func toAPITools(defs []llm.ToolDefinition) []apiTool {
+	if len(defs) == 0 {
+		return nil
+	}
+	out := make([]apiTool, 0, len(defs))
+	for _, d := range defs {
+		schema := d.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object"}
+		}
+		out = append(out, apiTool{
+			Name:        llm.FunctionName(d.Name),
+			Description: d.Description,
+			InputSchema: schema,
+		})
+	}
+	return out
+}
+
+// toToolCall keeps invalid input as a JSON string so callers can report it.
+func toToolCall(id, name string, input json.RawMessage) llm.ToolCall {
+	switch {
+	case len(bytes.TrimSpace(input)) == 0:
+		input = json.RawMessage("{}")
+	case !json.Valid(input):
+		input, _ = json.Marshal(string(input))
+	}
+	return llm.ToolCall{
+		ID:   id,
+		Type: "function",
+		Function: llm.ToolFunctionCall{
+			Name:      llm.ToolName(name),
+			Arguments: input,
+		},
+	}
+}
+
+// finishReason maps Anthropic stop reasons onto the OpenAI-style values the
+// runner understands.
+func finishReason(stop string) string {
+	switch stop {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return stop
+	}
+}
@@ -0,0 +1,168 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
+)
+
+func TestChatMapsMessagesAndToolUse(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/messages", r.URL.Path)
+		require.Equal(t, "key", r.Header.Get("x-api-key"))
+		require.Equal(t, apiVersion, r.Header.Get("anthropic-version"))
+
+		var body messagesRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, "claude-test", body.Model)
+		require.Equal(t, defaultMaxTokens, body.MaxTokens)
+		require.Equal(t, "be brief\n\nPlanned steps:\n1) read", body.System)
+		require.Len(t, body.Tools, 1)
+		require.Equal(t, "fs__read_file", body.Tools[0].Name)
+
+		// user, assistant(text+tool_use), user(tool_result+text) after folding.
+		require.Len(t, body.Messages, 3)
+		require.Equal(t, "user", body.Messages[0].Role)
+		require.Equal(t, "assistant", body.Messages[1].Role)
+		require.Equal(t, "tool_use", body.Messages[1].Content[1].Type)
+		require.Equal(t, "fs__read_file", body.Messages[1].Content[1].Name)
+		require.Equal(t, "user", body.Messages[2].Role)
+		require.Equal(t, "tool_result", body.Messages[2].Content[0].Type)
+		require.Equal(t, "toolu_1", body.Messages[2].Content[0].ToolUseID)
+		require.Equal(t, "text", body.Messages[2].Content[1].Type)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{
+			"id": "msg_1", "role": "assistant", "stop_reason": "tool_use",
+			"content": [
+				{"type": "text", "text": "Checking."},
+				{"type": "tool_use", "id": "toolu_2", "name": "terminal__exec", "input": {"command": "ls"}}
+			],
+			"usage": {"input_tokens": 12, "output_tokens": 5}
+		}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := NewProvider("anthropic", srv.URL, "key", 0)
+	resp, err := p.Chat(context.Background(), llm.ChatRequest{
+		Model: "claude-test",
+		Messages: []llm.ChatMessage{
+			{Role: llm.RoleSystem, Content: "be brief"},
+			{Role: llm.RoleAssistant, Content: "Planned steps:\n1) read"},
+			{Role: llm.RoleUser, Content: "read a.go"},
+			{Role: llm.RoleAssistant, Content: "Reading.", ToolCalls: []llm.ToolCall{{
+				ID:       "toolu_1",
+				Function: llm.ToolFunctionCall{Name: "fs.read_file", Arguments: json.RawMessage(`{"path":"a.go"}`)},
+			}}},
+			{Role: llm.RoleTool, Content: "package a", ToolCallID: "toolu_1"},
+			{Role: llm.RoleUser, Content: "continue"},
+		},
+		Tools: []llm.ToolDefinition{{Name: "fs.read_file", Parameters: map[string]interface{}{"type": "object"}}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Checking.", resp.Message.Content)
+	require.Equal(t, "tool_calls", resp.FinishReason)
+	require.Equal(t, llm.Usage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}, resp.Usage)
+	require.Len(t, resp.Message.ToolCalls, 1)
+	require.Equal(t, "toolu_2", resp.Message.ToolCalls[0].ID)
+	require.Equal(t, "terminal.exec", resp.Message.ToolCalls[0].Function.Name)
+	require.JSONEq(t, `{"command":"ls"}`, string(resp.Message.ToolCalls[0].Function.Arguments))
+}
+
+func TestStreamDecodesEvents(t *testing.T) {
+	t.Parallel()
+
+	srv := newSSEServer(t, []string{
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":9,\"output_tokens\":1}}}",
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}",
+		"event: ping\ndata: {\"type\":\"ping\"}",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi \"}}",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"there\"}}",
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}",
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"fs__read_file\",\"input\":{}}}",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"path\\\":\"}}",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"\\\"a.go\\\"}\"}}",
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":1}",
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":15}}",
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}",
+	})
+
+	p := NewProvider("anthropic", srv.URL, "", 0)
+	chunks, err := collect(p.Stream(context.Background(), llm.ChatRequest{
+		Model:    "claude-test",
+		Messages: []llm.ChatMessage{{Role: llm.RoleUser, Content: "hi"}},
+	}))
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+	require.Equal(t, "Hi ", chunks[0].Content)
+	require.Equal(t, "there", chunks[1].Content)
+
+	final := chunks[2]
+	require.Equal(t, "tool_calls", final.FinishReason)
+	require.Equal(t, llm.Usage{PromptTokens: 9, CompletionTokens: 15, TotalTokens: 24}, final.Usage)
+	require.Len(t, final.ToolCalls, 1)
+	require.Equal(t, "fs.read_file", final.ToolCalls[0].Function.Name)
+	require.JSONEq(t, `{"path":"a.go"}`, string(final.ToolCalls[0].Function.Arguments))
+}
+
+func TestStreamErrorEvent(t *testing.T) {
+	t.Parallel()
+
+	srv := newSSEServer(t, []string{
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":1}}}",
+		"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}",
+	})
+
+	p := NewProvider("anthropic", srv.URL, "", 0)
+	_, err := collect(p.Stream(context.Background(), llm.ChatRequest{Model: "claude-test"}))
+	require.ErrorContains(t, err, "overloaded_error")
+}
+
+func TestStreamTruncated(t *testing.T) {
+	t.Parallel()
+
+	srv := newSSEServer(t, []string{
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"cut\"}}",
+	})
+
+	p := NewProvider("anthropic", srv.URL, "", 0)
+	chunks, err := collect(p.Stream(context.Background(), llm.ChatRequest{Model: "claude-test"}))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Len(t, chunks, 1)
+}
+
+func newSSEServer(t *testing.T, frames []string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body messagesRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.True(t, body.Stream)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, f := range frames {
+			_, _ = io.WriteString(w, f+"\n\n")
+			flusher.Flush()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func collect(ch <-chan llm.StreamChunk, errCh <-chan error) ([]llm.StreamChunk, error) {
+	var chunks []llm.StreamChunk
+	for c := range ch {
+		chunks = append(chunks, c)
+	}
+	return chunks, <-errCh
+}
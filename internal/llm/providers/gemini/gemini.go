@@ -0,0 +1,418 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
+	"github.com/animus-coder/animus-coder/internal/llm/sse"
+)
+
+// Provider implements the Gemini generateContent REST API.
+type Provider struct {
+	name    string
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+// NewProvider constructs a Gemini provider.
+func NewProvider(name, baseURL, apiKey string, timeout time.Duration) *Provider {
+	if baseURL == "" {
+		baseURL = "https://generativelanguage.googleapis.com"
+	}
+	if timeout == 0 {
+		timeout = 60 * time.Second
+	}
+
+	return &Provider{
+		name:    name,
+		client:  llm.NewHTTPClient(timeout),
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+	}
+}
+
+// Name returns provider identifier.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Chat executes a non-streaming generateContent call.
+func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+	model := req.Model
+	if model == "" {
+		return llm.ChatResponse{}, fmt.Errorf("model is required")
+	}
+
+	res, err := p.send(ctx, model, "generateContent", newGenerateRequest(req))
+	if err != nil {
+		return llm.ChatResponse{}, err
+	}
+	defer res.Body.Close()
+
+	var resp generateResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return llm.ChatResponse{}, fmt.Errorf("decode response: %w", err)
+	}
+	if len(resp.Candidates) == 0 {
+		return llm.ChatResponse{}, fmt.Errorf("gemini: empty candidates%s", resp.blockReason())
+	}
+
+	cand := resp.Candidates[0]
+	msg := llm.ChatMessage{Role: llm.RoleAssistant}
+	var text strings.Builder
+	for _, part := range cand.Content.Parts {
+		text.WriteString(part.Text)
+		if part.FunctionCall != nil {
+			msg.ToolCalls = append(msg.ToolCalls, toToolCall(*part.FunctionCall, len(msg.ToolCalls)))
+		}
+	}
+	msg.Content = text.String()
+
+	return llm.ChatResponse{
+		Message:      msg,
+		FinishReason: finishReason(cand.FinishReason, len(msg.ToolCalls) > 0),
+		Usage:        resp.UsageMetadata.toUsage(),
+		RawResponse:  resp,
+		ProviderName: p.name,
+		Model:        model,
+	}, nil
+}
+
+// Stream uses streamGenerateContent with alt=sse, emitting one chunk per text
+// part. Function calls, finish reason and usage are reported on the final chunk.
+func (p *Provider) Stream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, <-chan error) {
+	ch := make(chan llm.StreamChunk, 16)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+		defer close(errCh)
+
+		if err := p.stream(ctx, req, ch); err != nil {
+			errCh <- err
+		}
+	}()
+
+	return ch, errCh
+}
+
+func (p *Provider) stream(ctx context.Context, req llm.ChatRequest, ch chan<- llm.StreamChunk) error {
+	if req.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+
+	res, err := p.send(ctx, req.Model, "streamGenerateContent", newGenerateRequest(req))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var (
+		final  llm.StreamChunk
+		reason string
+		reader = sse.NewReader(res.Body)
+	)
+	for {
+		evt, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			// The stream has no terminator; it is complete once a candidate
+			// reported a finish reason.
+			if reason == "" {
+				return fmt.Errorf("gemini: stream ended before completion: %w", io.ErrUnexpectedEOF)
+			}
+			final.FinishReason = finishReason(reason, len(final.ToolCalls) > 0)
+			select {
+			case ch <- final:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return fmt.Errorf("gemini: read stream: %w", err)
+		}
+
+		var chunk generateResponse
+		if err := json.Unmarshal([]byte(evt.Data), &chunk); err != nil {
+			return fmt.Errorf("gemini: decode stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("gemini: stream error: %s", chunk.Error.Message)
+		}
+		if chunk.UsageMetadata.TotalTokenCount > 0 {
+			final.Usage = chunk.UsageMetadata.toUsage()
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		cand := chunk.Candidates[0]
+		for _, part := range cand.Content.Parts {
+			if part.FunctionCall != nil {
+				final.ToolCalls = append(final.ToolCalls, toToolCall(*part.FunctionCall, len(final.ToolCalls)))
+			}
+			if part.Text == "" {
+				continue
+			}
+			select {
+			case ch <- llm.StreamChunk{Content: part.Text}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if cand.FinishReason != "" {
+			reason = cand.FinishReason
+		}
+	}
+}
+
+func (p *Provider) send(ctx context.Context, model, method string, body generateRequest) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1beta/models/%s:%s", p.baseURL, strings.TrimPrefix(model, "models/"), method)
+	if method == "streamGenerateContent" {
+		url += "?alt=sse"
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("x-goog-api-key", p.apiKey)
+	}
+
+	res, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		return nil, llm.NewStatusError("gemini", res)
+	}
+	return res, nil
+}
+
+type generateRequest struct {
+	Contents          []content         `json:"contents"`
+	SystemInstruction *content          `json:"systemInstruction,omitempty"`
+	Tools             []tool            `json:"tools,omitempty"`
+	GenerationConfig  *generationConfig `json:"generationConfig,omitempty"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type part struct {
+	Text             string            `json:"text,omitempty"`
+	FunctionCall     *functionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *functionResponse `json:"functionResponse,omitempty"`
+}
+
+type functionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type functionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+type tool struct {
+	FunctionDeclarations []functionDeclaration `json:"functionDeclarations"`
+}
+
+type functionDeclaration struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type generationConfig struct {
+	Temperature     float64 `json:"temperature,omitempty"`
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+}
+
+type usageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+func (u usageMetadata) toUsage() llm.Usage {
+	return llm.Usage{
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: u.CandidatesTokenCount,
+		TotalTokens:      u.TotalTokenCount,
+	}
+}
+
+type generateResponse struct {
+	Candidates []struct {
+		Content      content `json:"content"`
+		FinishReason string  `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata  usageMetadata `json:"usageMetadata"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (r generateResponse) blockReason() string {
+	if r.PromptFeedback == nil || r.PromptFeedback.BlockReason == "" {
+		return ""
+	}
+	return " (blocked: " + r.PromptFeedback.BlockReason + ")"
+}
+
+func newGenerateRequest(req llm.ChatRequest) generateRequest {
+	system, contents := toContents(req.Messages)
+	out := generateRequest{
+		Contents: contents,
+		Tools:    toTools(req.Tools),
+	}
+	if system != "" {
+		out.SystemInstruction = &content{Parts: []part{{Text: system}}}
+	}
+	if req.Temperature > 0 || req.MaxTokens > 0 {
+		out.GenerationConfig = &generationConfig{
+			Temperature:     req.Temperature,
+			MaxOutputTokens: req.MaxTokens,
+		}
+	}
+	return out
+}
+
+// toContents maps chat messages onto contents/parts: system messages become the
+// systemInstruction, assistant turns use the "model" role and tool results are
+// sent as functionResponse parts. Model context before the first user turn is
+// folded into the system instruction and same-role turns are merged.
+func toContents(msgs []llm.ChatMessage) (string, []content) {
+	var (
+		system []string
+		out    []content
+	)
+	for _, m := range msgs {
+		role := "user"
+		var parts []part
+		switch m.Role {
+		case llm.RoleSystem:
+			if strings.TrimSpace(m.Content) != "" {
+				system = append(system, m.Content)
+			}
+			continue
+		case llm.RoleAssistant:
+			role = "model"
+			if strings.TrimSpace(m.Content) != "" {
+				parts = append(parts, part{Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				parts = append(parts, part{FunctionCall: &functionCall{
+					Name: llm.FunctionName(tc.Function.Name),
+					Args: tc.Function.Arguments,
+				}})
+			}
+		case llm.RoleTool:
+			parts = append(parts, part{FunctionResponse: &functionResponse{
+				Name:     llm.FunctionName(m.Name),
+				Response: map[string]interface{}{"content": m.Content},
+			}})
+		default:
+			if strings.TrimSpace(m.Content) != "" {
+				parts = append(parts, part{Text: m.Content})
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		if role == "model" && len(out) == 0 {
+			for _, pt := range parts {
+				if pt.Text != "" {
+					system = append(system, pt.Text)
+				}
+			}
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Parts = append(out[n-1].Parts, parts...)
+			continue
+		}
+		out = append(out, content{Role: role, Parts: parts})
+	}
+	return strings.Join(system, "\n\n"), out
+}
+
+func toTools(defs []llm.ToolDefinition) []tool {
+	if len(defs) == 0 {
+		return nil
+	}
+	decls := make([]functionDeclaration, 0, len(defs))
+	for _, d := range defs {
+		decls = append(decls, functionDeclaration{
+			Name:        llm.FunctionName(d.Name),
+			Description: d.Description,
+			Parameters:  d.Parameters,
+		})
+	}
+	return []tool{{FunctionDeclarations: decls}}
+}
+
+// toToolCall converts a functionCall part; Gemini may omit call IDs, so one is
+// derived from the position so results can still be linked to their call.
+func toToolCall(fc functionCall, idx int) llm.ToolCall {
+	id := fc.ID
+	if id == "" {
+		id = fmt.Sprintf("call_%d_%s", idx, fc.Name)
+	}
+	args := fc.Args
+	if len(bytes.TrimSpace(args)) == 0 {
+		args = json.RawMessage("{}")
+	}
+	return llm.ToolCall{
+		ID:   id,
+		Type: "function",
+		Function: llm.ToolFunctionCall{
+			Name:      llm.ToolName(fc.Name),
+			Arguments: args,
+		},
+	}
+}
+
+// finishReason maps Gemini finish reasons onto the OpenAI-style values the runner
+// understands.
+func finishReason(reason string, hasToolCalls bool) string {
+	if hasToolCalls {
+		return "tool_calls"
+	}
+	switch reason {
+	case "STOP":
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	default:
+		return strings.ToLower(reason)
+	}
+}
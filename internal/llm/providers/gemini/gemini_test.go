@@ -0,0 +1,151 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
+)
+
+func TestChatMapsContentsAndFunctionCalls(t *testing.T) {
+	t.Parallel()
+
+	srv := newFixtureServer(t, "generate_content.json", func(r *http.Request, body generateRequest) {
+		require.Equal(t, "/v1beta/models/gemini-test:generateContent", r.URL.Path)
+		require.Equal(t, "key", r.Header.Get("x-goog-api-key"))
+
+		require.NotNil(t, body.SystemInstruction)
+		require.Equal(t, "be brief\n\nPlanned steps:\n1) read", body.SystemInstruction.Parts[0].Text)
+		require.Len(t, body.Tools, 1)
+		require.Equal(t, "fs__read_file", body.Tools[0].FunctionDeclarations[0].Name)
+		require.NotNil(t, body.GenerationConfig)
+		require.Equal(t, 256, body.GenerationConfig.MaxOutputTokens)
+
+		// user, model(text+functionCall), user(functionResponse+text) after folding.
+		require.Len(t, body.Contents, 3)
+		require.Equal(t, "user", body.Contents[0].Role)
+		require.Equal(t, "model", body.Contents[1].Role)
+		require.Equal(t, "fs__read_file", body.Contents[1].Parts[1].FunctionCall.Name)
+		require.Equal(t, "user", body.Contents[2].Role)
+		require.Equal(t, "fs__read_file", body.Contents[2].Parts[0].FunctionResponse.Name)
+		require.Equal(t, "package a", body.Contents[2].Parts[0].FunctionResponse.Response["content"])
+		require.Equal(t, "continue", body.Contents[2].Parts[1].Text)
+	})
+
+	p := NewProvider("gemini", srv.URL, "key", 0)
+	resp, err := p.Chat(context.Background(), llm.ChatRequest{
+		Model:     "models/gemini-test",
+		MaxTokens: 256,
+		Messages: []llm.ChatMessage{
+			{Role: llm.RoleSystem, Content: "be brief"},
+			{Role: llm.RoleAssistant, Content: "Planned steps:\n1) read"},
+			{Role: llm.RoleUser, Content: "read a.go"},
+			{Role: llm.RoleAssistant, Content: "Reading.", ToolCalls: []llm.ToolCall{{
+				ID:       "call_0_fs__read_file",
+				Function: llm.ToolFunctionCall{Name: "fs.read_file", Arguments: json.RawMessage(`{"path":"a.go"}`)},
+			}}},
+			{Role: llm.RoleTool, Content: "package a", Name: "fs.read_file", ToolCallID: "call_0_fs__read_file"},
+			{Role: llm.RoleUser, Content: "continue"},
+		},
+		Tools: []llm.ToolDefinition{{Name: "fs.read_file", Parameters: map[string]interface{}{"type": "object"}}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Let me look at the file.", resp.Message.Content)
+	require.Equal(t, "tool_calls", resp.FinishReason)
+	require.Equal(t, llm.Usage{PromptTokens: 42, CompletionTokens: 11, TotalTokens: 53}, resp.Usage)
+	require.Len(t, resp.Message.ToolCalls, 1)
+	require.Equal(t, "call_0_fs__read_file", resp.Message.ToolCalls[0].ID)
+	require.Equal(t, "fs.read_file", resp.Message.ToolCalls[0].Function.Name)
+	require.JSONEq(t, `{"path":"main.go"}`, string(resp.Message.ToolCalls[0].Function.Arguments))
+}
+
+func TestChatStatusError(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":429,"message":"quota"}}`, http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := NewProvider("gemini", srv.URL, "", 0)
+	_, err := p.Chat(context.Background(), llm.ChatRequest{Model: "gemini-test"})
+	require.ErrorContains(t, err, "gemini: status 429")
+}
+
+func TestStreamDecodesFixture(t *testing.T) {
+	t.Parallel()
+
+	srv := newFixtureServer(t, "stream_generate_content.sse", func(r *http.Request, _ generateRequest) {
+		require.Equal(t, "/v1beta/models/gemini-test:streamGenerateContent", r.URL.Path)
+		require.Equal(t, "sse", r.URL.Query().Get("alt"))
+	})
+
+	p := NewProvider("gemini", srv.URL, "", 0)
+	chunks, err := collect(p.Stream(context.Background(), llm.ChatRequest{
+		Model:    "gemini-test",
+		Messages: []llm.ChatMessage{{Role: llm.RoleUser, Content: "plan"}},
+	}))
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+	require.Equal(t, "The plan", chunks[0].Content)
+	require.Equal(t, " has three steps.", chunks[1].Content)
+
+	final := chunks[2]
+	require.Equal(t, "stop", final.FinishReason)
+	require.Equal(t, llm.Usage{PromptTokens: 20, CompletionTokens: 7, TotalTokens: 27}, final.Usage)
+}
+
+func TestStreamTruncated(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, `data: {"candidates":[{"content":{"parts":[{"text":"cut"}],"role":"model"}}]}`+"\n\n")
+	}))
+	t.Cleanup(srv.Close)
+
+	p := NewProvider("gemini", srv.URL, "", 0)
+	chunks, err := collect(p.Stream(context.Background(), llm.ChatRequest{Model: "gemini-test"}))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Len(t, chunks, 1)
+}
+
+// newFixtureServer replays a recorded response body from testdata after
+// letting check inspect the incoming request.
+func newFixtureServer(t *testing.T, fixture string, check func(*http.Request, generateRequest)) *httptest.Server {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", fixture))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body generateRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		check(r, body)
+
+		if filepath.Ext(fixture) == ".sse" {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func collect(ch <-chan llm.StreamChunk, errCh <-chan error) ([]llm.StreamChunk, error) {
+	var chunks []llm.StreamChunk
+	for c := range ch {
+		chunks = append(chunks, c)
+	}
+	return chunks, <-errCh
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -31,7 +32,7 @@ func NewProvider(name, baseURL string, timeout time.Duration) *Provider {
 
 	return &Provider{
 		name:    name,
-		client:  &http.Client{Timeout: timeout},
+		client:  llm.NewHTTPClient(timeout),
 		baseURL: strings.TrimRight(baseURL, "/"),
 	}
 }
@@ -48,38 +49,12 @@ func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (llm.ChatRespo
 		return llm.ChatResponse{}, fmt.Errorf("model is required")
 	}
 
-	body := ollamaChatRequest{
-		Model:    model,
-		Messages: toOllamaMessages(req.Messages),
-		Stream:   false,
-		Options: map[string]interface{}{
-			"temperature": req.Temperature,
-			"num_predict": req.MaxTokens,
-		},
-	}
-
-	payload, err := json.Marshal(body)
-	if err != nil {
-		return llm.ChatResponse{}, fmt.Errorf("marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/chat", bytes.NewReader(payload))
-	if err != nil {
-		return llm.ChatResponse{}, fmt.Errorf("build request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	res, err := p.client.Do(httpReq)
+	res, err := p.send(ctx, newChatRequest(req, false))
 	if err != nil {
-		return llm.ChatResponse{}, fmt.Errorf("send request: %w", err)
+		return llm.ChatResponse{}, err
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode >= 300 {
-		b, _ := io.ReadAll(res.Body)
-		return llm.ChatResponse{}, fmt.Errorf("ollama: status %d: %s", res.StatusCode, string(b))
-	}
-
 	var resp ollamaChatResponse
 	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
 		return llm.ChatResponse{}, fmt.Errorf("decode response: %w", err)
@@ -90,36 +65,116 @@ func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (llm.ChatRespo
 			Role:    llm.Role(resp.Message.Role),
 			Content: resp.Message.Content,
 		},
-		FinishReason: "stop",
+		FinishReason: resp.finishReason(),
+		Usage:        resp.usage(),
 		RawResponse:  resp,
 		ProviderName: p.name,
 		Model:        model,
 	}, nil
 }
 
-// Stream performs chat and emits a single chunk (simulated streaming).
+// Stream decodes Ollama's NDJSON /api/chat stream, emitting one chunk per line.
+// The final chunk carries done_reason and eval counts as FinishReason and Usage.
 func (p *Provider) Stream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, <-chan error) {
-	ch := make(chan llm.StreamChunk, 1)
+	ch := make(chan llm.StreamChunk, 16)
 	errCh := make(chan error, 1)
 
 	go func() {
 		defer close(ch)
 		defer close(errCh)
 
-		resp, err := p.Chat(ctx, req)
-		if err != nil {
+		if err := p.stream(ctx, req, ch); err != nil {
 			errCh <- err
-			return
-		}
-		ch <- llm.StreamChunk{
-			Content:      resp.Message.Content,
-			FinishReason: resp.FinishReason,
 		}
 	}()
 
 	return ch, errCh
 }
 
+func (p *Provider) stream(ctx context.Context, req llm.ChatRequest, ch chan<- llm.StreamChunk) error {
+	if req.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+
+	res, err := p.send(ctx, newChatRequest(req, true))
+	if err != nil {
+		return err
+	}
+	// Closing the body on cancellation unblocks a pending read immediately.
+	stop := context.AfterFunc(ctx, func() { res.Body.Close() })
+	defer stop()
+	defer res.Body.Close()
+
+	dec := json.NewDecoder(res.Body)
+	for {
+		var line ollamaChatResponse
+		if err := dec.Decode(&line); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("ollama: stream ended before completion: %w", io.ErrUnexpectedEOF)
+			}
+			return fmt.Errorf("ollama: decode stream line: %w", err)
+		}
+		if line.Error != "" {
+			return fmt.Errorf("ollama: stream error: %s", line.Error)
+		}
+
+		chunk := llm.StreamChunk{Content: line.Message.Content}
+		if line.Done {
+			chunk.FinishReason = line.finishReason()
+			chunk.Usage = line.usage()
+		}
+		if chunk.Content != "" || line.Done {
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if line.Done {
+			return nil
+		}
+	}
+}
+
+func (p *Provider) send(ctx context.Context, body ollamaChatRequest) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/chat", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	res, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		return nil, llm.NewStatusError("ollama", res)
+	}
+	return res, nil
+}
+
+func newChatRequest(req llm.ChatRequest, stream bool) ollamaChatRequest {
+	return ollamaChatRequest{
+		Model:    req.Model,
+		Messages: toOllamaMessages(req.Messages),
+		Stream:   stream,
+		Options: map[string]interface{}{
+			"temperature": req.Temperature,
+			"num_predict": req.MaxTokens,
+		},
+	}
+}
+
 type ollamaChatRequest struct {
 	Model    string                 `json:"model"`
 	Messages []ollamaMessage        `json:"messages"`
@@ -137,6 +192,26 @@ type ollamaChatResponse struct {
 		Role    string `json:"role"`
 		Content string `json:"content"`
 	} `json:"message"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason,omitempty"`
+	PromptEvalCount int    `json:"prompt_eval_count,omitempty"`
+	EvalCount       int    `json:"eval_count,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+func (r ollamaChatResponse) finishReason() string {
+	if r.DoneReason != "" {
+		return r.DoneReason
+	}
+	return "stop"
+}
+
+func (r ollamaChatResponse) usage() llm.Usage {
+	return llm.Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
 }
 
 func toOllamaMessages(msgs []llm.ChatMessage) []ollamaMessage {
@@ -2,10 +2,12 @@ package ollama
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -37,16 +39,22 @@ func TestChat(t *testing.T) {
 	require.Equal(t, "pong", resp.Message.Content)
 }
 
-func TestStream(t *testing.T) {
+func TestStreamDecodesNDJSON(t *testing.T) {
 	t.Parallel()
 
 	p := NewProvider("ollama", "http://mock", 0)
 	p.client = &http.Client{
 		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Equal(t, true, body["stream"])
 			return &http.Response{
 				StatusCode: http.StatusOK,
 				Header:     make(http.Header),
-				Body:       io.NopCloser(strings.NewReader(`{"message":{"role":"assistant","content":"chunk"}}`)),
+				Body: io.NopCloser(strings.NewReader(
+					`{"message":{"role":"assistant","content":"ch"},"done":false}` + "\n" +
+						`{"message":{"role":"assistant","content":"unk"},"done":false}` + "\n" +
+						`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"length","prompt_eval_count":7,"eval_count":3}` + "\n")),
 			}, nil
 		}),
 	}
@@ -57,9 +65,66 @@ func TestStream(t *testing.T) {
 			{Role: llm.RoleUser, Content: "hi"}},
 	})
 
-	chunk := <-ch
-	require.Equal(t, "chunk", chunk.Content)
-	require.Empty(t, <-errCh)
+	var chunks []llm.StreamChunk
+	for c := range ch {
+		chunks = append(chunks, c)
+	}
+	require.NoError(t, <-errCh)
+	require.Len(t, chunks, 3)
+	require.Equal(t, "ch", chunks[0].Content)
+	require.Equal(t, "unk", chunks[1].Content)
+	require.Equal(t, "length", chunks[2].FinishReason)
+	require.Equal(t, llm.Usage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10}, chunks[2].Usage)
+}
+
+func TestStreamReportsErrorLine(t *testing.T) {
+	t.Parallel()
+
+	p := NewProvider("ollama", "http://mock", 0)
+	p.client = &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     make(http.Header),
+				Body:       io.NopCloser(strings.NewReader(`{"error":"model not found"}` + "\n")),
+			}, nil
+		}),
+	}
+
+	ch, errCh := p.Stream(context.Background(), llm.ChatRequest{Model: "llama3"})
+	for range ch {
+	}
+	require.ErrorContains(t, <-errCh, "model not found")
+}
+
+func TestStreamStopsOnCancel(t *testing.T) {
+	t.Parallel()
+
+	pr, pw := io.Pipe()
+	p := NewProvider("ollama", "http://mock", 0)
+	p.client = &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			go func() {
+				_, _ = io.WriteString(pw, `{"message":{"role":"assistant","content":"first"},"done":false}`+"\n")
+				// Never finish the stream; only cancellation can end it.
+			}()
+			return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: pr}, nil
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, errCh := p.Stream(ctx, llm.ChatRequest{Model: "llama3"})
+
+	first := <-ch
+	require.Equal(t, "first", first.Content)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop after cancellation")
+	}
 }
 
 type roundTripFunc func(r *http.Request) (*http.Response, error)
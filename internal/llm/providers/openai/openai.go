@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/animus-coder/animus-coder/internal/llm"
+	"github.com/animus-coder/animus-coder/internal/llm/sse"
 )
 
 // Provider implements an OpenAI-compatible chat provider.
@@ -32,7 +34,7 @@ func NewProvider(name, baseURL, apiKey string, timeout time.Duration) *Provider
 
 	return &Provider{
 		name:    name,
-		client:  &http.Client{Timeout: timeout},
+		client:  llm.NewHTTPClient(timeout),
 		baseURL: strings.TrimRight(baseURL, "/"),
 		apiKey:  apiKey,
 	}
@@ -50,39 +52,19 @@ func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (llm.ChatRespo
 		return llm.ChatResponse{}, fmt.Errorf("model is required")
 	}
 
-	body := openAIChatRequest{
+	res, err := p.send(ctx, openAIChatRequest{
 		Model:       model,
 		Messages:    toOpenAIMessages(req.Messages),
+		Tools:       toOpenAITools(req.Tools),
 		MaxTokens:   req.MaxTokens,
 		Temperature: req.Temperature,
 		Stream:      false,
-	}
-
-	payload, err := json.Marshal(body)
-	if err != nil {
-		return llm.ChatResponse{}, fmt.Errorf("marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/chat/completions", bytes.NewReader(payload))
-	if err != nil {
-		return llm.ChatResponse{}, fmt.Errorf("build request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	if p.apiKey != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
-	}
-
-	res, err := p.client.Do(httpReq)
+	})
 	if err != nil {
-		return llm.ChatResponse{}, fmt.Errorf("send request: %w", err)
+		return llm.ChatResponse{}, err
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode >= 300 {
-		b, _ := io.ReadAll(res.Body)
-		return llm.ChatResponse{}, fmt.Errorf("openai: status %d: %s", res.StatusCode, string(b))
-	}
-
 	var resp openAIChatResponse
 	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
 		return llm.ChatResponse{}, fmt.Errorf("decode response: %w", err)
@@ -95,56 +77,199 @@ func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (llm.ChatRespo
 	msg := resp.Choices[0].Message
 	return llm.ChatResponse{
 		Message: llm.ChatMessage{
-			Role:    llm.Role(msg.Role),
-			Content: msg.Content,
+			Role:      llm.Role(msg.Role),
+			Content:   msg.Content,
+			ToolCalls: fromOpenAIToolCalls(msg.ToolCalls),
 		},
 		FinishReason: resp.Choices[0].FinishReason,
-		Usage: llm.Usage{
-			PromptTokens:     resp.Usage.PromptTokens,
-			CompletionTokens: resp.Usage.CompletionTokens,
-			TotalTokens:      resp.Usage.TotalTokens,
-		},
+		Usage:        resp.Usage.toUsage(),
 		RawResponse:  resp,
 		ProviderName: p.name,
 		Model:        model,
 	}, nil
 }
 
-// Stream performs chat and emits a single chunk (simulated streaming).
+// Stream sends a streaming chat completion and emits one chunk per SSE delta.
+// The final chunk carries the finish reason and usage reported by the server.
 func (p *Provider) Stream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, <-chan error) {
-	ch := make(chan llm.StreamChunk, 1)
+	ch := make(chan llm.StreamChunk, 16)
 	errCh := make(chan error, 1)
 
 	go func() {
 		defer close(ch)
 		defer close(errCh)
 
-		resp, err := p.Chat(ctx, req)
-		if err != nil {
+		if err := p.stream(ctx, req, ch); err != nil {
 			errCh <- err
-			return
-		}
-		ch <- llm.StreamChunk{
-			Content:      resp.Message.Content,
-			FinishReason: resp.FinishReason,
 		}
 	}()
 
 	return ch, errCh
 }
 
+func (p *Provider) stream(ctx context.Context, req llm.ChatRequest, ch chan<- llm.StreamChunk) error {
+	if req.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+
+	res, err := p.send(ctx, openAIChatRequest{
+		Model:         req.Model,
+		Messages:      toOpenAIMessages(req.Messages),
+		Tools:         toOpenAITools(req.Tools),
+		MaxTokens:     req.MaxTokens,
+		Temperature:   req.Temperature,
+		Stream:        true,
+		StreamOptions: &openAIStreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	emit := func(chunk llm.StreamChunk) error {
+		select {
+		case ch <- chunk:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var (
+		final    llm.StreamChunk
+		finished bool
+		calls    toolCallAccumulator
+		reader   = sse.NewReader(res.Body)
+	)
+	for {
+		evt, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			// Some compatible servers close the stream without [DONE]; accept that
+			// only once a finish reason has been seen.
+			if !finished {
+				return fmt.Errorf("openai: stream ended before completion: %w", io.ErrUnexpectedEOF)
+			}
+			final.ToolCalls = calls.toolCalls()
+			return emit(final)
+		}
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return fmt.Errorf("openai: read stream: %w", err)
+		}
+
+		data := strings.TrimSpace(evt.Data)
+		if data == "[DONE]" {
+			if !finished {
+				final.FinishReason = "stop"
+			}
+			final.ToolCalls = calls.toolCalls()
+			return emit(final)
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("openai: decode stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("openai: stream error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			final.Usage = chunk.Usage.toUsage()
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			if choice.Delta.Content != "" {
+				if err := emit(llm.StreamChunk{Content: choice.Delta.Content}); err != nil {
+					return err
+				}
+			}
+			if err := calls.add(choice.Delta.ToolCalls); err != nil {
+				return fmt.Errorf("openai: decode stream chunk: %w", err)
+			}
+			if choice.FinishReason != "" {
+				final.FinishReason = choice.FinishReason
+				finished = true
+			}
+		}
+	}
+}
+
+func (p *Provider) send(ctx context.Context, body openAIChatRequest) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if body.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	res, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		return nil, llm.NewStatusError("openai", res)
+	}
+	return res, nil
+}
+
 type openAIChatRequest struct {
-	Model       string          `json:"model"`
-	Messages    []openAIMessage `json:"messages"`
-	MaxTokens   int             `json:"max_tokens,omitempty"`
-	Temperature float64         `json:"temperature,omitempty"`
-	Stream      bool            `json:"stream,omitempty"`
+	Model         string               `json:"model"`
+	Messages      []openAIMessage      `json:"messages"`
+	Tools         []openAITool         `json:"tools,omitempty"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Temperature   float64              `json:"temperature,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type openAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content,omitempty"`
-	Name    string `json:"name,omitempty"`
+	Role       string           `json:"role"`
+	Content    string           `json:"content,omitempty"`
+	Name       string           `json:"name,omitempty"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAITool struct {
+	Type     string             `json:"type"`
+	Function openAIToolFunction `json:"function"`
+}
+
+type openAIToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type openAIToolCall struct {
+	Index    int    `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name string `json:"name,omitempty"`
+		// Arguments is a JSON document encoded as a string.
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type openAIChatResponse struct {
@@ -153,21 +278,141 @@ type openAIChatResponse struct {
 		FinishReason string        `json:"finish_reason"`
 		Message      openAIMessage `json:"message"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage openAIUsage `json:"usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u openAIUsage) toUsage() llm.Usage {
+	return llm.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Index        int    `json:"index"`
+		FinishReason string `json:"finish_reason"`
+		Delta        struct {
+			Role      string           `json:"role"`
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
 }
 
 func toOpenAIMessages(msgs []llm.ChatMessage) []openAIMessage {
 	out := make([]openAIMessage, 0, len(msgs))
 	for _, m := range msgs {
-		out = append(out, openAIMessage{
-			Role:    string(m.Role),
-			Content: m.Content,
-			Name:    m.Name,
+		msg := openAIMessage{
+			Role:       string(m.Role),
+			Content:    m.Content,
+			Name:       m.Name,
+			ToolCallID: m.ToolCallID,
+		}
+		for _, tc := range m.ToolCalls {
+			call := openAIToolCall{ID: tc.ID, Type: "function"}
+			call.Function.Name = llm.FunctionName(tc.Function.Name)
+			call.Function.Arguments = string(tc.Function.Arguments)
+			if call.Function.Arguments == "" {
+				call.Function.Arguments = "{}"
+			}
+			msg.ToolCalls = append(msg.ToolCalls, call)
+		}
+		out = append(out, msg)
+	}
+	return out
+}
+
+func toOpenAITools(defs []llm.ToolDefinition) []openAITool {
+	if len(defs) == 0 {
+		return nil
+	}
+	out := make([]openAITool, 0, len(defs))
+	for _, d := range defs {
+		out = append(out, openAITool{
+			Type: "function",
+			Function: openAIToolFunction{
+				Name:        llm.FunctionName(d.Name),
+				Description: d.Description,
+				Parameters:  d.Parameters,
+			},
+		})
+	}
+	return out
+}
+
+func fromOpenAIToolCalls(calls []openAIToolCall) []llm.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]llm.ToolCall, 0, len(calls))
+	for _, c := range calls {
+		out = append(out, llm.ToolCall{
+			ID:   c.ID,
+			Type: "function",
+			Function: llm.ToolFunctionCall{
+				Name:      llm.ToolName(c.Function.Name),
+				Arguments: toolArguments(c.Function.Arguments),
+			},
 		})
 	}
 	return out
 }
+
+// toolArguments converts the string-encoded arguments into raw JSON, keeping
+// invalid payloads as a JSON string so callers can report them.
+func toolArguments(args string) json.RawMessage {
+	if strings.TrimSpace(args) == "" {
+		return json.RawMessage("{}")
+	}
+	if json.Valid([]byte(args)) {
+		return json.RawMessage(args)
+	}
+	encoded, _ := json.Marshal(args)
+	return encoded
+}
+
+// maxToolCallIndexGap bounds how far a streamed tool-call index may run ahead of the
+// calls seen so far; servers number them sequentially from 0.
+const maxToolCallIndexGap = 8
+
+// toolCallAccumulator assembles streamed tool-call fragments keyed by index.
+type toolCallAccumulator struct {
+	calls []openAIToolCall
+}
+
+func (a *toolCallAccumulator) add(deltas []openAIToolCall) error {
+	for _, d := range deltas {
+		if d.Index < 0 || d.Index > len(a.calls)+maxToolCallIndexGap {
+			return fmt.Errorf("tool call index %d out of range with %d calls", d.Index, len(a.calls))
+		}
+		for len(a.calls) <= d.Index {
+			a.calls = append(a.calls, openAIToolCall{})
+		}
+		c := &a.calls[d.Index]
+		if d.ID != "" {
+			c.ID = d.ID
+		}
+		if d.Type != "" {
+			c.Type = d.Type
+		}
+		c.Function.Name += d.Function.Name
+		c.Function.Arguments += d.Function.Arguments
+	}
+	return nil
+}
+
+func (a *toolCallAccumulator) toolCalls() []llm.ToolCall {
+	return fromOpenAIToolCalls(a.calls)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -57,38 +58,272 @@ func TestChatSendsRequestAndParsesResponse(t *testing.T) {
 	require.Equal(t, 3, resp.Usage.TotalTokens)
 }
 
-func TestStreamWrapsChat(t *testing.T) {
+func TestChatSendsToolsAndParsesToolCalls(t *testing.T) {
 	t.Parallel()
 
 	p := NewProvider("openai", "http://mock", "", 0)
 	p.client = &http.Client{
 		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			var reqBody struct {
+				Tools    []openAITool    `json:"tools"`
+				Messages []openAIMessage `json:"messages"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&reqBody))
+			require.Len(t, reqBody.Tools, 1)
+			require.Equal(t, "function", reqBody.Tools[0].Type)
+			require.Equal(t, "fs__read_file", reqBody.Tools[0].Function.Name)
+			require.Equal(t, "object", reqBody.Tools[0].Function.Parameters["type"])
+
+			require.Len(t, reqBody.Messages, 3)
+			require.Equal(t, "fs__read_file", reqBody.Messages[1].ToolCalls[0].Function.Name)
+			require.JSONEq(t, `{"path":"a.go"}`, reqBody.Messages[1].ToolCalls[0].Function.Arguments)
+			require.Equal(t, "call_0", reqBody.Messages[2].ToolCallID)
+
 			return &http.Response{
 				StatusCode: http.StatusOK,
 				Header:     make(http.Header),
 				Body: io.NopCloser(strings.NewReader(`{
 					"choices": [{
 						"index": 0,
-						"finish_reason": "stop",
-						"message": {"role": "assistant", "content": "streamed"}
-					}],
-					"usage": {"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2}
+						"finish_reason": "tool_calls",
+						"message": {"role": "assistant", "tool_calls": [{
+							"id": "call_1", "type": "function",
+							"function": {"name": "fs__read_file", "arguments": "{\"path\":\"b.go\"}"}
+						}]}
+					}]
 				}`)),
 			}, nil
 		}),
 	}
 
-	ch, errCh := p.Stream(context.Background(), llm.ChatRequest{
+	resp, err := p.Chat(context.Background(), llm.ChatRequest{
 		Model: "gpt-4o-mini",
 		Messages: []llm.ChatMessage{
-			{Role: llm.RoleUser, Content: "hi"},
+			{Role: llm.RoleUser, Content: "read"},
+			{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{
+				ID: "call_0", Type: "function",
+				Function: llm.ToolFunctionCall{Name: "fs.read_file", Arguments: json.RawMessage(`{"path":"a.go"}`)},
+			}}},
+			{Role: llm.RoleTool, Content: "package a", ToolCallID: "call_0"},
 		},
+		Tools: []llm.ToolDefinition{{
+			Name:       "fs.read_file",
+			Parameters: map[string]interface{}{"type": "object"},
+		}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "tool_calls", resp.FinishReason)
+	require.Len(t, resp.Message.ToolCalls, 1)
+	require.Equal(t, "call_1", resp.Message.ToolCalls[0].ID)
+	require.Equal(t, "fs.read_file", resp.Message.ToolCalls[0].Function.Name)
+	require.JSONEq(t, `{"path":"b.go"}`, string(resp.Message.ToolCalls[0].Function.Arguments))
+}
+
+func TestStreamAssemblesToolCalls(t *testing.T) {
+	t.Parallel()
+
+	srv := newSSEServer(t, nil, []string{
+		`data: {"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"terminal__exec","arguments":""}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"comm"}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"and\":\"ls\"}"}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
 	})
 
-	chunk := <-ch
-	require.Equal(t, "streamed", chunk.Content)
-	require.Equal(t, "stop", chunk.FinishReason)
-	require.Empty(t, <-errCh)
+	p := NewProvider("openai", srv.URL, "", 0)
+	chunks, err := collect(p.Stream(context.Background(), llm.ChatRequest{Model: "m"}))
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	require.Equal(t, "tool_calls", chunks[0].FinishReason)
+	require.Len(t, chunks[0].ToolCalls, 1)
+	require.Equal(t, "call_1", chunks[0].ToolCalls[0].ID)
+	require.Equal(t, "terminal.exec", chunks[0].ToolCalls[0].Function.Name)
+	require.JSONEq(t, `{"command":"ls"}`, string(chunks[0].ToolCalls[0].Function.Arguments))
+}
+
+func TestStreamEmitsDeltas(t *testing.T) {
+	t.Parallel()
+
+	srv := newSSEServer(t, func(body map[string]interface{}) {
+		require.Equal(t, true, body["stream"])
+		require.Equal(t, map[string]interface{}{"include_usage": true}, body["stream_options"])
+	}, []string{
+		`data: {"choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"Hel"}}]}`,
+		`: keep-alive`,
+		`data: {"choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`data: {"choices":[],"usage":{"prompt_tokens":4,"completion_tokens":2,"total_tokens":6}}`,
+		`data: [DONE]`,
+	})
+
+	p := NewProvider("openai", srv.URL, "", 0)
+	chunks, err := collect(p.Stream(context.Background(), llm.ChatRequest{
+		Model:    "gpt-4o-mini",
+		Messages: []llm.ChatMessage{{Role: llm.RoleUser, Content: "hi"}},
+	}))
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+	require.Equal(t, "Hel", chunks[0].Content)
+	require.Equal(t, "lo", chunks[1].Content)
+
+	final := chunks[2]
+	require.Empty(t, final.Content)
+	require.Equal(t, "stop", final.FinishReason)
+	require.Equal(t, llm.Usage{PromptTokens: 4, CompletionTokens: 2, TotalTokens: 6}, final.Usage)
+}
+
+func TestStreamAcceptsMissingDoneAfterFinish(t *testing.T) {
+	t.Parallel()
+
+	srv := newSSEServer(t, nil, []string{
+		`data: {"choices":[{"index":0,"delta":{"content":"ok"},"finish_reason":"length"}]}`,
+	})
+
+	p := NewProvider("openai", srv.URL, "", 0)
+	chunks, err := collect(p.Stream(context.Background(), llm.ChatRequest{Model: "m"}))
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+	require.Equal(t, "ok", chunks[0].Content)
+	require.Equal(t, "length", chunks[1].FinishReason)
+}
+
+func TestStreamMalformedFrame(t *testing.T) {
+	t.Parallel()
+
+	srv := newSSEServer(t, nil, []string{
+		`data: {"choices":[{"index":0,"delta":{"content":"a"}}]}`,
+		`data: {"choices":[{"index":0,"delta":`,
+		`data: [DONE]`,
+	})
+
+	p := NewProvider("openai", srv.URL, "", 0)
+	chunks, err := collect(p.Stream(context.Background(), llm.ChatRequest{Model: "m"}))
+	require.ErrorContains(t, err, "decode stream chunk")
+	require.Len(t, chunks, 1)
+	require.Equal(t, "a", chunks[0].Content)
+}
+
+func TestStreamRejectsToolCallIndexOutOfRange(t *testing.T) {
+	t.Parallel()
+
+	for _, index := range []string{"-1", "1000000000"} {
+		srv := newSSEServer(t, nil, []string{
+			`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"fs__read_file","arguments":""}}]}}]}`,
+			`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":` + index + `,"function":{"arguments":"{}"}}]}}]}`,
+			`data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			`data: [DONE]`,
+		})
+
+		p := NewProvider("openai", srv.URL, "", 0)
+		_, err := collect(p.Stream(context.Background(), llm.ChatRequest{Model: "m"}))
+		require.ErrorContains(t, err, "decode stream chunk", "index %s", index)
+		require.ErrorContains(t, err, "tool call index "+index+" out of range")
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	t.Parallel()
+
+	srv := newSSEServer(t, nil, []string{
+		`data: {"choices":[{"index":0,"delta":{"content":"partial"}}]}`,
+	})
+
+	p := NewProvider("openai", srv.URL, "", 0)
+	chunks, err := collect(p.Stream(context.Background(), llm.ChatRequest{Model: "m"}))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Len(t, chunks, 1)
+}
+
+func TestStreamServerError(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := NewProvider("openai", srv.URL, "", 0)
+	_, err := collect(p.Stream(context.Background(), llm.ChatRequest{Model: "m"}))
+	require.ErrorContains(t, err, "status 429")
+}
+
+func TestStreamOutlivesProviderTimeout(t *testing.T) {
+	t.Parallel()
+
+	frames := []string{
+		`data: {"choices":[{"index":0,"delta":{"content":"slow "}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"but "}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"steady"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`data: [DONE]`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		flusher.Flush()
+		for _, f := range frames {
+			time.Sleep(40 * time.Millisecond)
+			_, _ = io.WriteString(w, f+"\n\n")
+			flusher.Flush()
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	// The whole stream takes ~200ms, well past the 50ms timeout, which only bounds
+	// the wait for response headers.
+	p := NewProvider("openai", srv.URL, "", 50*time.Millisecond)
+	chunks, err := collect(p.Stream(context.Background(), llm.ChatRequest{Model: "m"}))
+	require.NoError(t, err)
+	require.Len(t, chunks, 4)
+	require.Equal(t, "steady", chunks[2].Content)
+	require.Equal(t, "stop", chunks[3].FinishReason)
+}
+
+func TestTimeoutBoundsWaitForResponseHeaders(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	p := NewProvider("openai", srv.URL, "", 50*time.Millisecond)
+	_, err := collect(p.Stream(context.Background(), llm.ChatRequest{Model: "m"}))
+	require.Error(t, err)
+	require.Equal(t, llm.ErrorTimeout, llm.ClassifyError(err))
+}
+
+// newSSEServer replays recorded SSE frames, each followed by a blank line.
+func newSSEServer(t *testing.T, check func(body map[string]interface{}), frames []string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			check(body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, f := range frames {
+			_, _ = io.WriteString(w, f+"\n\n")
+			flusher.Flush()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func collect(ch <-chan llm.StreamChunk, errCh <-chan error) ([]llm.StreamChunk, error) {
+	var chunks []llm.StreamChunk
+	for c := range ch {
+		chunks = append(chunks, c)
+	}
+	return chunks, <-errCh
 }
 
 type roundTripFunc func(r *http.Request) (*http.Response, error)
@@ -0,0 +1,417 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
+)
+
+// Mode selects whether the provider records new interactions or replays a cassette.
+type Mode string
+
+const (
+	ModeReplay Mode = "replay"
+	ModeRecord Mode = "record"
+)
+
+// ErrUnmatched is returned in replay mode when no recorded interaction matches a request.
+var ErrUnmatched = errors.New("replay: no recorded interaction matches request")
+
+// Cassette is the on-disk recording of request/response pairs.
+type Cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded exchange.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the normalized form of llm.ChatRequest used for matching. Sampling
+// parameters are left out so tuning temperature or max_tokens keeps cassettes valid.
+type Request struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Tools    []string  `json:"tools,omitempty"`
+}
+
+// Message is a normalized chat message.
+type Message struct {
+	Role       llm.Role       `json:"role"`
+	Content    string         `json:"content,omitempty"`
+	Name       string         `json:"name,omitempty"`
+	ToolCalls  []llm.ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// Response is the recorded provider answer.
+type Response struct {
+	Content      string     `json:"content,omitempty"`
+	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
+	FinishReason string     `json:"finish_reason,omitempty"`
+	Model        string     `json:"model,omitempty"`
+	Usage        Usage      `json:"usage"`
+}
+
+// ToolCall is a recorded tool call. Arguments are kept as a string so replay returns
+// exactly the bytes the model produced (indenting the cassette would reformat raw JSON).
+type ToolCall struct {
+	ID        string `json:"id,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Usage mirrors llm.Usage with stable JSON names.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Provider records every exchange with an upstream provider to a cassette file, or
+// serves recorded responses for matching requests without network access.
+type Provider struct {
+	name     string
+	path     string
+	mode     Mode
+	upstream llm.Provider
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewProvider opens a cassette. Replay mode requires the file to exist; record mode
+// requires an upstream provider and starts a fresh cassette, overwriting the file on
+// the first recorded interaction.
+func NewProvider(name, path string, mode Mode, upstream llm.Provider) (*Provider, error) {
+	if path == "" {
+		return nil, fmt.Errorf("replay: cassette path is required")
+	}
+	if mode == "" {
+		mode = ModeReplay
+	}
+
+	p := &Provider{name: name, path: path, mode: mode, upstream: upstream, cassette: Cassette{Version: 1}}
+	switch mode {
+	case ModeReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("replay: read cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, &p.cassette); err != nil {
+			return nil, fmt.Errorf("replay: decode cassette %s: %w", path, err)
+		}
+		p.used = make([]bool, len(p.cassette.Interactions))
+	case ModeRecord:
+		if upstream == nil {
+			return nil, fmt.Errorf("replay: record mode requires an upstream provider")
+		}
+	default:
+		return nil, fmt.Errorf("replay: unknown mode %q", mode)
+	}
+	return p, nil
+}
+
+// Name returns provider identifier.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Remaining returns how many recorded interactions have not been served yet.
+func (p *Provider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := 0
+	for _, used := range p.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+// Chat replays or records a single exchange.
+func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+	if p.mode == ModeReplay {
+		resp, err := p.lookup(req)
+		if err != nil {
+			return llm.ChatResponse{}, err
+		}
+		return p.toChatResponse(resp, req.Model), nil
+	}
+
+	resp, err := p.upstream.Chat(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if err := p.record(req, Response{
+		Content:      resp.Message.Content,
+		ToolCalls:    fromToolCalls(resp.Message.ToolCalls),
+		FinishReason: resp.FinishReason,
+		Model:        resp.Model,
+		Usage:        fromUsage(resp.Usage),
+	}); err != nil {
+		return llm.ChatResponse{}, err
+	}
+	return resp, nil
+}
+
+// Stream replays a recorded response as one content chunk followed by the final chunk,
+// or forwards the upstream stream and records the assembled response once it completes.
+func (p *Provider) Stream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, <-chan error) {
+	ch := make(chan llm.StreamChunk, 16)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+		defer close(errCh)
+
+		var err error
+		if p.mode == ModeReplay {
+			err = p.replayStream(ctx, req, ch)
+		} else {
+			err = p.recordStream(ctx, req, ch)
+		}
+		if err != nil {
+			errCh <- err
+		}
+	}()
+
+	return ch, errCh
+}
+
+func (p *Provider) replayStream(ctx context.Context, req llm.ChatRequest, ch chan<- llm.StreamChunk) error {
+	resp, err := p.lookup(req)
+	if err != nil {
+		return err
+	}
+	chunks := []llm.StreamChunk{{
+		ToolCalls:    toToolCalls(resp.ToolCalls),
+		FinishReason: resp.FinishReason,
+		Usage:        toUsage(resp.Usage),
+	}}
+	if resp.Content != "" {
+		chunks = append([]llm.StreamChunk{{Content: resp.Content}}, chunks...)
+	}
+	for _, c := range chunks {
+		select {
+		case ch <- c:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (p *Provider) recordStream(ctx context.Context, req llm.ChatRequest, ch chan<- llm.StreamChunk) error {
+	chunks, errs := p.upstream.Stream(ctx, req)
+	var (
+		resp    Response
+		content strings.Builder
+	)
+	for c := range chunks {
+		content.WriteString(c.Content)
+		if len(c.ToolCalls) > 0 {
+			resp.ToolCalls = fromToolCalls(c.ToolCalls)
+		}
+		if c.FinishReason != "" {
+			resp.FinishReason = c.FinishReason
+		}
+		if c.Usage.TotalTokens > 0 {
+			resp.Usage = fromUsage(c.Usage)
+		}
+		select {
+		case ch <- c:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err := <-errs; err != nil {
+		return err
+	}
+	resp.Content = content.String()
+	return p.record(req, resp)
+}
+
+// lookup returns the first unused interaction whose normalized request matches.
+func (p *Provider) lookup(req llm.ChatRequest) (Response, error) {
+	key, err := matchKey(Normalize(req))
+	if err != nil {
+		return Response{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, it := range p.cassette.Interactions {
+		if p.used[i] {
+			continue
+		}
+		recorded, err := matchKey(it.Request)
+		if err != nil {
+			return Response{}, err
+		}
+		if recorded == key {
+			p.used[i] = true
+			return it.Response, nil
+		}
+	}
+	return Response{}, fmt.Errorf("%w in %s (model %q, %d messages, last: %s); re-record the cassette",
+		ErrUnmatched, p.path, req.Model, len(req.Messages), describeLast(req.Messages))
+}
+
+func (p *Provider) record(req llm.ChatRequest, resp Response) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cassette.Interactions = append(p.cassette.Interactions, Interaction{Request: Normalize(req), Response: resp})
+	return p.save()
+}
+
+// save rewrites the cassette through a temp file so an interrupted session keeps
+// everything recorded so far.
+func (p *Provider) save() error {
+	data, err := json.MarshalIndent(p.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("replay: encode cassette: %w", err)
+	}
+	if dir := filepath.Dir(p.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("replay: create cassette dir: %w", err)
+		}
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("replay: write cassette: %w", err)
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		return fmt.Errorf("replay: write cassette: %w", err)
+	}
+	return nil
+}
+
+func (p *Provider) toChatResponse(resp Response, model string) llm.ChatResponse {
+	if resp.Model != "" {
+		model = resp.Model
+	}
+	return llm.ChatResponse{
+		Message: llm.ChatMessage{
+			Role:      llm.RoleAssistant,
+			Content:   resp.Content,
+			ToolCalls: toToolCalls(resp.ToolCalls),
+		},
+		FinishReason: resp.FinishReason,
+		Usage:        toUsage(resp.Usage),
+		ProviderName: p.name,
+		Model:        model,
+	}
+}
+
+// Normalize converts a request into its matching form: line endings and surrounding
+// whitespace are normalized, tool arguments are compacted and tools are kept by name.
+func Normalize(req llm.ChatRequest) Request {
+	out := Request{Model: req.Model}
+	for _, m := range req.Messages {
+		msg := Message{
+			Role:       m.Role,
+			Content:    normalizeText(m.Content),
+			Name:       m.Name,
+			ToolCallID: m.ToolCallID,
+		}
+		for _, tc := range m.ToolCalls {
+			tc.Function.Arguments = compactJSON(tc.Function.Arguments)
+			msg.ToolCalls = append(msg.ToolCalls, tc)
+		}
+		out.Messages = append(out.Messages, msg)
+	}
+	for _, t := range req.Tools {
+		out.Tools = append(out.Tools, t.Name)
+	}
+	return out
+}
+
+// matchKey renders a request for comparison; recorded requests go through the same
+// normalization so hand-edited cassettes still match.
+func matchKey(req Request) (string, error) {
+	msgs := make([]Message, len(req.Messages))
+	for i, m := range req.Messages {
+		m.Content = normalizeText(m.Content)
+		calls := make([]llm.ToolCall, len(m.ToolCalls))
+		for j, tc := range m.ToolCalls {
+			tc.Function.Arguments = compactJSON(tc.Function.Arguments)
+			calls[j] = tc
+		}
+		m.ToolCalls = calls
+		msgs[i] = m
+	}
+	req.Messages = msgs
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("replay: encode request: %w", err)
+	}
+	return string(b), nil
+}
+
+func normalizeText(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n"))
+}
+
+func compactJSON(raw json.RawMessage) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+func describeLast(msgs []llm.ChatMessage) string {
+	if len(msgs) == 0 {
+		return "none"
+	}
+	last := msgs[len(msgs)-1]
+	content := normalizeText(last.Content)
+	if len(content) > 80 {
+		content = content[:80] + "..."
+	}
+	return fmt.Sprintf("%s %q", last.Role, content)
+}
+
+func fromUsage(u llm.Usage) Usage {
+	return Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
+
+func toUsage(u Usage) llm.Usage {
+	return llm.Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
+
+func fromToolCalls(calls []llm.ToolCall) []ToolCall {
+	var out []ToolCall
+	for _, tc := range calls {
+		out = append(out, ToolCall{ID: tc.ID, Type: tc.Type, Name: tc.Function.Name, Arguments: string(tc.Function.Arguments)})
+	}
+	return out
+}
+
+func toToolCalls(calls []ToolCall) []llm.ToolCall {
+	var out []llm.ToolCall
+	for _, tc := range calls {
+		out = append(out, llm.ToolCall{
+			ID:       tc.ID,
+			Type:     tc.Type,
+			Function: llm.ToolFunctionCall{Name: tc.Name, Arguments: json.RawMessage(tc.Arguments)},
+		})
+	}
+	return out
+}
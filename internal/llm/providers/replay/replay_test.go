@@ -0,0 +1,101 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
+	llmmock "github.com/animus-coder/animus-coder/internal/llm/mock"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassettes", "session.json")
+	calls := 0
+	upstream := &llmmock.Provider{ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+		calls++
+		if calls == 1 {
+			return llm.ChatResponse{
+				Message: llm.ChatMessage{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{
+					ID:       "call_1",
+					Type:     "function",
+					Function: llm.ToolFunctionCall{Name: "fs.read_file", Arguments: json.RawMessage(`{ "path": "a.go" }`)},
+				}}},
+				FinishReason: "tool_calls",
+				Usage:        llm.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+			}, nil
+		}
+		return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "done [done]"}, FinishReason: "stop"}, nil
+	}}
+
+	first := llm.ChatRequest{
+		Model:       "m",
+		Temperature: 0.2,
+		Messages:    []llm.ChatMessage{{Role: llm.RoleSystem, Content: "sys"}, {Role: llm.RoleUser, Content: "read a.go"}},
+		Tools:       []llm.ToolDefinition{{Name: "fs.read_file"}},
+	}
+	rec, err := NewProvider("rec", path, ModeRecord, upstream)
+	require.NoError(t, err)
+	resp, err := rec.Chat(context.Background(), first)
+	require.NoError(t, err)
+	require.Equal(t, "tool_calls", resp.FinishReason)
+
+	second := first
+	second.Messages = append(append([]llm.ChatMessage{}, first.Messages...),
+		resp.Message,
+		llm.ChatMessage{Role: llm.RoleTool, Content: "package a", ToolCallID: "call_1"},
+	)
+	chunks, err := collect(rec.Stream(context.Background(), second))
+	require.NoError(t, err)
+	require.Equal(t, "done [done]", chunks[0].Content)
+
+	play, err := NewProvider("play", path, ModeReplay, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, play.Remaining())
+
+	// Sampling parameters and surrounding whitespace do not affect matching.
+	first.Temperature = 0.9
+	first.Messages[1].Content = "read a.go\r\n"
+	resp, err = play.Chat(context.Background(), first)
+	require.NoError(t, err)
+	require.Equal(t, "play", resp.ProviderName)
+	require.Equal(t, "tool_calls", resp.FinishReason)
+	require.Equal(t, llm.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}, resp.Usage)
+	require.Equal(t, "fs.read_file", resp.Message.ToolCalls[0].Function.Name)
+	require.JSONEq(t, `{"path":"a.go"}`, string(resp.Message.ToolCalls[0].Function.Arguments))
+
+	chunks, err = collect(play.Stream(context.Background(), second))
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+	require.Equal(t, "done [done]", chunks[0].Content)
+	require.Equal(t, "stop", chunks[1].FinishReason)
+	require.Zero(t, play.Remaining())
+	require.Equal(t, 2, calls, "replay must not reach the upstream provider")
+
+	// Every interaction is served once; asking again fails loudly.
+	_, err = play.Chat(context.Background(), first)
+	require.ErrorIs(t, err, ErrUnmatched)
+	require.ErrorContains(t, err, `last: user "read a.go"`)
+}
+
+func TestNewProviderValidation(t *testing.T) {
+	_, err := NewProvider("r", filepath.Join(t.TempDir(), "missing.json"), ModeReplay, nil)
+	require.ErrorContains(t, err, "read cassette")
+
+	_, err = NewProvider("r", "x.json", ModeRecord, nil)
+	require.ErrorContains(t, err, "upstream")
+
+	_, err = NewProvider("r", "x.json", Mode("rewind"), nil)
+	require.ErrorContains(t, err, "unknown mode")
+}
+
+func collect(ch <-chan llm.StreamChunk, errCh <-chan error) ([]llm.StreamChunk, error) {
+	var chunks []llm.StreamChunk
+	for c := range ch {
+		chunks = append(chunks, c)
+	}
+	return chunks, <-errCh
+}
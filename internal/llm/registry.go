@@ -9,6 +9,17 @@ type ModelRoute struct {
 	Model       string
 	Temperature float64
 	MaxTokens   int
+	// InputCostPerMTok and OutputCostPerMTok price the route in USD per million
+	// prompt and completion tokens; zero means unpriced.
+	InputCostPerMTok  float64
+	OutputCostPerMTok float64
+	// ContextWindow is the model's context size in tokens; zero means unknown.
+	ContextWindow int
+}
+
+// Cost returns the USD cost of usage at the route's prices.
+func (r ModelRoute) Cost(u Usage) float64 {
+	return (float64(u.PromptTokens)*r.InputCostPerMTok + float64(u.CompletionTokens)*r.OutputCostPerMTok) / 1e6
 }
 
 // Registry resolves models to providers and tracks metadata (expensive flags).
@@ -17,6 +28,7 @@ type Registry struct {
 	models       map[string]ModelRoute
 	defaultModel string
 	expensive    map[string]bool
+	breakers     map[string]*CircuitBreaker
 }
 
 // MarkExpensive marks a model as expensive for strategy accounting.
@@ -38,6 +50,7 @@ func NewRegistry() *Registry {
 		providers: make(map[string]Provider),
 		models:    make(map[string]ModelRoute),
 		expensive: make(map[string]bool),
+		breakers:  make(map[string]*CircuitBreaker),
 	}
 }
 
@@ -46,6 +59,47 @@ func (r *Registry) RegisterProvider(name string, p Provider) {
 	r.providers[name] = p
 }
 
+// SetCircuitBreaker guards a registered provider with a circuit breaker; calls fail fast
+// with ErrCircuitOpen while it is open and Available reports its models as unavailable.
+func (r *Registry) SetCircuitBreaker(name string, policy BreakerPolicy, observer CircuitObserver) error {
+	p, ok := r.providers[name]
+	if !ok {
+		return fmt.Errorf("provider %q not registered", name)
+	}
+	if bp, ok := p.(*breakerProvider); ok {
+		p = bp.inner
+	}
+	if r.breakers == nil {
+		r.breakers = make(map[string]*CircuitBreaker)
+	}
+	b := NewCircuitBreaker(name, policy, observer)
+	r.breakers[name] = b
+	r.providers[name] = &breakerProvider{inner: p, breaker: b}
+	return nil
+}
+
+// ProviderStates returns the circuit state of every provider (closed when no breaker is set).
+func (r *Registry) ProviderStates() map[string]CircuitState {
+	states := make(map[string]CircuitState, len(r.providers))
+	for name := range r.providers {
+		states[name] = CircuitClosed
+		if b, ok := r.breakers[name]; ok {
+			states[name] = b.State()
+		}
+	}
+	return states
+}
+
+// Available reports whether a model resolves and its provider circuit is not open.
+func (r *Registry) Available(modelName string) bool {
+	_, route, err := r.Resolve(modelName)
+	if err != nil {
+		return false
+	}
+	b, ok := r.breakers[route.Provider]
+	return !ok || b.State() != CircuitOpen
+}
+
 // RegisterModel adds a model route.
 func (r *Registry) RegisterModel(name string, route ModelRoute, isDefault bool) {
 	route.Name = name
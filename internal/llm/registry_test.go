@@ -30,17 +30,29 @@ func TestRegistryResolve(t *testing.T) {
 func TestBuildRegistryFromConfig(t *testing.T) {
 	cfg := &config.Config{
 		Providers: map[string]config.ProviderConfig{
-			"openai": {Type: "openai", BaseURL: "http://example.com"},
+			"openai":    {Type: "openai", BaseURL: "http://example.com"},
+			"anthropic": {Type: "anthropic"},
+			"gemini":    {Type: "gemini"},
 		},
 		Models: map[string]config.ModelConfig{
-			"main": {Provider: "openai", Model: "gpt-4o", Default: true},
+			"main":   {Provider: "openai", Model: "gpt-4o", Default: true},
+			"critic": {Provider: "anthropic", Model: "claude-sonnet"},
+			"fast":   {Provider: "gemini", Model: "gemini-1.5-flash"},
 		},
 	}
 
-	reg, err := configbuilder.BuildRegistryFromConfig(cfg)
+	reg, err := configbuilder.BuildRegistryFromConfig(cfg, nil)
 	require.NoError(t, err)
 
 	p, _, err := reg.Resolve("main")
 	require.NoError(t, err)
 	require.Equal(t, "openai", p.Name())
+
+	p, _, err = reg.Resolve("critic")
+	require.NoError(t, err)
+	require.Equal(t, "anthropic", p.Name())
+
+	p, _, err = reg.Resolve("fast")
+	require.NoError(t, err)
+	require.Equal(t, "gemini", p.Name())
 }
@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultRetryAttempts = 3
+	defaultRetryBase     = 500 * time.Millisecond
+	defaultRetryMax      = 30 * time.Second
+)
+
+// RetryPolicy controls how RetryProvider retries transient failures.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first; 1 disables retries.
+	MaxAttempts int
+	// BaseDelay is the backoff before the second attempt; it doubles on every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff. A Retry-After longer than MaxDelay is not waited out;
+	// the error is returned so the caller can fall back to another model.
+	MaxDelay time.Duration
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultRetryAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaultRetryBase
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultRetryMax
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	return p
+}
+
+// RetryObserver is notified about every retry attempt (implemented by observability.Metrics).
+type RetryObserver interface {
+	RecordProviderRetry(provider, reason string)
+}
+
+// RetryProvider decorates a Provider with jittered exponential backoff for retryable errors.
+type RetryProvider struct {
+	inner    Provider
+	policy   RetryPolicy
+	observer RetryObserver
+	sleep    func(context.Context, time.Duration) error
+}
+
+// NewRetryProvider wraps inner; zero policy fields fall back to defaults and observer may be nil.
+func NewRetryProvider(inner Provider, policy RetryPolicy, observer RetryObserver) *RetryProvider {
+	return &RetryProvider{
+		inner:    inner,
+		policy:   policy.withDefaults(),
+		observer: observer,
+		sleep:    sleepContext,
+	}
+}
+
+// Name returns the wrapped provider's name.
+func (p *RetryProvider) Name() string {
+	return p.inner.Name()
+}
+
+// Chat retries the wrapped Chat call until it succeeds, fails permanently or attempts run out.
+func (p *RetryProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := p.inner.Chat(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		delay, ok := p.retryDelay(ctx, err, attempt)
+		if !ok {
+			return resp, err
+		}
+		if err := p.sleep(ctx, delay); err != nil {
+			return ChatResponse{}, err
+		}
+	}
+}
+
+// Stream retries only failures that happen before the first chunk was forwarded;
+// once output reached the caller a retry would duplicate it, so the error is returned.
+func (p *RetryProvider) Stream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, <-chan error) {
+	ch := make(chan StreamChunk, 16)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+		defer close(errCh)
+
+		if err := p.stream(ctx, req, ch); err != nil {
+			errCh <- err
+		}
+	}()
+
+	return ch, errCh
+}
+
+func (p *RetryProvider) stream(ctx context.Context, req ChatRequest, ch chan<- StreamChunk) error {
+	for attempt := 1; ; attempt++ {
+		forwarded, err := p.forward(ctx, req, ch)
+		if err == nil || forwarded {
+			return err
+		}
+		delay, ok := p.retryDelay(ctx, err, attempt)
+		if !ok {
+			return err
+		}
+		if err := p.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *RetryProvider) forward(ctx context.Context, req ChatRequest, ch chan<- StreamChunk) (bool, error) {
+	chunks, errs := p.inner.Stream(ctx, req)
+	forwarded := false
+	for chunk := range chunks {
+		select {
+		case ch <- chunk:
+			forwarded = true
+		case <-ctx.Done():
+			return forwarded, ctx.Err()
+		}
+	}
+	return forwarded, <-errs
+}
+
+// retryDelay decides whether err after the given attempt is retried and how long to wait.
+func (p *RetryProvider) retryDelay(ctx context.Context, err error, attempt int) (time.Duration, bool) {
+	if ctx.Err() != nil || attempt >= p.policy.MaxAttempts {
+		return 0, false
+	}
+	class := ClassifyError(err)
+	if !class.Retryable() {
+		return 0, false
+	}
+
+	delay := backoffDelay(p.policy, attempt)
+	if after := retryAfter(err); after > 0 {
+		if after > p.policy.MaxDelay {
+			return 0, false
+		}
+		delay = after
+	}
+
+	if p.observer != nil {
+		p.observer.RecordProviderRetry(p.inner.Name(), string(class))
+	}
+	return delay, true
+}
+
+// backoffDelay returns BaseDelay*2^(attempt-1), capped at MaxDelay, with "equal jitter":
+// half of the delay is fixed and the other half is random.
+func backoffDelay(policy RetryPolicy, attempt int) time.Duration {
+	delay := policy.BaseDelay
+	for i := 1; i < attempt && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func retryAfter(err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
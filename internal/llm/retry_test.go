@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type flakyProvider struct {
+	errs   []error
+	calls  int
+	chunks []StreamChunk
+}
+
+func (f *flakyProvider) Name() string { return "flaky" }
+
+func (f *flakyProvider) next() error {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return f.errs[f.calls-1]
+	}
+	return nil
+}
+
+func (f *flakyProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if err := f.next(); err != nil {
+		return ChatResponse{}, err
+	}
+	return ChatResponse{Message: ChatMessage{Role: RoleAssistant, Content: "ok"}}, nil
+}
+
+func (f *flakyProvider) Stream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, <-chan error) {
+	ch := make(chan StreamChunk, len(f.chunks))
+	errCh := make(chan error, 1)
+	err := f.next()
+	for _, c := range f.chunks {
+		ch <- c
+	}
+	if err != nil {
+		errCh <- err
+	}
+	close(ch)
+	close(errCh)
+	return ch, errCh
+}
+
+type retryRecorder struct {
+	reasons []string
+}
+
+func (r *retryRecorder) RecordProviderRetry(provider, reason string) {
+	r.reasons = append(r.reasons, provider+":"+reason)
+}
+
+func newTestRetryProvider(inner Provider, policy RetryPolicy, obs RetryObserver) (*RetryProvider, *[]time.Duration) {
+	var waits []time.Duration
+	p := NewRetryProvider(inner, policy, obs)
+	p.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	return p, &waits
+}
+
+func TestRetryProviderChatRetriesTransientErrors(t *testing.T) {
+	inner := &flakyProvider{errs: []error{
+		&StatusError{Provider: "openai", StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Second},
+		&StatusError{Provider: "openai", StatusCode: http.StatusBadGateway},
+	}}
+	rec := &retryRecorder{}
+	p, waits := newTestRetryProvider(inner, RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second}, rec)
+
+	resp, err := p.Chat(context.Background(), ChatRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "ok", resp.Message.Content)
+	require.Equal(t, 3, inner.calls)
+	require.Equal(t, []string{"flaky:rate_limit", "flaky:server"}, rec.reasons)
+
+	require.Len(t, *waits, 2)
+	require.Equal(t, 2*time.Second, (*waits)[0], "Retry-After must be respected")
+	require.GreaterOrEqual(t, (*waits)[1], 100*time.Millisecond)
+	require.LessOrEqual(t, (*waits)[1], 200*time.Millisecond)
+}
+
+func TestRetryProviderStopsOnClientErrorAndAttemptLimit(t *testing.T) {
+	inner := &flakyProvider{errs: []error{&StatusError{Provider: "openai", StatusCode: http.StatusBadRequest}}}
+	p, waits := newTestRetryProvider(inner, RetryPolicy{MaxAttempts: 3}, nil)
+	_, err := p.Chat(context.Background(), ChatRequest{})
+	require.Error(t, err)
+	require.Equal(t, 1, inner.calls)
+	require.Empty(t, *waits)
+
+	overloaded := &StatusError{Provider: "anthropic", StatusCode: 529}
+	inner = &flakyProvider{errs: []error{overloaded, overloaded, overloaded}}
+	p, _ = newTestRetryProvider(inner, RetryPolicy{MaxAttempts: 2}, nil)
+	_, err = p.Chat(context.Background(), ChatRequest{})
+	require.ErrorIs(t, err, overloaded)
+	require.Equal(t, 2, inner.calls)
+}
+
+func TestRetryProviderGivesUpOnLongRetryAfter(t *testing.T) {
+	inner := &flakyProvider{errs: []error{&StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}}}
+	p, waits := newTestRetryProvider(inner, RetryPolicy{MaxAttempts: 3, MaxDelay: 10 * time.Second}, nil)
+	_, err := p.Chat(context.Background(), ChatRequest{})
+	require.Error(t, err)
+	require.Equal(t, 1, inner.calls)
+	require.Empty(t, *waits)
+}
+
+func TestRetryProviderStreamRetriesOnlyBeforeOutput(t *testing.T) {
+	inner := &flakyProvider{errs: []error{&StatusError{StatusCode: http.StatusServiceUnavailable}}}
+	p, _ := newTestRetryProvider(inner, RetryPolicy{MaxAttempts: 3}, nil)
+	chunks, err := collectStream(p.Stream(context.Background(), ChatRequest{}))
+	require.NoError(t, err)
+	require.Empty(t, chunks)
+	require.Equal(t, 2, inner.calls)
+
+	inner = &flakyProvider{errs: []error{errors.New("anthropic: stream error: overloaded_error: Overloaded")}, chunks: []StreamChunk{{Content: "partial"}}}
+	p, _ = newTestRetryProvider(inner, RetryPolicy{MaxAttempts: 3}, nil)
+	chunks, err = collectStream(p.Stream(context.Background(), ChatRequest{}))
+	require.ErrorContains(t, err, "overloaded_error")
+	require.Len(t, chunks, 1)
+	require.Equal(t, 1, inner.calls)
+}
+
+func TestClassifyError(t *testing.T) {
+	cases := map[ErrorClass]error{
+		ErrorRateLimited: &StatusError{StatusCode: 429},
+		ErrorOverloaded:  fmt.Errorf("wrapped: %w", &StatusError{StatusCode: 503}),
+		ErrorTimeout:     context.DeadlineExceeded,
+		ErrorServer:      &StatusError{StatusCode: 500},
+		ErrorClient:      &StatusError{StatusCode: 401},
+		ErrorUnknown:     errors.New("decode response: bad json"),
+	}
+	for want, err := range cases {
+		require.Equal(t, want, ClassifyError(err), err.Error())
+	}
+	require.True(t, ErrorTimeout.Retryable())
+	require.False(t, ErrorClient.Retryable())
+}
+
+func TestNewStatusErrorParsesRetryAfter(t *testing.T) {
+	res := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"7"}},
+		Body:       io.NopCloser(strings.NewReader("slow down")),
+	}
+	err := NewStatusError("openai", res)
+	require.Equal(t, "openai: status 429: slow down", err.Error())
+	require.Equal(t, 7*time.Second, err.RetryAfter)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, 30*time.Second, ParseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	require.Zero(t, ParseRetryAfter("soon", now))
+}
+
+func collectStream(ch <-chan StreamChunk, errCh <-chan error) ([]StreamChunk, error) {
+	var chunks []StreamChunk
+	for c := range ch {
+		chunks = append(chunks, c)
+	}
+	return chunks, <-errCh
+}
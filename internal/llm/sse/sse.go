@@ -0,0 +1,70 @@
+// Package sse decodes text/event-stream bodies used by streaming LLM APIs.
+package sse
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Event is a single server-sent event.
+type Event struct {
+	Name string
+	ID   string
+	Data string
+}
+
+// Reader decodes events incrementally from an event stream.
+type Reader struct {
+	scanner *bufio.Scanner
+}
+
+// NewReader wraps r; lines up to 1MiB are accepted to fit large deltas.
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &Reader{scanner: scanner}
+}
+
+// Next returns the next complete event. It returns io.EOF when the stream ends
+// cleanly between events and io.ErrUnexpectedEOF when it ends mid-event.
+func (r *Reader) Next() (Event, error) {
+	var (
+		evt     Event
+		data    []string
+		pending bool
+	)
+	for r.scanner.Scan() {
+		line := strings.TrimSuffix(r.scanner.Text(), "\r")
+		if line == "" {
+			if !pending {
+				continue
+			}
+			evt.Data = strings.Join(data, "\n")
+			return evt, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			evt.Name = value
+		case "id":
+			evt.ID = value
+		case "data":
+			data = append(data, value)
+		default:
+			continue
+		}
+		pending = true
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	if pending {
+		return Event{}, io.ErrUnexpectedEOF
+	}
+	return Event{}, io.EOF
+}
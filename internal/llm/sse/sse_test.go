@@ -0,0 +1,35 @@
+package sse
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderDecodesEvents(t *testing.T) {
+	t.Parallel()
+
+	r := NewReader(strings.NewReader(": keep-alive\n\nevent: delta\ndata: {\"a\":1}\n\ndata: line1\r\ndata: line2\r\n\r\n"))
+
+	evt, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, "delta", evt.Name)
+	require.Equal(t, `{"a":1}`, evt.Data)
+
+	evt, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, "line1\nline2", evt.Data)
+
+	_, err = r.Next()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestReaderReportsTruncatedEvent(t *testing.T) {
+	t.Parallel()
+
+	r := NewReader(strings.NewReader("data: {\"partial\""))
+	_, err := r.Next()
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
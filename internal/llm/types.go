@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"strings"
 )
 
 // Role is the message role used in chat exchanges.
@@ -38,10 +39,35 @@ type ToolFunctionCall struct {
 	Arguments json.RawMessage `json:"arguments"`
 }
 
+// ToolDefinition describes a tool offered to the model for native function calling.
+type ToolDefinition struct {
+	Name        string
+	Description string
+	// Parameters is a JSON schema object describing the call arguments.
+	Parameters map[string]interface{}
+}
+
+// Agent roles issuing chat requests, carried in ChatRequest.Role.
+const (
+	AgentRolePlanner    = "planner"
+	AgentRoleReplanner  = "replanner"
+	AgentRoleCoder      = "coder"
+	AgentRoleCritic     = "critic"
+	AgentRoleClassifier = "classifier"
+	AgentRoleSummarizer = "summarizer"
+)
+
+// AgentRoles lists every agent role.
+var AgentRoles = []string{AgentRolePlanner, AgentRoleReplanner, AgentRoleCoder, AgentRoleCritic, AgentRoleClassifier, AgentRoleSummarizer}
+
 // ChatRequest is the input for chat providers.
 type ChatRequest struct {
+	// Role is the agent role issuing the request, one of AgentRoles. Providers do not
+	// send it; scripted providers key on it.
+	Role        string
 	Model       string
 	Messages    []ChatMessage
+	Tools       []ToolDefinition
 	MaxTokens   int
 	Temperature float64
 	Stream      bool
@@ -64,10 +90,13 @@ type ChatResponse struct {
 	Model        string
 }
 
-// StreamChunk is emitted during streaming responses.
+// StreamChunk is emitted during streaming responses. FinishReason, Usage and the
+// assembled ToolCalls are only set on the final chunk of a stream.
 type StreamChunk struct {
 	Content      string
+	ToolCalls    []ToolCall
 	FinishReason string
+	Usage        Usage
 	Err          error
 }
 
@@ -77,3 +106,14 @@ type Provider interface {
 	Chat(ctx context.Context, req ChatRequest) (ChatResponse, error)
 	Stream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, <-chan error)
 }
+
+// FunctionName encodes a dotted tool name ("fs.read_file") into the
+// [a-zA-Z0-9_-] alphabet required by function-calling APIs ("fs__read_file").
+func FunctionName(tool string) string {
+	return strings.ReplaceAll(tool, ".", "__")
+}
+
+// ToolName reverses FunctionName.
+func ToolName(function string) string {
+	return strings.ReplaceAll(function, "__", ".")
+}
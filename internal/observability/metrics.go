@@ -16,6 +16,10 @@ type Metrics struct {
 	TransportErrs *prometheus.CounterVec
 	ModelUsage    *prometheus.CounterVec
 	ModelFailures *prometheus.CounterVec
+	ProviderRetry *prometheus.CounterVec
+	CircuitState  *prometheus.GaugeVec
+	LLMTokens     *prometheus.CounterVec
+	LLMCost       *prometheus.CounterVec
 }
 
 // NewMetrics constructs a metrics registry with agent collectors.
@@ -35,7 +39,7 @@ func NewMetrics() *Metrics {
 
 	tokens := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "mycodex_agent_tokens_total",
-		Help: "Tokens (approx words) emitted by agent",
+		Help: "Provider-reported tokens consumed by agent runs",
 	}, []string{"finish_reason"})
 
 	active := prometheus.NewGaugeVec(prometheus.GaugeOpts{
@@ -58,7 +62,27 @@ func NewMetrics() *Metrics {
 		Help: "Model failures by role and model",
 	}, []string{"role", "model"})
 
-	reg.MustRegister(reqs, durs, tokens, active, trErrors, modelUsage, modelFailures)
+	providerRetries := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mycodex_provider_retries_total",
+		Help: "Provider call retries by provider and error class",
+	}, []string{"provider", "reason"})
+
+	circuitState := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mycodex_provider_circuit_state",
+		Help: "Provider circuit breaker state (0=closed, 1=half_open, 2=open)",
+	}, []string{"provider"})
+
+	llmTokens := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mycodex_llm_tokens_total",
+		Help: "Provider-reported tokens by role, model and type (prompt|completion)",
+	}, []string{"role", "model", "type"})
+
+	llmCost := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mycodex_llm_cost_usd_total",
+		Help: "Estimated model spend in USD by role and model",
+	}, []string{"role", "model"})
+
+	reg.MustRegister(reqs, durs, tokens, active, trErrors, modelUsage, modelFailures, providerRetries, circuitState, llmTokens, llmCost)
 
 	return &Metrics{
 		registry:      reg,
@@ -69,6 +93,10 @@ func NewMetrics() *Metrics {
 		TransportErrs: trErrors,
 		ModelUsage:    modelUsage,
 		ModelFailures: modelFailures,
+		ProviderRetry: providerRetries,
+		CircuitState:  circuitState,
+		LLMTokens:     llmTokens,
+		LLMCost:       llmCost,
 	}
 }
 
@@ -147,3 +175,51 @@ func (m *Metrics) RecordModelFailure(role, model string) {
 	}
 	m.ModelFailures.WithLabelValues(role, model).Inc()
 }
+
+// RecordProviderRetry increments the retry counter for a provider and error class.
+func (m *Metrics) RecordProviderRetry(provider, reason string) {
+	if m == nil {
+		return
+	}
+	if provider == "" {
+		provider = "unknown"
+	}
+	if reason == "" {
+		reason = "unknown"
+	}
+	m.ProviderRetry.WithLabelValues(provider, reason).Inc()
+}
+
+// RecordCircuitState sets the circuit breaker gauge for a provider (closed, half_open or open).
+func (m *Metrics) RecordCircuitState(provider, state string) {
+	if m == nil {
+		return
+	}
+	if provider == "" {
+		provider = "unknown"
+	}
+	var v float64
+	switch state {
+	case "half_open":
+		v = 1
+	case "open":
+		v = 2
+	}
+	m.CircuitState.WithLabelValues(provider).Set(v)
+}
+
+// RecordTokenUsage adds provider-reported token usage and its cost for a role/model.
+func (m *Metrics) RecordTokenUsage(role, model string, promptTokens, completionTokens int, costUSD float64) {
+	if m == nil {
+		return
+	}
+	if role == "" {
+		role = "unknown"
+	}
+	if model == "" {
+		model = "unknown"
+	}
+	m.LLMTokens.WithLabelValues(role, model, "prompt").Add(float64(promptTokens))
+	m.LLMTokens.WithLabelValues(role, model, "completion").Add(float64(completionTokens))
+	m.LLMCost.WithLabelValues(role, model).Add(costUSD)
+}
@@ -0,0 +1,181 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/animus-coder/animus-coder/internal/rpc"
+	"github.com/animus-coder/animus-coder/internal/tools"
+)
+
+// Approval decisions.
+const (
+	decisionApprove = "approve"
+	decisionDeny    = "deny"
+	decisionEdit    = "edit"
+)
+
+// ApprovalRunner is a Runner whose tool calls may wait for a human decision.
+type ApprovalRunner interface {
+	Runner
+	// Approve answers the open approval request of the run with the correlation ID.
+	Approve(correlationID string, d rpc.ApprovalDecision) error
+}
+
+// errRunNotFound is returned by Approve when no run with the correlation ID waits for
+// decisions.
+var errRunNotFound = errors.New("no run awaiting approval")
+
+// approvalGate holds the open approval request of a run.
+type approvalGate struct {
+	sessionID string
+
+	mu       sync.Mutex
+	pending  string                    // id of the open request, if any
+	decision chan rpc.ApprovalDecision // receives the decision on the open request
+}
+
+// deliver answers the open request when d carries its id or no id.
+func (g *approvalGate) deliver(d rpc.ApprovalDecision) error {
+	if d.SessionID != "" && d.SessionID != g.sessionID {
+		return fmt.Errorf("approval for session %s sent to run of session %s", d.SessionID, g.sessionID)
+	}
+	switch d.Decision {
+	case decisionApprove, decisionDeny:
+	case decisionEdit:
+		if len(d.Args) == 0 {
+			return errors.New("edit decision requires args")
+		}
+	default:
+		return fmt.Errorf("unknown decision %q (want approve, deny or edit)", d.Decision)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.pending == "" {
+		return errors.New("no approval request is open")
+	}
+	if d.ApprovalID != "" && d.ApprovalID != g.pending {
+		return fmt.Errorf("approval request %s is not open", d.ApprovalID)
+	}
+	g.decision <- d
+	g.pending = ""
+	return nil
+}
+
+// await opens request id and waits for its decision, the timeout (0 = none) or ctx.
+func (g *approvalGate) await(ctx context.Context, id string, timeout time.Duration) (rpc.ApprovalDecision, error) {
+	decision := make(chan rpc.ApprovalDecision, 1)
+	g.mu.Lock()
+	g.pending, g.decision = id, decision
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		if g.pending == id {
+			g.pending = ""
+		}
+		g.mu.Unlock()
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case d := <-decision:
+		return d, nil
+	case <-expired:
+		return rpc.ApprovalDecision{}, errNoAnswer
+	case <-ctx.Done():
+		return rpc.ApprovalDecision{}, ctx.Err()
+	}
+}
+
+// Approve implements ApprovalRunner.
+func (r *AgentRunner) Approve(correlationID string, d rpc.ApprovalDecision) error {
+	r.gatesMu.Lock()
+	gate := r.gates[correlationID]
+	r.gatesMu.Unlock()
+	if gate == nil {
+		return fmt.Errorf("%w with correlation id %s", errRunNotFound, correlationID)
+	}
+	return gate.deliver(d)
+}
+
+// openGate registers the approval gate of a run.
+func (r *AgentRunner) openGate(correlationID, sessionID string) (*approvalGate, error) {
+	r.gatesMu.Lock()
+	defer r.gatesMu.Unlock()
+
+	if _, ok := r.gates[correlationID]; ok {
+		return nil, fmt.Errorf("run with correlation id %s is already in progress", correlationID)
+	}
+	if r.gates == nil {
+		r.gates = make(map[string]*approvalGate)
+	}
+	gate := &approvalGate{sessionID: sessionID}
+	r.gates[correlationID] = gate
+	return gate, nil
+}
+
+func (r *AgentRunner) closeGate(correlationID string) {
+	r.gatesMu.Lock()
+	defer r.gatesMu.Unlock()
+
+	delete(r.gates, correlationID)
+}
+
+// approveCall asks the client to approve tc when the policy requires it and returns the
+// call to run, with the client's arguments after an edit. A denial or timeout is returned
+// as an error, which the model sees as the tool result.
+func (r *AgentRunner) approveCall(ctx context.Context, gate *approvalGate, out chan<- rpc.RunTaskEvent, req rpc.RunTaskRequest, corr string, step int, tc rpc.ToolCall, n int) (rpc.ToolCall, error) {
+	reason, ok := r.Approvals.Requires(tc.Name, tc.Args)
+	if !ok {
+		return tc, nil
+	}
+	if gate == nil {
+		return tc, fmt.Errorf("%s requires approval", tc.Name)
+	}
+	id := tc.ID
+	if id == "" {
+		id = fmt.Sprintf("%s-a%d", corr, n)
+	}
+	diff, err := tools.PreviewChange(r.Tools, tc.Name, tc.Args)
+	if err != nil {
+		r.logf("preview %s for approval: %v", tc.Name, err)
+	}
+	out <- rpc.RunTaskEvent{Type: "approval_request", SessionID: req.SessionID, CorrelationID: corr, Message: reason, ApprovalID: id, ToolName: tc.Name, ToolArgs: tc.Args, Diff: diff, Step: step}
+
+	timeout := r.Approvals.Timeout
+	d, err := gate.await(ctx, id, timeout)
+	evt := rpc.RunTaskEvent{Type: "approval", SessionID: req.SessionID, CorrelationID: corr, ApprovalID: id, ToolName: tc.Name, Step: step}
+	switch {
+	case errors.Is(err, errNoAnswer):
+		evt.Decision = decisionDeny
+		evt.Message = fmt.Sprintf("no decision within %s", timeout)
+		out <- evt
+		return tc, fmt.Errorf("%s was not approved within %s and did not run", tc.Name, timeout)
+	case err != nil:
+		return tc, err
+	}
+
+	evt.Decision, evt.Message = d.Decision, d.Reason
+	if d.Decision == decisionDeny {
+		out <- evt
+		if d.Reason != "" {
+			return tc, fmt.Errorf("the user denied %s: %s", tc.Name, d.Reason)
+		}
+		return tc, fmt.Errorf("the user denied %s", tc.Name)
+	}
+	if d.Decision == decisionEdit {
+		tc.Args = d.Args
+	}
+	evt.ToolArgs = tc.Args
+	out <- evt
+	return tc, nil
+}
@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/animus-coder/animus-coder/internal/config"
+	"github.com/animus-coder/animus-coder/internal/llm"
+	llmmock "github.com/animus-coder/animus-coder/internal/llm/mock"
+	"github.com/animus-coder/animus-coder/internal/rpc"
+	"github.com/animus-coder/animus-coder/internal/tools"
+)
+
+// writeCallsProvider asks for the write_file calls on the first coder call and records
+// the tool results it gets back on the second.
+func writeCallsProvider(results *[]string, calls ...llm.ToolCall) *llmmock.Provider {
+	call := 0
+	return &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			call++
+			if call == 1 {
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, ToolCalls: calls}, FinishReason: "tool_calls"}, nil
+			}
+			for _, m := range req.Messages {
+				if m.Role == llm.RoleTool {
+					*results = append(*results, m.Content)
+				}
+			}
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "[done]"}, FinishReason: "stop"}, nil
+		},
+	}
+}
+
+func writeCall(id, path, content string) llm.ToolCall {
+	args := `{"path":"` + path + `","content":"` + content + `"}`
+	return llm.ToolCall{ID: id, Type: "function", Function: llm.ToolFunctionCall{Name: "fs.write_file", Arguments: []byte(args)}}
+}
+
+func newApprovalRunner(t *testing.T, dir string, results *[]string, timeout time.Duration, calls ...llm.ToolCall) *AgentRunner {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644))
+	fsTool, err := tools.NewFilesystem(dir, true)
+	require.NoError(t, err)
+
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", writeCallsProvider(results, calls...))
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+	return &AgentRunner{
+		Agent: regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 2}),
+		Tools: tools.NewRegistry(fsTool, nil, nil, nil),
+		Approvals: tools.NewApprovalPolicy(config.ApprovalConfig{
+			Rules:   []config.ApprovalRule{{Tool: "fs.write_file", ExceptPaths: []string{"docs"}}},
+			Timeout: timeout,
+		}),
+	}
+}
+
+func TestAgentRunnerWaitsForApprovalDecisions(t *testing.T) {
+	dir := t.TempDir()
+	var results []string
+	ar := newApprovalRunner(t, dir, &results, time.Minute,
+		writeCall("w1", "main.go", "package main // denied"),
+		writeCall("w2", "docs/notes.md", "notes"),
+		writeCall("w3", "main.go", "package main // proposed"),
+	)
+
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "gate", CorrelationID: "gate-corr", Prompt: "edit main"})
+	require.NoError(t, err)
+
+	var requests, decisions []rpc.RunTaskEvent
+	for ev := range ch {
+		switch ev.Type {
+		case "approval_request":
+			requests = append(requests, ev)
+			switch ev.ApprovalID {
+			case "w1":
+				require.ErrorContains(t, ar.Approve("gate-corr", rpc.ApprovalDecision{ApprovalID: "w3", Decision: "approve"}), "not open")
+				require.NoError(t, ar.Approve("gate-corr", rpc.ApprovalDecision{ApprovalID: "w1", Decision: "deny", Reason: "keep main.go as is"}))
+			case "w3":
+				require.NoError(t, ar.Approve("gate-corr", rpc.ApprovalDecision{Decision: "edit", Args: map[string]interface{}{"path": "main.go", "content": "package main // edited"}}))
+			}
+		case "approval":
+			decisions = append(decisions, ev)
+		case "error":
+			t.Fatalf("unexpected error event: %s", ev.Error)
+		}
+	}
+
+	require.Len(t, requests, 2, "writes under docs/ run without approval")
+	require.Equal(t, "fs.write_file", requests[0].ToolName)
+	require.Equal(t, "main.go", requests[0].ToolArgs["path"])
+	require.Contains(t, requests[0].Diff, "+package main // denied")
+	require.Contains(t, requests[0].Message, "outside docs")
+
+	require.Len(t, decisions, 2)
+	require.Equal(t, "deny", decisions[0].Decision)
+	require.Equal(t, "edit", decisions[1].Decision)
+	require.Equal(t, "package main // edited", decisions[1].ToolArgs["content"])
+
+	require.Equal(t, []string{"error: the user denied fs.write_file: keep main.go as is", "ok", "ok"}, results)
+	data, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+	require.Equal(t, "package main // edited", string(data))
+	require.ErrorIs(t, ar.Approve("gate-corr", rpc.ApprovalDecision{Decision: "approve"}), errRunNotFound)
+}
+
+func TestAgentRunnerDeniesUnansweredApprovalOverNDJSON(t *testing.T) {
+	dir := t.TempDir()
+	var results []string
+	ar := newApprovalRunner(t, dir, &results, 50*time.Millisecond,
+		writeCall("w1", "main.go", "package main // approved"),
+		writeCall("w2", "main.go", "package main // unanswered"),
+	)
+	approvals := NewApprovalHandler(ar, nil)
+	post := func(body string) int {
+		rr := httptest.NewRecorder()
+		approvals.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/agent/approve", bytes.NewBufferString(body)))
+		return rr.Code
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "ndjson", CorrelationID: "ndjson-corr", Prompt: "edit main"})
+	require.NoError(t, err)
+
+	var decisions []rpc.RunTaskEvent
+	for ev := range ch {
+		switch ev.Type {
+		case "approval_request":
+			if ev.ApprovalID == "w1" {
+				require.Equal(t, http.StatusBadRequest, post(`{"decision":"approve"}`))
+				require.Equal(t, http.StatusNotFound, post(`{"correlation_id":"other","decision":"approve"}`))
+				require.Equal(t, http.StatusConflict, post(`{"correlation_id":"ndjson-corr","decision":"maybe"}`))
+				require.Equal(t, http.StatusNoContent, post(`{"correlation_id":"ndjson-corr","approval_id":"w1","decision":"approve"}`))
+			}
+		case "approval":
+			decisions = append(decisions, ev)
+		}
+	}
+
+	require.Len(t, decisions, 2)
+	require.Equal(t, "approve", decisions[0].Decision)
+	require.Equal(t, "deny", decisions[1].Decision)
+	require.Equal(t, "no decision within 50ms", decisions[1].Message)
+	require.Len(t, results, 2)
+	require.Equal(t, "ok", results[0])
+	require.Contains(t, results[1], "was not approved within 50ms and did not run")
+	data, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+	require.Equal(t, "package main // approved", string(data))
+}
@@ -58,7 +58,20 @@ func (h *connectRunHandler) handle(ctx context.Context, stream *connect.BidiStre
 		req.CorrelationID = req.SessionID + "-corr"
 	}
 
-	// Listen for cancellation messages from the client.
+	httpReq := &http.Request{}
+	httpReq = httpReq.WithContext(ctx)
+
+	events, runErr := h.runner.Run(httpReq, req)
+	if runErr != nil {
+		if h.metrics != nil {
+			h.metrics.RecordTransportError("connect", "runner_error")
+		}
+		return connect.NewError(connect.CodeInternal, runErr)
+	}
+
+	// Listen for cancellation, approval decisions and, on interactive runs, user input
+	// from the client.
+	// The run's inbox is registered by Run, so input is only read once it has started.
 	go func() {
 		for {
 			msg, recvErr := stream.Receive()
@@ -69,24 +82,22 @@ func (h *connectRunHandler) handle(ctx context.Context, stream *connect.BidiStre
 				cancel()
 				return
 			}
-			if msg != nil && msg.Cancel {
+			if msg == nil {
+				continue
+			}
+			if msg.Cancel {
 				cancel()
 				return
 			}
+			if msg.Input != nil {
+				h.deliverInput(req.CorrelationID, *msg.Input)
+			}
+			if msg.Approval != nil {
+				h.deliverApproval(req.CorrelationID, *msg.Approval)
+			}
 		}
 	}()
 
-	httpReq := &http.Request{}
-	httpReq = httpReq.WithContext(ctx)
-
-	events, runErr := h.runner.Run(httpReq, req)
-	if runErr != nil {
-		if h.metrics != nil {
-			h.metrics.RecordTransportError("connect", "runner_error")
-		}
-		return connect.NewError(connect.CodeInternal, runErr)
-	}
-
 	for ev := range events {
 		if err := stream.Send(&ev); err != nil {
 			if h.metrics != nil {
@@ -97,3 +108,28 @@ func (h *connectRunHandler) handle(ctx context.Context, stream *connect.BidiStre
 	}
 	return nil
 }
+
+// deliverInput forwards user input to the run. Input the run cannot take (not interactive,
+// no open question with that id) is dropped and counted as a transport error.
+func (h *connectRunHandler) deliverInput(correlationID string, in rpc.UserInput) {
+	ir, ok := h.runner.(InputRunner)
+	if ok && ir.Input(correlationID, in) == nil {
+		return
+	}
+	if h.metrics != nil {
+		h.metrics.RecordTransportError("connect", "input")
+	}
+}
+
+// deliverApproval forwards an approval decision to the run. Decisions the run cannot take
+// (no approval policy, no open request with that id) are dropped and counted as a
+// transport error.
+func (h *connectRunHandler) deliverApproval(correlationID string, d rpc.ApprovalDecision) {
+	ar, ok := h.runner.(ApprovalRunner)
+	if ok && ar.Approve(correlationID, d) == nil {
+		return
+	}
+	if h.metrics != nil {
+		h.metrics.RecordTransportError("connect", "approval")
+	}
+}
@@ -3,6 +3,7 @@ package agent
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -93,6 +94,58 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ApprovalHandler takes approval decisions for NDJSON runs, whose stream cannot carry
+// them back to the daemon.
+type ApprovalHandler struct {
+	runner  Runner
+	metrics *observability.Metrics
+}
+
+// NewApprovalHandler constructs an approval handler instance.
+func NewApprovalHandler(runner Runner, metrics *observability.Metrics) *ApprovalHandler {
+	return &ApprovalHandler{runner: runner, metrics: metrics}
+}
+
+// ServeHTTP handles POST /agent/approve with an rpc.ApprovalDecision naming the run by
+// correlation_id. It answers 204 once the decision is delivered, 404 when no such run
+// waits for decisions and 409 when the run cannot take the decision.
+func (h *ApprovalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.fail(w, "method_not_allowed", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var d rpc.ApprovalDecision
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		h.fail(w, "decode", fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if d.CorrelationID == "" {
+		h.fail(w, "decode", "correlation_id is required", http.StatusBadRequest)
+		return
+	}
+	ar, ok := h.runner.(ApprovalRunner)
+	if !ok {
+		h.fail(w, "approval", "runner does not take approvals", http.StatusNotFound)
+		return
+	}
+	if err := ar.Approve(d.CorrelationID, d); err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, errRunNotFound) {
+			status = http.StatusNotFound
+		}
+		h.fail(w, "approval", err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ApprovalHandler) fail(w http.ResponseWriter, kind, msg string, status int) {
+	if h.metrics != nil {
+		h.metrics.RecordTransportError("ndjson", kind)
+	}
+	http.Error(w, msg, status)
+}
+
 // runTaskEcho simulates an agent run by emitting token events.
 func runTaskEcho(req rpc.RunTaskRequest) <-chan rpc.RunTaskEvent {
 	out := make(chan rpc.RunTaskEvent, 16)
@@ -112,7 +165,7 @@ func runTaskEcho(req rpc.RunTaskRequest) <-chan rpc.RunTaskEvent {
 				Type:          "token",
 				SessionID:     req.SessionID,
 				CorrelationID: req.CorrelationID,
-				Token:         w,
+				Token:         w + " ",
 				Step:          i + 1,
 			}
 		}
@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/animus-coder/animus-coder/internal/llm"
+	"github.com/animus-coder/animus-coder/internal/rpc"
+)
+
+// askUserTool lets the coder of an interactive run ask the client a question.
+const askUserTool = "user.ask"
+
+// InputRunner is a Runner that accepts user input for interactive runs in progress.
+type InputRunner interface {
+	Runner
+	// Input delivers in to the run with the correlation ID.
+	Input(correlationID string, in rpc.UserInput) error
+}
+
+// askUserDefinition is offered to the coder of interactive runs.
+var askUserDefinition = llm.ToolDefinition{
+	Name:        askUserTool,
+	Description: "Ask the user a clarifying question and wait for the answer. Use it only when the task cannot continue without a decision from the user.",
+	Parameters: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"question": map[string]interface{}{"type": "string", "description": "The question for the user"},
+		},
+		"required": []string{"question"},
+	},
+}
+
+// runInbox holds the input a client sent to an interactive run.
+type runInbox struct {
+	sessionID string
+
+	mu       sync.Mutex
+	queued   []string
+	question string      // id of the open question, if any
+	answer   chan string // receives the answer to the open question
+}
+
+// deliver answers the open question when in carries its id, or carries no id while a
+// question is open; other input is queued for the next step.
+func (b *runInbox) deliver(in rpc.UserInput) error {
+	if in.SessionID != "" && in.SessionID != b.sessionID {
+		return fmt.Errorf("input for session %s sent to run of session %s", in.SessionID, b.sessionID)
+	}
+	if strings.TrimSpace(in.Text) == "" {
+		return errors.New("input text is empty")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.question != "" && (in.QuestionID == "" || in.QuestionID == b.question) {
+		b.answer <- in.Text
+		b.question = ""
+		return nil
+	}
+	if in.QuestionID != "" {
+		return fmt.Errorf("question %s is not open", in.QuestionID)
+	}
+	b.queued = append(b.queued, in.Text)
+	return nil
+}
+
+// drain returns and clears the queued input.
+func (b *runInbox) drain() []string {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	queued := b.queued
+	b.queued = nil
+	return queued
+}
+
+// inboxInput returns the input to add before step; the first step sends the prompt and
+// leaves input queued.
+func inboxInput(b *runInbox, step int) []string {
+	if step == 1 {
+		return nil
+	}
+	return b.drain()
+}
+
+// pending reports whether input is waiting for the next step.
+func (b *runInbox) pending() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.queued) > 0
+}
+
+// errNoAnswer is returned by await when the question timed out.
+var errNoAnswer = errors.New("no answer")
+
+// await opens question id and waits for its answer, the timeout (0 = none) or ctx.
+func (b *runInbox) await(ctx context.Context, id string, timeout time.Duration) (string, error) {
+	answer := make(chan string, 1)
+	b.mu.Lock()
+	b.question, b.answer = id, answer
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		if b.question == id {
+			b.question = ""
+		}
+		b.mu.Unlock()
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case text := <-answer:
+		return text, nil
+	case <-expired:
+		return "", errNoAnswer
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Input implements InputRunner.
+func (r *AgentRunner) Input(correlationID string, in rpc.UserInput) error {
+	r.inboxMu.Lock()
+	inbox := r.inboxes[correlationID]
+	r.inboxMu.Unlock()
+	if inbox == nil {
+		return fmt.Errorf("no interactive run with correlation id %s", correlationID)
+	}
+	return inbox.deliver(in)
+}
+
+// openInbox registers the inbox of an interactive run.
+func (r *AgentRunner) openInbox(correlationID, sessionID string) (*runInbox, error) {
+	r.inboxMu.Lock()
+	defer r.inboxMu.Unlock()
+
+	if _, ok := r.inboxes[correlationID]; ok {
+		return nil, fmt.Errorf("interactive run with correlation id %s is already in progress", correlationID)
+	}
+	if r.inboxes == nil {
+		r.inboxes = make(map[string]*runInbox)
+	}
+	inbox := &runInbox{sessionID: sessionID}
+	r.inboxes[correlationID] = inbox
+	return inbox, nil
+}
+
+func (r *AgentRunner) closeInbox(correlationID string) {
+	r.inboxMu.Lock()
+	defer r.inboxMu.Unlock()
+
+	delete(r.inboxes, correlationID)
+}
+
+// askUser streams the question of a user.ask call and waits for the answer, which
+// becomes the tool result. Without an answer in time the coder is told to go on.
+func (r *AgentRunner) askUser(ctx context.Context, inbox *runInbox, out chan<- rpc.RunTaskEvent, req rpc.RunTaskRequest, corr string, step int, tc rpc.ToolCall, n int) (string, error) {
+	if inbox == nil {
+		return "", fmt.Errorf("%s is only available in interactive runs", askUserTool)
+	}
+	question, _ := tc.Args["question"].(string)
+	if question == "" {
+		return "", fmt.Errorf("%s requires a question", askUserTool)
+	}
+	id := tc.ID
+	if id == "" {
+		id = fmt.Sprintf("%s-q%d", corr, n)
+	}
+	out <- rpc.RunTaskEvent{Type: "question", SessionID: req.SessionID, CorrelationID: corr, Message: question, QuestionID: id, Step: step}
+
+	answer, err := inbox.await(ctx, id, r.Agent.QuestionTimeout())
+	if errors.Is(err, errNoAnswer) {
+		return fmt.Sprintf("The user did not answer within %s. Continue with your best judgement and state the assumption you made.", r.Agent.QuestionTimeout()), nil
+	}
+	return answer, err
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/animus-coder/animus-coder/internal/config"
+	"github.com/animus-coder/animus-coder/internal/llm"
+	llmmock "github.com/animus-coder/animus-coder/internal/llm/mock"
+	"github.com/animus-coder/animus-coder/internal/rpc"
+)
+
+func TestAgentRunnerAddsMidRunInputBeforeNextStep(t *testing.T) {
+	ar := &AgentRunner{}
+	var lastMessages []string
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			last := req.Messages[len(req.Messages)-1]
+			lastMessages = append(lastMessages, last.Content)
+			if len(lastMessages) == 1 {
+				// The user sends a correction while the first step is running.
+				require.NoError(t, ar.Input("input-corr", rpc.UserInput{SessionID: "input", Text: "use snake_case names"}))
+			}
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "renamed [done]"}, FinishReason: "stop"}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+	ar.Agent = regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 3})
+
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "input", CorrelationID: "input-corr", Prompt: "rename the helpers", Interactive: true})
+	require.NoError(t, err)
+
+	var inputs []rpc.RunTaskEvent
+	var done rpc.RunTaskEvent
+	for ev := range ch {
+		switch ev.Type {
+		case "input":
+			inputs = append(inputs, ev)
+		case "done":
+			done = ev
+		}
+	}
+	require.Len(t, inputs, 1)
+	require.Equal(t, "use snake_case names", inputs[0].Message)
+	require.Equal(t, 2, inputs[0].Step)
+	require.Equal(t, 2, done.Step, "input sent during the final step keeps the run going")
+	require.Len(t, lastMessages, 2)
+	require.Contains(t, lastMessages[1], "Message from the user during the run")
+	require.Contains(t, lastMessages[1], "use snake_case names")
+
+	require.ErrorContains(t, ar.Input("input-corr", rpc.UserInput{Text: "late"}), "no interactive run")
+}
+
+func TestAgentRunnerPausesOnQuestionUntilAnswered(t *testing.T) {
+	var offered []string
+	var toolResults []string
+	call := 0
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			call++
+			if call == 1 {
+				for _, tool := range req.Tools {
+					offered = append(offered, tool.Name)
+				}
+				return llm.ChatResponse{
+					Message: llm.ChatMessage{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{
+						{ID: "ask-1", Type: "function", Function: llm.ToolFunctionCall{Name: "user.ask", Arguments: []byte(`{"question":"Which database?"}`)}},
+						{ID: "ask-2", Type: "function", Function: llm.ToolFunctionCall{Name: "user.ask", Arguments: []byte(`{"question":"Keep the old schema?"}`)}},
+					}},
+					FinishReason: "tool_calls",
+				}, nil
+			}
+			for _, m := range req.Messages {
+				if m.Role == llm.RoleTool {
+					toolResults = append(toolResults, m.Content)
+				}
+			}
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "[done]"}, FinishReason: "stop"}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+	ar := &AgentRunner{Agent: regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 2, QuestionTimeout: 50 * time.Millisecond})}
+
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "ask", Prompt: "migrate the store", Interactive: true})
+	require.NoError(t, err)
+
+	var questions []rpc.RunTaskEvent
+	for ev := range ch {
+		if ev.Type == "question" {
+			questions = append(questions, ev)
+			if ev.QuestionID == "ask-1" {
+				require.ErrorContains(t, ar.Input("ask", rpc.UserInput{QuestionID: "ask-9", Text: "?"}), "not open")
+				require.NoError(t, ar.Input("ask", rpc.UserInput{QuestionID: "ask-1", Text: "postgres"}))
+			}
+		}
+	}
+	require.Contains(t, offered, "user.ask")
+	require.Len(t, questions, 2)
+	require.Equal(t, "Which database?", questions[0].Message)
+	require.Len(t, toolResults, 2)
+	require.Equal(t, "postgres", toolResults[0])
+	require.Contains(t, toolResults[1], "did not answer within 50ms", "an unanswered question times out")
+}
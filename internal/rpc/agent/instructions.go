@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/animus-coder/animus-coder/internal/agent"
+	"github.com/animus-coder/animus-coder/internal/config"
+	"github.com/animus-coder/animus-coder/internal/tools"
+)
+
+// DiscoverInstructions loads the configured instruction files (AGENTS.md and the like)
+// found in every directory from the workspace root down to the directories a run touches:
+// the context paths and the paths mentioned in the prompt. Outer directories come first,
+// so deeper files refine them. cfg.MaxBytes is spent on the deepest directories first,
+// since they are the most specific; the outermost files are truncated or dropped.
+func DiscoverInstructions(reg *tools.Registry, prompt string, paths []string, cfg config.InstructionsConfig) ([]agent.ContextFile, error) {
+	if reg == nil || reg.FS == nil || len(cfg.Files) == 0 {
+		return nil, nil
+	}
+
+	dirs := instructionDirs(reg.FS, append(append([]string{}, paths...), extractMentionedPaths(prompt)...))
+	groups := make([][]agent.ContextFile, len(dirs))
+	total := 0
+budget:
+	for i := len(dirs) - 1; i >= 0; i-- {
+		for _, name := range cfg.Files {
+			p := filepath.ToSlash(filepath.Join(dirs[i], name))
+			info, err := reg.FS.Stat(p)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			content, err := reg.FS.ReadFile(p)
+			if err != nil {
+				return nil, fmt.Errorf("read instructions %s: %w", p, err)
+			}
+			if appendWithBudget(&groups[i], agent.ContextFile{Path: p, Content: content}, &total, cfg.MaxBytes, cfg.MaxBytes) {
+				break budget
+			}
+		}
+	}
+
+	var out []agent.ContextFile
+	for _, group := range groups {
+		out = append(out, group...)
+	}
+	return out, nil
+}
+
+// instructionDirs returns the workspace root and every directory on the way down to the
+// existing targets, shallowest first.
+func instructionDirs(fsTool *tools.Filesystem, targets []string) []string {
+	seen := map[string]struct{}{".": {}}
+	dirs := []string{"."}
+	for _, t := range targets {
+		info, err := fsTool.Stat(t)
+		if err != nil {
+			continue
+		}
+		dir := filepath.Clean(t)
+		if !info.IsDir() {
+			dir = filepath.Dir(dir)
+		}
+		if filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+			continue
+		}
+		for d := dir; d != "."; d = filepath.Dir(d) {
+			if _, ok := seen[d]; !ok {
+				seen[d] = struct{}{}
+				dirs = append(dirs, d)
+			}
+		}
+	}
+	sort.SliceStable(dirs, func(i, j int) bool {
+		di, dj := instructionDepth(dirs[i]), instructionDepth(dirs[j])
+		if di != dj {
+			return di < dj
+		}
+		return dirs[i] < dirs[j]
+	})
+	return dirs
+}
+
+func instructionDepth(dir string) int {
+	if dir == "." {
+		return 0
+	}
+	return strings.Count(filepath.ToSlash(dir), "/") + 1
+}
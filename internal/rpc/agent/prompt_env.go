@@ -0,0 +1,36 @@
+package agent
+
+import (
+	"github.com/animus-coder/animus-coder/internal/agent"
+	"github.com/animus-coder/animus-coder/internal/config"
+	"github.com/animus-coder/animus-coder/internal/tools"
+)
+
+// Workspace structure shown to prompt templates: shallow enough to stay cheap.
+const (
+	promptStructureDepth   = 2
+	promptStructureEntries = 100
+)
+
+// NewPromptEnv describes the configured workspace and its tools for prompt templates.
+func NewPromptEnv(cfg *config.Config, reg *tools.Registry) (agent.PromptEnv, error) {
+	structure, err := DescribeWorkspace(reg)
+	if err != nil {
+		return agent.PromptEnv{}, err
+	}
+	return agent.PromptEnv{
+		Workspace: cfg.Sandbox.WorkingDir,
+		Structure: structure,
+		Tools:     toolDefinitions(reg),
+		Sandbox:   agent.NewSandboxPolicy(cfg.Sandbox, cfg.Tools),
+	}, nil
+}
+
+// DescribeWorkspace returns the directory tree of the workspace root shown to prompt
+// templates, or "" without a filesystem tool.
+func DescribeWorkspace(reg *tools.Registry) (string, error) {
+	if reg == nil || reg.FS == nil {
+		return "", nil
+	}
+	return reg.FS.DescribeStructure(".", promptStructureDepth, promptStructureEntries)
+}
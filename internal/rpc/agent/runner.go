@@ -3,15 +3,18 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/animus-coder/animus-coder/internal/agent"
+	"github.com/animus-coder/animus-coder/internal/llm"
 	"github.com/animus-coder/animus-coder/internal/rpc"
 	"github.com/animus-coder/animus-coder/internal/tools"
 )
@@ -21,25 +24,57 @@ type AgentRunner struct {
 	Agent   *agent.Agent
 	Metrics interface {
 		RecordAgentRun(finishReason string, duration time.Duration, tokenCount int)
+		RecordTokenUsage(role, model string, promptTokens, completionTokens int, costUSD float64)
 		RecordModelUsage(role, model string)
 		RecordModelFailure(role, model string)
 	}
-	Tools    *tools.Registry
-	Strategy *agent.StrategyEngine
-	Logger   *zap.Logger
+	Tools     *tools.Registry
+	Approvals *tools.ApprovalPolicy // model tool calls that wait for a human decision
+	Strategy  *agent.StrategyEngine
+	Logger    *zap.Logger
+
+	inboxMu sync.Mutex
+	inboxes map[string]*runInbox // interactive runs by correlation id
+	gatesMu sync.Mutex
+	gates   map[string]*approvalGate // runs with an approval policy by correlation id
 }
 
-// Run executes the agent loop with step limits and emits word-based token events.
+// Run executes the agent loop with step limits, forwarding provider deltas as token events.
 func (r *AgentRunner) Run(reqCtx *http.Request, req rpc.RunTaskRequest) (<-chan rpc.RunTaskEvent, error) {
+	corr := req.CorrelationID
+	if corr == "" {
+		corr = req.SessionID
+	}
+	// The inbox and approval gate are registered before Run returns so input and
+	// decisions sent right after the run starts are not lost.
+	var inbox *runInbox
+	if req.Interactive {
+		var err error
+		if inbox, err = r.openInbox(corr, req.SessionID); err != nil {
+			return nil, err
+		}
+	}
+	var gate *approvalGate
+	if r.Approvals.Enabled() {
+		var err error
+		if gate, err = r.openGate(corr, req.SessionID); err != nil {
+			if inbox != nil {
+				r.closeInbox(corr)
+			}
+			return nil, err
+		}
+	}
+
 	out := make(chan rpc.RunTaskEvent, 16)
 	go func() {
 		defer close(out)
-		start := time.Now()
-		expensiveUsed := 0
-		corr := req.CorrelationID
-		if corr == "" {
-			corr = req.SessionID
+		if inbox != nil {
+			defer r.closeInbox(corr)
+		}
+		if gate != nil {
+			defer r.closeGate(corr)
 		}
+		start := time.Now()
 
 		if r.Agent == nil {
 			out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: "agent unavailable"}
@@ -53,9 +88,39 @@ func (r *AgentRunner) Run(reqCtx *http.Request, req rpc.RunTaskRequest) (<-chan
 			out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
 			return
 		}
+		instructions, err := DiscoverInstructions(r.Tools, req.Prompt, req.ContextPaths, r.Agent.Instructions())
+		if err != nil {
+			out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
+			return
+		}
 
-		tokenCount := 0
+		usage := agent.NewUsageLedger()
+		if r.Metrics != nil {
+			defer func() {
+				for _, e := range usage.Entries() {
+					r.Metrics.RecordTokenUsage(e.Role, e.Model, e.Usage.PromptTokens, e.Usage.CompletionTokens, e.CostUSD)
+				}
+			}()
+		}
+		spent := &runSpend{usage: usage}
+		stats := r.Strategy.Stats()
+		defer func() {
+			if err := stats.Save(); err != nil {
+				r.logf("save routing stats: %v", err)
+			}
+		}()
 		forcedFinish := ""
+		haltMessage := ""
+		testFailures := 0
+		replans := 0
+		escalatedModel := ""
+		classifiedModel := ""
+		toolDefs := toolDefinitions(r.Tools)
+		if inbox != nil {
+			toolDefs = append(toolDefs, askUserDefinition)
+		}
+		questions := 0
+		approvals := 0
 		initialTools := make([]agent.ToolObservation, 0, len(req.Tools))
 
 		if len(req.Tools) > 0 && r.Tools != nil {
@@ -68,42 +133,68 @@ func (r *AgentRunner) Run(reqCtx *http.Request, req rpc.RunTaskRequest) (<-chan
 					return
 				}
 				initialTools = append(initialTools, obs)
+				if err := r.Agent.AppendToolResult(req.SessionID, "", tc.Name, output); err != nil {
+					out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
+					return
+				}
 				out <- rpc.RunTaskEvent{Type: "tool", SessionID: req.SessionID, CorrelationID: corr, ToolName: tc.Name, ToolOutput: output}
 			}
 		}
 
+		var plan string
 		if r.Agent != nil && r.Agent.PlanningEnabled() {
-			planModel := r.selectModel("planner", firstNonEmpty(req.PlannerModel, req.Model), &expensiveUsed)
-			plan, err := r.Agent.Plan(reqCtx.Context(), agent.Request{
-				SessionID: req.SessionID,
-				Model:     planModel,
-				Prompt:    req.Prompt,
-				Context:   ctxFiles,
+			planModel := r.selectModel("planner", firstNonEmpty(req.PlannerModel, req.Model), spent)
+			err := r.withFallbacks(reqCtx.Context(), "planner", planModel, spent, func(model string) error {
+				var err error
+				plan, err = r.Agent.Plan(reqCtx.Context(), agent.Request{
+					SessionID:    req.SessionID,
+					Model:        model,
+					Prompt:       req.Prompt,
+					Context:      ctxFiles,
+					Usage:        usage,
+					Instructions: instructions,
+				})
+				return err
 			})
 			if err != nil {
-				if r.Metrics != nil {
-					r.Metrics.RecordModelFailure("planner", planModel)
-				}
-				r.logf("planner model %s failed: %v", planModel, err)
-				if fb := r.pickFallbackModel("planner", planModel, &expensiveUsed); fb != "" {
-					planModel = fb
-					plan, err = r.Agent.Plan(reqCtx.Context(), agent.Request{
-						SessionID: req.SessionID,
-						Model:     planModel,
-						Prompt:    req.Prompt,
-						Context:   ctxFiles,
-					})
-				}
-			}
-			if err != nil {
-				if r.Metrics != nil {
-					r.Metrics.RecordModelFailure("planner", planModel)
-				}
 				out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
 				return
 			}
 			if strings.TrimSpace(plan) != "" {
-				out <- rpc.RunTaskEvent{Type: "plan", SessionID: req.SessionID, CorrelationID: corr, Message: plan}
+				current, err := r.Agent.CurrentPlan(req.SessionID)
+				if err != nil {
+					out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
+					return
+				}
+				out <- rpc.RunTaskEvent{Type: "plan", SessionID: req.SessionID, CorrelationID: corr, Message: plan, PlanSteps: planSteps(current.Steps), PlanVersion: current.Version}
+			}
+		}
+
+		// An explicit model always wins; otherwise the task's complexity picks the coder route.
+		if req.Model == "" {
+			if label, model := r.classifyTask(reqCtx.Context(), req.Prompt, ctxFiles, plan, usage); label != "" {
+				classifiedModel = model
+				out <- rpc.RunTaskEvent{Type: "classify", SessionID: req.SessionID, CorrelationID: corr, Message: classifyMessage(label, model), Complexity: string(label), Model: model}
+			}
+		}
+
+		// finish emits the optional halt message and the done event, then records run metrics.
+		finish := func(finishReason, halt string, step int) {
+			if halt != "" {
+				out <- rpc.RunTaskEvent{Type: "message", SessionID: req.SessionID, CorrelationID: corr, Message: halt, Step: step}
+			}
+			out <- rpc.RunTaskEvent{
+				Type:          "done",
+				SessionID:     req.SessionID,
+				CorrelationID: corr,
+				Done:          true,
+				FinishReason:  finishReason,
+				Step:          step,
+				Usage:         runUsage(usage),
+			}
+			if r.Metrics != nil {
+				total, _ := usage.Total()
+				r.Metrics.RecordAgentRun(finishReason, time.Since(start), total.TotalTokens)
 			}
 		}
 
@@ -113,38 +204,68 @@ func (r *AgentRunner) Run(reqCtx *http.Request, req rpc.RunTaskRequest) (<-chan
 				out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: "cancelled"}
 				return
 			}
+			if limit, exhausted := r.budgetExhausted(spent); exhausted {
+				finish(finishReasonBudgetExhausted, budgetHaltMessage(limit), step-1)
+				return
+			}
+
+			// Input sent since the last step joins the session before the coder continues;
+			// the first step sends the prompt, so its input waits for the second.
+			for _, text := range inboxInput(inbox, step) {
+				if err := r.Agent.AppendUserInput(req.SessionID, text); err != nil {
+					out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
+					return
+				}
+				out <- rpc.RunTaskEvent{Type: "input", SessionID: req.SessionID, CorrelationID: corr, Message: text, Step: step}
+			}
 
 			stepTools := append([]agent.ToolObservation{}, initialTools...)
 			initialTools = nil
+			var planTools []agent.PlanToolCall
 
-			modelOverride := r.selectModel("coder", firstNonEmpty(req.Model), &expensiveUsed)
-			resp, err := r.Agent.Run(reqCtx.Context(), agent.Request{
-				SessionID: req.SessionID,
-				Model:     modelOverride,
-				Prompt:    req.Prompt,
-				Context:   ctxFiles,
-			})
-			if err != nil {
-				if r.Metrics != nil {
-					r.Metrics.RecordModelFailure("coder", modelOverride)
-				}
-				r.logf("coder model %s failed: %v", modelOverride, err)
-				if fb := r.pickFallbackModel("coder", modelOverride, &expensiveUsed); fb != "" {
-					modelOverride = fb
-					resp, err = r.Agent.Run(reqCtx.Context(), agent.Request{
-						SessionID: req.SessionID,
-						Model:     modelOverride,
-						Prompt:    req.Prompt,
-						Context:   ctxFiles,
-					})
+			var streamed bool
+			onDelta := func(delta string) {
+				streamed = true
+				select {
+				case <-reqCtx.Context().Done():
+				case out <- rpc.RunTaskEvent{Type: "token", SessionID: req.SessionID, CorrelationID: corr, Token: delta, Step: step}:
 				}
-				if err != nil {
-					if r.Metrics != nil {
-						r.Metrics.RecordModelFailure("coder", modelOverride)
-					}
-					out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
-					return
+			}
+
+			// After the first step the coder continues the conversation from its
+			// own tool results rather than receiving the original prompt again.
+			var resp agent.Response
+			// An escalated model is picked like any other, so budgets and open
+			// circuits still apply to it for the rest of the run.
+			coderModel := r.selectModel("coder", firstNonEmpty(escalatedModel, req.Model, classifiedModel), spent)
+			err := r.withFallbacks(reqCtx.Context(), "coder", coderModel, spent, func(model string) error {
+				var err error
+				streamed = false
+				resp, err = r.Agent.RunStream(reqCtx.Context(), agent.Request{
+					SessionID:    req.SessionID,
+					Model:        model,
+					Prompt:       req.Prompt,
+					Context:      ctxFiles,
+					Tools:        toolDefs,
+					Continue:     step > 1,
+					Usage:        usage,
+					Instructions: instructions,
+				}, onDelta)
+				if err != nil && streamed {
+					// The client already has part of this answer; a fallback would
+					// stream a second one after it.
+					return &partialOutputError{err: err}
 				}
+				return err
+			})
+			if err != nil {
+				out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
+				return
+			}
+
+			if err := reqCtx.Context().Err(); err != nil {
+				out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: "cancelled"}
+				return
 			}
 
 			out <- rpc.RunTaskEvent{
@@ -155,29 +276,48 @@ func (r *AgentRunner) Run(reqCtx *http.Request, req rpc.RunTaskRequest) (<-chan
 				Step:          step,
 			}
 
-			tokens := strings.Fields(resp.Message.Content)
-			for idx, token := range tokens {
-				select {
-				case <-reqCtx.Context().Done():
-					out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: "cancelled"}
+			// Execute any tool calls emitted by the model before deciding to stop.
+			if r.Tools != nil || inbox != nil {
+				tcalls, err := responseToolCalls(resp.Message)
+				if err != nil {
+					out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
 					return
-				case out <- rpc.RunTaskEvent{Type: "token", SessionID: req.SessionID, CorrelationID: corr, Token: token, Step: step*1000 + idx}:
 				}
-			}
-			tokenCount += len(tokens)
-
-			// Execute any tool calls emitted by the model before deciding to stop.
-			if r.Tools != nil {
-				tcalls := extractToolCalls(resp.Message.Content)
 				for _, tc := range tcalls {
-					output, err := executeTool(reqCtx.Context(), r.Tools, tc)
+					var output string
+					var err error
+					if tc.Name == askUserTool {
+						questions++
+						output, err = r.askUser(reqCtx.Context(), inbox, out, req, corr, step, tc, questions)
+					} else {
+						approvals++
+						if tc, err = r.approveCall(reqCtx.Context(), gate, out, req, corr, step, tc, approvals); err == nil {
+							output, err = executeTool(reqCtx.Context(), r.Tools, tc)
+						}
+					}
 					obs := agent.ToolObservation{Name: tc.Name, Output: output}
 					if err != nil {
+						if ctxErr := reqCtx.Context().Err(); ctxErr != nil {
+							out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: "cancelled"}
+							return
+						}
+						// Feed the failure back so the model can correct its next call.
 						obs.Error = err.Error()
+						stepTools = append(stepTools, obs)
+						planTools = append(planTools, agent.PlanToolCall{ID: tc.ID, Name: tc.Name, Error: err.Error()})
+						if appendErr := r.Agent.AppendToolResult(req.SessionID, tc.ID, tc.Name, "error: "+err.Error()); appendErr != nil {
+							out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: appendErr.Error()}
+							return
+						}
 						out <- rpc.RunTaskEvent{Type: "tool", SessionID: req.SessionID, CorrelationID: corr, ToolName: tc.Name, ToolOutput: err.Error(), Error: err.Error()}
+						continue
+					}
+					if err := r.Agent.AppendToolResult(req.SessionID, tc.ID, tc.Name, output); err != nil {
+						out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
 						return
 					}
 					stepTools = append(stepTools, obs)
+					planTools = append(planTools, agent.PlanToolCall{ID: tc.ID, Name: tc.Name})
 					out <- rpc.RunTaskEvent{Type: "tool", SessionID: req.SessionID, CorrelationID: corr, ToolName: tc.Name, ToolOutput: output}
 				}
 			}
@@ -207,50 +347,87 @@ func (r *AgentRunner) Run(reqCtx *http.Request, req rpc.RunTaskRequest) (<-chan
 					evt.FailingTests = failing
 				}
 				out <- evt
+
+				// attempts is zero when the tests could not be started at all (no terminal,
+				// empty command); only real runs count as passing or failing.
+				if attempts > 0 {
+					passed := exitCode == 0 && testErr == nil
+					stats.RecordTestResult(resp.Route.Name, passed)
+					if passed {
+						testFailures = 0
+					} else {
+						// Failing tests mean the task is not finished: show the coder the
+						// output and keep going.
+						testFailures++
+						done = false
+						if err := r.Agent.AppendToolResult(req.SessionID, "", "tests", output); err != nil {
+							out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
+							return
+						}
+						if escalatedModel == "" {
+							if model, reason := r.escalateCoder(testFailures, spent); model != "" {
+								escalatedModel = model
+								out <- rpc.RunTaskEvent{Type: "escalate", SessionID: req.SessionID, CorrelationID: corr, Message: reason, Model: model, Step: step}
+							}
+						}
+					}
+				}
+			}
+
+			update, err := r.Agent.UpdatePlan(req.SessionID, agent.PlanProgress{Content: resp.Message.Content, ToolCalls: planTools, Test: testObs})
+			if err != nil {
+				out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
+				return
+			}
+			if len(update.Notes) > 0 {
+				out <- rpc.RunTaskEvent{Type: "plan_update", SessionID: req.SessionID, CorrelationID: corr, Message: strings.Join(update.Notes, "; "), PlanSteps: planSteps(update.Steps), PlanVersion: update.Version, Step: step}
+			}
+
+			// A spent budget skips reflection; a step that did not finish the task stops the run.
+			limit, overBudget := r.budgetExhausted(spent)
+			if overBudget && !done {
+				done = true
+				forcedFinish = finishReasonBudgetExhausted
+				haltMessage = budgetHaltMessage(limit)
+			}
+
+			// Failing tests after the coder reported done, or a poor critique, mean the plan
+			// no longer holds; the planner revises it before the next step.
+			var why agent.ReplanContext
+			if testObs != nil && len(testObs.Failing) > 0 {
+				why.Test = testObs
 			}
 
-			if r.Agent != nil && r.Agent.ReflectionEnabled() {
+			if !overBudget && r.Agent != nil && r.Agent.ReflectionEnabled() {
 				selfDiff := ""
 				if r.Agent.EnableSelfDiff() {
 					selfDiff = computeSelfDiff(resp.PreviousAssistant, resp.Message.Content)
 				}
-				criticModel := r.selectModel("critic", firstNonEmpty(req.CriticModel, req.Model), &expensiveUsed)
-				reflection, err := r.Agent.Reflect(reqCtx.Context(), agent.Request{
-					SessionID: req.SessionID,
-					Model:     criticModel,
-					Prompt:    req.Prompt,
-				}, resp, agent.ReflectionContext{
-					Tools:    stepTools,
-					Test:     testObs,
-					SelfDiff: selfDiff,
+				var reflection string
+				criticModel := r.selectModel("critic", firstNonEmpty(req.CriticModel, req.Model), spent)
+				err := r.withFallbacks(reqCtx.Context(), "critic", criticModel, spent, func(model string) error {
+					var err error
+					criticModel = model
+					reflection, err = r.Agent.Reflect(reqCtx.Context(), agent.Request{
+						SessionID:    req.SessionID,
+						Model:        model,
+						Prompt:       req.Prompt,
+						Usage:        usage,
+						Instructions: instructions,
+					}, resp, agent.ReflectionContext{
+						Tools:    stepTools,
+						Test:     testObs,
+						SelfDiff: selfDiff,
+					})
+					return err
 				})
 				if err != nil {
-					if r.Metrics != nil {
-						r.Metrics.RecordModelFailure("critic", criticModel)
-					}
-					r.logf("critic model %s failed: %v", criticModel, err)
-					if fb := r.pickFallbackModel("critic", criticModel, &expensiveUsed); fb != "" {
-						criticModel = fb
-						reflection, err = r.Agent.Reflect(reqCtx.Context(), agent.Request{
-							SessionID: req.SessionID,
-							Model:     criticModel,
-							Prompt:    req.Prompt,
-						}, resp, agent.ReflectionContext{
-							Tools:    stepTools,
-							Test:     testObs,
-							SelfDiff: selfDiff,
-						})
-					}
-				}
-				if err != nil {
-					if r.Metrics != nil {
-						r.Metrics.RecordModelFailure("critic", criticModel)
-					}
 					out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
 					return
 				}
 				if strings.TrimSpace(reflection) != "" {
 					critique := parseCritique(reflection)
+					stats.RecordCritique(criticModel, critiqueBlocksApply(critique))
 					out <- rpc.RunTaskEvent{
 						Type:          "reflect",
 						SessionID:     req.SessionID,
@@ -259,51 +436,55 @@ func (r *AgentRunner) Run(reqCtx *http.Request, req rpc.RunTaskRequest) (<-chan
 						Critique:      critique,
 						Step:          step,
 					}
+					if critiqueQuality(critique) == "poor" {
+						why.Critique = reflection
+					}
 					if critiqueBlocksApply(critique) && shouldBlockOnCritique(r.Agent.ReflectionPolicy()) {
 						done = true
 						forcedFinish = "blocked_by_reflect"
+						haltMessage = fmt.Sprintf("Run halted by reflection policy (%s)", forcedFinish)
 					}
 				}
 			}
 
+			if !done && (why.Test != nil || why.Critique != "") && r.Agent.PlanningEnabled() && replans < r.Agent.MaxReplans() {
+				replans++
+				var revision agent.PlanRevision
+				planModel := r.selectModel("planner", firstNonEmpty(req.PlannerModel, req.Model), spent)
+				err := r.withFallbacks(reqCtx.Context(), "planner", planModel, spent, func(model string) error {
+					var err error
+					revision, err = r.Agent.Replan(reqCtx.Context(), agent.Request{
+						SessionID:    req.SessionID,
+						Model:        model,
+						Prompt:       req.Prompt,
+						Usage:        usage,
+						Instructions: instructions,
+					}, why)
+					return err
+				})
+				if err != nil {
+					out <- rpc.RunTaskEvent{Type: "error", SessionID: req.SessionID, CorrelationID: corr, Error: err.Error()}
+					return
+				}
+				out <- rpc.RunTaskEvent{Type: "plan", SessionID: req.SessionID, CorrelationID: corr, Message: revision.Text, PlanSteps: planSteps(revision.Steps), PlanVersion: revision.Version, Step: step}
+			}
+
+			// Input that arrived during the final step keeps the run going.
+			if done && forcedFinish == "" && inbox.pending() && step < maxSteps {
+				continue
+			}
+
 			if done {
 				finishReason := resp.FinishReason
 				if forcedFinish != "" {
 					finishReason = forcedFinish
-					out <- rpc.RunTaskEvent{
-						Type:          "message",
-						SessionID:     req.SessionID,
-						CorrelationID: corr,
-						Message:       fmt.Sprintf("Run halted by reflection policy (%s)", finishReason),
-						Step:          step,
-					}
-				}
-				out <- rpc.RunTaskEvent{
-					Type:          "done",
-					SessionID:     req.SessionID,
-					CorrelationID: corr,
-					Done:          true,
-					FinishReason:  finishReason,
-					Step:          step,
-				}
-				if r.Metrics != nil {
-					r.Metrics.RecordAgentRun(finishReason, time.Since(start), tokenCount)
 				}
+				finish(finishReason, haltMessage, step)
 				return
 			}
 		}
 
-		out <- rpc.RunTaskEvent{
-			Type:          "done",
-			SessionID:     req.SessionID,
-			CorrelationID: corr,
-			Done:          true,
-			FinishReason:  "max_steps",
-			Step:          maxSteps,
-		}
-		if r.Metrics != nil {
-			r.Metrics.RecordAgentRun("max_steps", time.Since(start), tokenCount)
-		}
+		finish("max_steps", "", maxSteps)
 	}()
 	return out, nil
 }
@@ -315,7 +496,48 @@ func (EchoRunner) Run(reqCtx *http.Request, req rpc.RunTaskRequest) (<-chan rpc.
 	return runTaskEcho(req), nil
 }
 
+// runUsage converts the run's ledger into the usage summary carried by the done event.
+func runUsage(ledger *agent.UsageLedger) *rpc.RunUsage {
+	total, cost := ledger.Total()
+	out := &rpc.RunUsage{
+		PromptTokens:     total.PromptTokens,
+		CompletionTokens: total.CompletionTokens,
+		TotalTokens:      total.TotalTokens,
+		CostUSD:          cost,
+	}
+	for _, e := range ledger.Entries() {
+		out.ByModel = append(out.ByModel, rpc.ModelUsage{
+			Role:             e.Role,
+			Model:            e.Model,
+			Calls:            e.Calls,
+			PromptTokens:     e.Usage.PromptTokens,
+			CompletionTokens: e.Usage.CompletionTokens,
+			TotalTokens:      e.Usage.TotalTokens,
+			CostUSD:          e.CostUSD,
+		})
+	}
+	return out
+}
+
+func planSteps(steps []agent.PlanStep) []rpc.PlanStep {
+	var out []rpc.PlanStep
+	for _, st := range steps {
+		ps := rpc.PlanStep{ID: st.ID, Description: st.Description, Status: string(st.Status)}
+		for _, tc := range st.ToolCalls {
+			ps.ToolCalls = append(ps.ToolCalls, rpc.PlanToolCall{ID: tc.ID, Name: tc.Name, Error: tc.Error})
+		}
+		for _, t := range st.Tests {
+			ps.Tests = append(ps.Tests, rpc.PlanTest{ExitCode: t.ExitCode, Summary: t.Summary, Failing: t.Failing})
+		}
+		out = append(out, ps)
+	}
+	return out
+}
+
 func isResponseDone(resp agent.Response) bool {
+	if len(resp.Message.ToolCalls) > 0 || resp.FinishReason == "tool_calls" {
+		return false
+	}
 	if resp.FinishReason != "" && resp.FinishReason != "length" {
 		return true
 	}
@@ -447,13 +669,86 @@ func executeTool(ctx context.Context, reg *tools.Registry, tc rpc.ToolCall) (str
 	}
 }
 
-func (r *AgentRunner) selectModel(role, override string, expensiveUsed *int) string {
+// finishReasonBudgetExhausted ends runs that reached a strategy budget limit.
+const finishReasonBudgetExhausted = "budget_exhausted"
+
+// runSpend tracks what a run has consumed for the strategy's budget checks.
+type runSpend struct {
+	expensive int
+	usage     *agent.UsageLedger
+}
+
+func (s *runSpend) spend() agent.Spend {
+	total, cost := s.usage.Total()
+	return agent.Spend{
+		Expensive:        s.expensive,
+		PromptTokens:     total.PromptTokens,
+		CompletionTokens: total.CompletionTokens,
+		CostUSD:          cost,
+	}
+}
+
+func (r *AgentRunner) budgetExhausted(spent *runSpend) (string, bool) {
+	if r.Strategy == nil {
+		return "", false
+	}
+	return r.Strategy.BudgetExhausted(spent.spend())
+}
+
+// escalateCoder returns the model to switch the coder to once tests have failed after
+// failures consecutive steps, with the reason; it is skipped when max_expensive is used
+// up. Each escalated step is then picked, and charged, like any other coder step.
+func (r *AgentRunner) escalateCoder(failures int, spent *runSpend) (string, string) {
+	model, after := r.Strategy.Escalation()
+	if model == "" || failures < after {
+		return "", ""
+	}
+	if !r.Strategy.ExpensiveAllowed(spent.spend()) {
+		r.logf("coder escalation to %s skipped: expensive model budget used up", model)
+		return "", ""
+	}
+	return model, fmt.Sprintf("Tests failed after %d consecutive steps; escalating coder to %s for the rest of the run", failures, model)
+}
+
+// classifyTask labels the task's complexity from the prompt, context and plan and returns
+// the coder model routed to the label; the label is empty when classification is disabled
+// or fails.
+func (r *AgentRunner) classifyTask(ctx context.Context, prompt string, ctxFiles []agent.ContextFile, plan string, usage *agent.UsageLedger) (agent.Complexity, string) {
+	sig := agent.TaskSignals{
+		Prompt:       prompt,
+		ContextFiles: len(ctxFiles),
+		PlanSteps:    agent.CountPlanSteps(plan),
+		Usage:        usage,
+	}
+	for _, f := range ctxFiles {
+		sig.ContextBytes += len(f.Content)
+	}
+	label, model, err := r.Strategy.ClassifyTask(ctx, sig)
+	if err != nil {
+		r.logf("task classification failed: %v", err)
+		return "", ""
+	}
+	return label, model
+}
+
+func classifyMessage(label agent.Complexity, model string) string {
+	if model == "" {
+		return fmt.Sprintf("Task classified as %s", label)
+	}
+	return fmt.Sprintf("Task classified as %s; routing coder to %s", label, model)
+}
+
+func budgetHaltMessage(limit string) string {
+	return fmt.Sprintf("Run stopped: budget exhausted (%s)", limit)
+}
+
+func (r *AgentRunner) selectModel(role, override string, spent *runSpend) string {
 	model := firstNonEmpty(override)
 	if r.Strategy == nil {
 		return model
 	}
 
-	_, route, _, isExp, err := r.Strategy.PickWithBudget(role, model, *expensiveUsed)
+	_, route, _, isExp, err := r.Strategy.PickWithBudget(role, model, spent.spend())
 	if err != nil {
 		if r.Metrics != nil {
 			r.Metrics.RecordModelFailure(role, model)
@@ -465,7 +760,7 @@ func (r *AgentRunner) selectModel(role, override string, expensiveUsed *int) str
 		return model
 	}
 	if isExp {
-		*expensiveUsed++
+		spent.expensive++
 	}
 	if r.Metrics != nil {
 		r.Metrics.RecordModelUsage(role, route.Name)
@@ -476,22 +771,61 @@ func (r *AgentRunner) selectModel(role, override string, expensiveUsed *int) str
 	return route.Name
 }
 
-func (r *AgentRunner) pickFallbackModel(role, current string, expensiveUsed *int) string {
-	if r.Strategy == nil {
-		return ""
-	}
+// partialOutputError marks a failure after output was already streamed to the client,
+// which withFallbacks returns instead of retrying on another model.
+type partialOutputError struct{ err error }
+
+func (e *partialOutputError) Error() string { return e.err.Error() }
+func (e *partialOutputError) Unwrap() error { return e.err }
+
+// withFallbacks calls fn with model and, while it fails, walks the role's fallback chain
+// one link at a time until a model succeeds or the chain is exhausted; the last error is
+// returned. Each attempt's outcome and latency feed the routing statistics. A
+// *partialOutputError ends the walk since the client has seen part of the output.
+func (r *AgentRunner) withFallbacks(ctx context.Context, role, model string, spent *runSpend, fn func(model string) error) error {
 	tried := map[string]struct{}{}
-	if current != "" {
-		tried[current] = struct{}{}
+	for {
+		tried[model] = struct{}{}
+		start := time.Now()
+		err := fn(model)
+		if ctx.Err() == nil {
+			r.Strategy.Stats().RecordCall(role, model, time.Since(start), err)
+		}
+		if err == nil {
+			return nil
+		}
+		if r.Metrics != nil {
+			r.Metrics.RecordModelFailure(role, model)
+		}
+		r.logf("%s model %s failed: %v", role, model, err)
+		var partial *partialOutputError
+		if errors.As(err, &partial) {
+			return partial.err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		fb := r.pickFallbackModel(role, model, spent, tried)
+		if fb == "" {
+			return err
+		}
+		model = fb
 	}
+}
 
-	for fb := r.Strategy.NextFallback(current); fb != ""; fb = r.Strategy.NextFallback(fb) {
-		if _, seen := tried[fb]; seen {
+// pickFallbackModel returns the first link of the role's fallback chain that has not
+// been tried yet and still resolves under the strategy's budgets.
+func (r *AgentRunner) pickFallbackModel(role, current string, spent *runSpend, tried map[string]struct{}) string {
+	if r.Strategy == nil {
+		return ""
+	}
+	for _, fb := range r.Strategy.FallbackChain(role) {
+		if _, seen := tried[fb]; seen || strings.TrimSpace(fb) == "" {
 			continue
 		}
 		tried[fb] = struct{}{}
 
-		_, route, _, isExp, err := r.Strategy.PickWithBudget(role, fb, *expensiveUsed)
+		_, route, _, isExp, err := r.Strategy.PickWithBudget(role, fb, spent.spend())
 		if err != nil {
 			if r.Metrics != nil {
 				r.Metrics.RecordModelFailure(role, fb)
@@ -499,11 +833,11 @@ func (r *AgentRunner) pickFallbackModel(role, current string, expensiveUsed *int
 			r.logf("%s fallback selection %s failed: %v", role, fb, err)
 			continue
 		}
-		if route.Name == "" || route.Name == current {
+		if _, seen := tried[route.Name]; route.Name == "" || (seen && route.Name != fb) {
 			continue
 		}
 		if isExp {
-			*expensiveUsed++
+			spent.expensive++
 		}
 		if r.Metrics != nil {
 			r.Metrics.RecordModelUsage(role, route.Name)
@@ -674,7 +1008,6 @@ func discoverContextPaths(fsTool *tools.Filesystem, prompt string) []string {
 	defaults := []string{
 		".",
 		"README.md",
-		"CONTRIBUTING.md",
 		"go.mod",
 		"package.json",
 		"Makefile",
@@ -731,6 +1064,42 @@ func extractMentionedPaths(prompt string) []string {
 	return out
 }
 
+// toolDefinitions exposes the registry schemas for native function calling.
+func toolDefinitions(reg *tools.Registry) []llm.ToolDefinition {
+	if reg == nil {
+		return nil
+	}
+	schemas := reg.Schemas()
+	defs := make([]llm.ToolDefinition, 0, len(schemas))
+	for _, s := range schemas {
+		defs = append(defs, llm.ToolDefinition{
+			Name:        s.Name,
+			Description: s.Description,
+			Parameters:  s.JSONSchema(),
+		})
+	}
+	return defs
+}
+
+// responseToolCalls returns the structured tool calls of a model message, falling
+// back to parsing JSON from the content for models without native tool support.
+func responseToolCalls(msg llm.ChatMessage) ([]rpc.ToolCall, error) {
+	if len(msg.ToolCalls) == 0 {
+		return extractToolCalls(msg.Content), nil
+	}
+	calls := make([]rpc.ToolCall, 0, len(msg.ToolCalls))
+	for _, tc := range msg.ToolCalls {
+		args := map[string]interface{}{}
+		if len(tc.Function.Arguments) > 0 {
+			if err := json.Unmarshal(tc.Function.Arguments, &args); err != nil {
+				return nil, fmt.Errorf("decode arguments for tool %q: %w", tc.Function.Name, err)
+			}
+		}
+		calls = append(calls, rpc.ToolCall{ID: tc.ID, Name: tc.Function.Name, Args: args})
+	}
+	return calls, nil
+}
+
 // extractToolCalls parses basic JSON tool call structures from model content.
 // Supports either a single object {"name":"tool","args":{...}} or an array of such objects.
 func extractToolCalls(content string) []rpc.ToolCall {
@@ -793,6 +1162,11 @@ func critiqueBlocksApply(crit map[string]interface{}) bool {
 	return false
 }
 
+func critiqueQuality(crit map[string]interface{}) string {
+	quality, _ := crit["quality"].(string)
+	return strings.ToLower(strings.TrimSpace(quality))
+}
+
 func shouldBlockOnCritique(policy string) bool {
 	switch strings.ToLower(strings.TrimSpace(policy)) {
 	case "never_block":
@@ -16,6 +16,7 @@ import (
 	"github.com/animus-coder/animus-coder/internal/config"
 	"github.com/animus-coder/animus-coder/internal/llm"
 	llmmock "github.com/animus-coder/animus-coder/internal/llm/mock"
+	"github.com/animus-coder/animus-coder/internal/llm/providers/replay"
 	"github.com/animus-coder/animus-coder/internal/rpc"
 	"github.com/animus-coder/animus-coder/internal/semantic"
 	"github.com/animus-coder/animus-coder/internal/tools"
@@ -167,6 +168,105 @@ func TestAgentRunnerEmitsPlanEvent(t *testing.T) {
 	require.Equal(t, 2, callCount)
 }
 
+func TestAgentRunnerEmitsPlanUpdates(t *testing.T) {
+	reg := llm.NewRegistry()
+	coderReplies := []string{"Inspected the handler. [step 1 done]", "[step 2 skipped] nothing to edit [done]"}
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			if llmmock.RequestRole(req) == llmmock.RolePlanner {
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "1. inspect the handler\n2. edit it"}}, nil
+			}
+			reply := coderReplies[0]
+			coderReplies = coderReplies[1:]
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: reply}}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	ar := &AgentRunner{Agent: regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 3, EnablePlan: true})}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "plan-updates", Prompt: "fix the handler"})
+	require.NoError(t, err)
+
+	var plan rpc.RunTaskEvent
+	var updates []rpc.RunTaskEvent
+	for ev := range ch {
+		switch ev.Type {
+		case "plan":
+			plan = ev
+		case "plan_update":
+			updates = append(updates, ev)
+		}
+	}
+	require.Equal(t, []rpc.PlanStep{
+		{ID: 1, Description: "inspect the handler", Status: "pending"},
+		{ID: 2, Description: "edit it", Status: "pending"},
+	}, plan.PlanSteps)
+	require.Len(t, updates, 2)
+	require.Equal(t, "Step 1 done: inspect the handler", updates[0].Message)
+	require.Equal(t, 1, updates[0].Step)
+	require.Equal(t, "Step 2 skipped: edit it", updates[1].Message)
+	require.Equal(t, "done", updates[1].PlanSteps[0].Status)
+	require.Equal(t, "skipped", updates[1].PlanSteps[1].Status)
+}
+
+func TestAgentRunnerReplansOnFailingTestsAndPoorCritique(t *testing.T) {
+	reg := llm.NewRegistry()
+	var replanPrompts []string
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			switch llmmock.RequestRole(req) {
+			case llmmock.RoleReplanner:
+				replanPrompts = append(replanPrompts, req.Messages[1].Content)
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: fmt.Sprintf("1. fix the handler\n2. fix attempt %d", len(replanPrompts))}}, nil
+			case llmmock.RolePlanner:
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "1. fix the handler\n2. run tests"}}, nil
+			case llmmock.RoleCritic:
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: `{"quality":"poor","issues":["handler still panics"]}`}}, nil
+			}
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "[done]"}, FinishReason: "stop"}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	script := filepath.Join(t.TempDir(), "test.sh")
+	require.NoError(t, os.WriteFile(script, []byte("echo '--- FAIL: TestHandler (0.00s)'\nexit 1\n"), 0o644))
+	term := &tools.Terminal{AllowExecution: true, Allowed: []string{"sh"}}
+	ar := &AgentRunner{
+		Agent: regAgentWithConfig(reg, config.AgentConfig{
+			MaxSteps:         3,
+			EnablePlan:       true,
+			EnableReflect:    true,
+			ReflectionPolicy: "never_block",
+			EnableTestRun:    true,
+			TestCommand:      "sh " + script,
+			MaxReplans:       2,
+		}),
+		Tools: tools.NewRegistry(nil, term, nil, nil),
+	}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "replan", Prompt: "fix the handler"})
+	require.NoError(t, err)
+
+	var plans []rpc.RunTaskEvent
+	for ev := range ch {
+		if ev.Type == "plan" {
+			plans = append(plans, ev)
+		}
+	}
+	require.Len(t, plans, 3, "the first plan and one revision per step up to max_replans")
+	require.Equal(t, 1, plans[0].PlanVersion)
+	require.Equal(t, 2, plans[1].PlanVersion)
+	require.Equal(t, 3, plans[2].PlanVersion)
+	require.Equal(t, "fix attempt 1", plans[1].PlanSteps[1].Description)
+	require.Equal(t, 1, plans[1].Step)
+
+	require.Len(t, replanPrompts, 2)
+	require.Contains(t, replanPrompts[0], "Failing tests: TestHandler")
+	require.Contains(t, replanPrompts[0], "handler still panics")
+	require.Contains(t, replanPrompts[1], "2. fix attempt 1", "revisions build on the previous plan")
+}
+
 func TestAgentRunnerRunsTestsOnDone(t *testing.T) {
 	reg := llm.NewRegistry()
 	reg.RegisterProvider("mock", &llmmock.Provider{
@@ -206,6 +306,142 @@ func TestAgentRunnerRunsTestsOnDone(t *testing.T) {
 	require.True(t, doneSeen)
 }
 
+func TestAgentRunnerEscalatesCoderAfterFailingTests(t *testing.T) {
+	reg := llm.NewRegistry()
+	var models []string
+	var sawTestOutput bool
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			models = append(models, req.Model)
+			for _, m := range req.Messages {
+				if strings.Contains(m.Content, "Tool tests returned") {
+					sawTestOutput = true
+				}
+			}
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "[done]"}, FinishReason: "stop"}, nil
+		},
+	})
+	reg.RegisterModel("cheap", llm.ModelRoute{Provider: "mock", Model: "cheap"}, true)
+	reg.RegisterModel("strong", llm.ModelRoute{Provider: "mock", Model: "strong"}, false)
+	reg.MarkExpensive("strong", true)
+
+	term := &tools.Terminal{AllowExecution: true, Allowed: []string{"false"}}
+	metrics := &fakeMetrics{}
+	ar := &AgentRunner{
+		Agent:   regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 4, EnableTestRun: true, TestCommand: "false"}),
+		Tools:   tools.NewRegistry(nil, term, nil, nil),
+		Metrics: metrics,
+		Strategy: agent.NewStrategyEngine(reg, config.StrategyConfig{
+			CoderModel:   "cheap",
+			MaxExpensive: 1,
+			Escalation:   config.EscalationConfig{Model: "strong", AfterFailures: 2},
+		}),
+	}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "escalate", Prompt: "fix the tests"})
+	require.NoError(t, err)
+
+	var escalations []rpc.RunTaskEvent
+	var done rpc.RunTaskEvent
+	for ev := range ch {
+		switch ev.Type {
+		case "escalate":
+			escalations = append(escalations, ev)
+		case "done":
+			done = ev
+		}
+	}
+	require.Len(t, escalations, 1)
+	require.Equal(t, "strong", escalations[0].Model)
+	require.Equal(t, 2, escalations[0].Step)
+	require.Contains(t, escalations[0].Message, "2 consecutive steps")
+	require.Equal(t, []string{"cheap", "cheap", "strong", "strong"}, models)
+	require.Equal(t, "max_steps", done.FinishReason)
+	require.True(t, sawTestOutput, "failing test output should reach the coder")
+	require.Contains(t, metrics.usage, "coder:strong")
+}
+
+func TestAgentRunnerDowngradesEscalatedCoderUnderBudget(t *testing.T) {
+	reg := llm.NewRegistry()
+	var models []string
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			models = append(models, req.Model)
+			return llm.ChatResponse{
+				Message:      llm.ChatMessage{Role: llm.RoleAssistant, Content: "[done]"},
+				FinishReason: "stop",
+				Usage:        llm.Usage{CompletionTokens: 150, TotalTokens: 150},
+			}, nil
+		},
+	})
+	reg.RegisterModel("cheap", llm.ModelRoute{Provider: "mock", Model: "cheap"}, true)
+	reg.RegisterModel("strong", llm.ModelRoute{Provider: "mock", Model: "strong", InputCostPerMTok: 10, OutputCostPerMTok: 30}, false)
+	reg.MarkExpensive("strong", true)
+
+	term := &tools.Terminal{AllowExecution: true, Allowed: []string{"false"}}
+	ar := &AgentRunner{
+		Agent: regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 5, EnableTestRun: true, TestCommand: "false"}),
+		Tools: tools.NewRegistry(nil, term, nil, nil),
+		Strategy: agent.NewStrategyEngine(reg, config.StrategyConfig{
+			CoderModel: "cheap",
+			Fallbacks:  []string{"cheap"},
+			Escalation: config.EscalationConfig{Model: "strong", AfterFailures: 2},
+			Budget:     config.BudgetConfig{MaxCompletionTokens: 1000, DowngradeAt: 0.5},
+		}),
+	}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "escalate-budget", Prompt: "fix the tests"})
+	require.NoError(t, err)
+	for range ch {
+	}
+
+	require.Equal(t, []string{"cheap", "cheap", "strong", "strong", "cheap"}, models,
+		"the escalated coder is downgraded once half the token budget is spent")
+}
+
+func TestAgentRunnerRoutesTrivialTaskToLocalCoder(t *testing.T) {
+	reg := llm.NewRegistry()
+	var models []string
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			models = append(models, req.Model)
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "[done]"}, FinishReason: "stop"}, nil
+		},
+	})
+	reg.RegisterModel("strong", llm.ModelRoute{Provider: "mock", Model: "strong"}, true)
+	reg.RegisterModel("local-coder", llm.ModelRoute{Provider: "mock", Model: "local"}, false)
+
+	ar := &AgentRunner{
+		Agent: regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 2}),
+		Strategy: agent.NewStrategyEngine(reg, config.StrategyConfig{
+			CoderModel: "strong",
+			Complexity: config.ComplexityConfig{Classifier: "heuristic", Routes: map[string]string{"trivial": "local-coder"}},
+		}),
+	}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "classify", Prompt: "fix the typo in the help text"})
+	require.NoError(t, err)
+
+	var classified rpc.RunTaskEvent
+	for ev := range ch {
+		if ev.Type == "classify" {
+			classified = ev
+		}
+	}
+	require.Equal(t, "trivial", classified.Complexity)
+	require.Equal(t, "local-coder", classified.Model)
+	require.Equal(t, []string{"local"}, models)
+
+	// An explicit model skips classification.
+	models = nil
+	ch, err = ar.Run(req, rpc.RunTaskRequest{SessionID: "classify-explicit", Prompt: "fix the typo in the help text", Model: "strong"})
+	require.NoError(t, err)
+	for ev := range ch {
+		require.NotEqual(t, "classify", ev.Type)
+	}
+	require.Equal(t, []string{"strong"}, models)
+}
+
 func TestAgentRunnerParsesFailingTests(t *testing.T) {
 	reg := llm.NewRegistry()
 	reg.RegisterProvider("mock", &llmmock.Provider{
@@ -276,6 +512,61 @@ func TestAgentRunnerLoadsContextFiles(t *testing.T) {
 	require.True(t, doneSeen)
 }
 
+func TestAgentRunnerMergesInstructionFilesIntoSystemPrompt(t *testing.T) {
+	tmp := t.TempDir()
+	write := func(rel, content string) {
+		full := filepath.Join(tmp, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0o644))
+	}
+	write("AGENTS.md", "Use tabs.")
+	write("internal/CONTRIBUTING.md", "Wrap errors with %w.")
+	write("internal/api/.mycodex/instructions.md", "Handlers return JSON errors. "+strings.Repeat("x", 100))
+	write("internal/api/handler.go", "package api")
+	write("cmd/AGENTS.md", "Not on the way to the touched files.")
+
+	var systems []string
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			systems = append(systems, req.Messages[0].Content)
+			if llmmock.RequestRole(req) == llmmock.RolePlanner {
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "1. fix"}}, nil
+			}
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "[done]"}, FinishReason: "stop"}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	fsTool, err := tools.NewFilesystem(tmp, true)
+	require.NoError(t, err)
+	ar := &AgentRunner{
+		Agent: regAgentWithConfig(reg, config.AgentConfig{
+			MaxSteps:        1,
+			EnablePlan:      true,
+			MaxContextBytes: 10,
+			Instructions: config.InstructionsConfig{
+				Files:    []string{"AGENTS.md", "CONTRIBUTING.md", ".mycodex/instructions.md"},
+				MaxBytes: 140,
+			},
+		}),
+		Tools: tools.NewRegistry(fsTool, nil, nil, nil),
+	}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "instructions", Prompt: "fix internal/api/handler.go"})
+	require.NoError(t, err)
+	for range ch {
+	}
+
+	require.Len(t, systems, 2)
+	for _, system := range systems {
+		require.Contains(t, system, "From internal/CONTRIBUTING.md:\nWrap errors\n[truncated]\n\nFrom internal/api/.mycodex/instructions.md:\nHandlers return JSON errors. "+strings.Repeat("x", 100),
+			"instructions have their own budget, not max_context_bytes, and it goes to the deepest files first")
+		require.NotContains(t, system, "Use tabs.")
+		require.NotContains(t, system, "Not on the way")
+	}
+}
+
 func TestAgentRunnerAutoLoadsContextFromPrompt(t *testing.T) {
 	tmp := t.TempDir()
 	mainPath := filepath.Join(tmp, "main.go")
@@ -595,6 +886,216 @@ func TestAgentRunnerFallsBackOnCoderError(t *testing.T) {
 	require.Contains(t, metrics.usage, "coder:backup")
 }
 
+func TestAgentRunnerKeepsPartialStreamOffFallback(t *testing.T) {
+	reg := llm.NewRegistry()
+	fallbackCalls := 0
+	reg.RegisterProvider("fail", &llmmock.Provider{
+		StreamChunks: []llm.StreamChunk{{Content: "half an ans"}},
+		StreamErr:    fmt.Errorf("connection reset"),
+	})
+	reg.RegisterProvider("ok", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			fallbackCalls++
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "fallback [done]"}, FinishReason: "stop"}, nil
+		},
+	})
+	reg.RegisterModel("primary", llm.ModelRoute{Provider: "fail", Model: "m1"}, true)
+	reg.RegisterModel("backup", llm.ModelRoute{Provider: "ok", Model: "m2"}, false)
+
+	ar := &AgentRunner{
+		Agent:    agent.New(reg, config.AgentConfig{MaxSteps: 1}),
+		Strategy: agent.NewStrategyEngine(reg, config.StrategyConfig{CoderModel: "primary", Fallbacks: []string{"backup"}}),
+	}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "partial", Prompt: "do work"})
+	require.NoError(t, err)
+
+	var tokens []string
+	var errEvt rpc.RunTaskEvent
+	for ev := range ch {
+		switch ev.Type {
+		case "token":
+			tokens = append(tokens, ev.Token)
+		case "error":
+			errEvt = ev
+		}
+	}
+
+	require.Equal(t, []string{"half an ans"}, tokens)
+	require.Contains(t, errEvt.Error, "connection reset")
+	require.Zero(t, fallbackCalls, "no fallback once tokens were streamed")
+}
+
+func TestAgentRunnerSavesRoutingStatsAfterEachRun(t *testing.T) {
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "[done]"}, FinishReason: "stop"}, nil
+		},
+	})
+	reg.RegisterModel("coder", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	path := filepath.Join(t.TempDir(), "routing.json")
+	stats, err := agent.LoadRouteStats(path, 10)
+	require.NoError(t, err)
+	strategy := agent.NewStrategyEngine(reg, config.StrategyConfig{CoderModel: "coder"})
+	strategy.SetStats(stats)
+	ar := &AgentRunner{Agent: agent.New(reg, config.AgentConfig{MaxSteps: 1}), Strategy: strategy}
+
+	for i := 1; i <= 2; i++ {
+		req, _ := http.NewRequest(http.MethodPost, "/", nil)
+		ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: fmt.Sprintf("stats-%d", i), Prompt: "do work"})
+		require.NoError(t, err)
+		for range ch {
+		}
+
+		saved, err := agent.LoadRouteStats(path, 10)
+		require.NoError(t, err)
+		require.Equal(t, i, saved.Summary("coder", "coder").Calls, "stats are on disk once the run's stream closes")
+	}
+}
+
+func TestAgentRunnerWalksRoleFallbackChain(t *testing.T) {
+	reg := llm.NewRegistry()
+	var models []string
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			models = append(models, req.Model)
+			if strings.Contains(req.Messages[0].Content, "reflection") {
+				if req.Model != "crit-c" {
+					return llm.ChatResponse{}, fmt.Errorf("%s unavailable", req.Model)
+				}
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "looks fine"}}, nil
+			}
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "[done]"}, FinishReason: "stop"}, nil
+		},
+	})
+	for _, name := range []string{"coder", "big-coder", "crit-a", "crit-b", "crit-c"} {
+		reg.RegisterModel(name, llm.ModelRoute{Provider: "mock", Model: name}, name == "coder")
+	}
+
+	metrics := &fakeMetrics{}
+	ar := &AgentRunner{
+		Agent:   regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 1, EnableReflect: true}),
+		Metrics: metrics,
+		Strategy: agent.NewStrategyEngine(reg, config.StrategyConfig{
+			CoderModel:    "coder",
+			CriticModel:   "crit-a",
+			Fallbacks:     []string{"big-coder"},
+			RoleFallbacks: map[string][]string{"critic": {"crit-b", "crit-c"}},
+		}),
+	}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "chain", Prompt: "p"})
+	require.NoError(t, err)
+
+	var reflection string
+	var done rpc.RunTaskEvent
+	for ev := range ch {
+		require.NotEqual(t, "error", ev.Type, ev.Error)
+		switch ev.Type {
+		case "reflect":
+			reflection = ev.Message
+		case "done":
+			done = ev
+		}
+	}
+	require.Equal(t, "stop", done.FinishReason)
+	require.Equal(t, "looks fine", reflection)
+	require.Equal(t, []string{"coder", "crit-a", "crit-b", "crit-c"}, models)
+	require.Equal(t, []string{"critic:crit-a", "critic:crit-b"}, metrics.failures)
+	require.NotContains(t, metrics.usage, "critic:big-coder")
+
+	stats := ar.Strategy.Stats()
+	require.Equal(t, 1.0, stats.Summary("critic", "crit-a").ErrorRate)
+	require.Equal(t, 1, stats.Summary("coder", "coder").Calls)
+	critic := stats.Summary("critic", "crit-c")
+	require.Equal(t, 1, critic.Calls)
+	require.Zero(t, critic.ErrorRate)
+	require.Equal(t, 1, critic.Critiques)
+}
+
+func TestAgentRunnerReportsUsageAndCost(t *testing.T) {
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			content := "ok [done]"
+			usage := llm.Usage{PromptTokens: 1000, CompletionTokens: 200, TotalTokens: 1200}
+			if strings.Contains(req.Messages[0].Content, "planning assistant") {
+				content = "1. do it"
+				usage = llm.Usage{PromptTokens: 100, CompletionTokens: 50}
+			}
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: content}, FinishReason: "stop", Usage: usage}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m", InputCostPerMTok: 3, OutputCostPerMTok: 15}, true)
+
+	metrics := &fakeMetrics{}
+	ar := &AgentRunner{Agent: regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 1, EnablePlan: true}), Metrics: metrics}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "usage", Prompt: "p"})
+	require.NoError(t, err)
+
+	var done *rpc.RunTaskEvent
+	for ev := range ch {
+		if ev.Type == "done" {
+			ev := ev
+			done = &ev
+		}
+	}
+	require.NotNil(t, done)
+	require.NotNil(t, done.Usage)
+	require.Equal(t, 1100, done.Usage.PromptTokens)
+	require.Equal(t, 250, done.Usage.CompletionTokens)
+	require.Equal(t, 1350, done.Usage.TotalTokens)
+	require.InDelta(t, 0.00705, done.Usage.CostUSD, 1e-9)
+	require.Equal(t, []rpc.ModelUsage{
+		{Role: "coder", Model: "default", Calls: 1, PromptTokens: 1000, CompletionTokens: 200, TotalTokens: 1200, CostUSD: 0.006},
+		{Role: "planner", Model: "default", Calls: 1, PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150, CostUSD: 0.00105},
+	}, done.Usage.ByModel)
+	require.Equal(t, []string{"stop:1350"}, metrics.runs)
+	require.Equal(t, []string{"coder:default:1000/200:$0.00600", "planner:default:100/50:$0.00105"}, metrics.tokens)
+}
+
+func TestAgentRunnerStopsWhenBudgetExhausted(t *testing.T) {
+	reg := llm.NewRegistry()
+	calls := 0
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			calls++
+			return llm.ChatResponse{
+				Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "still working"},
+				Usage:   llm.Usage{PromptTokens: 4000, CompletionTokens: 500},
+			}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	ar := &AgentRunner{
+		Agent:    regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 5}),
+		Strategy: agent.NewStrategyEngine(reg, config.StrategyConfig{Budget: config.BudgetConfig{MaxPromptTokens: 10000}}),
+	}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "budget", Prompt: "p"})
+	require.NoError(t, err)
+
+	var messages []string
+	var done rpc.RunTaskEvent
+	for ev := range ch {
+		switch ev.Type {
+		case "message":
+			messages = append(messages, ev.Message)
+		case "done":
+			done = ev
+		}
+	}
+	require.Equal(t, 3, calls)
+	require.Equal(t, "budget_exhausted", done.FinishReason)
+	require.Equal(t, 3, done.Step)
+	require.Equal(t, 12000, done.Usage.PromptTokens)
+	require.Contains(t, messages, "Run stopped: budget exhausted (max_prompt_tokens)")
+}
+
 func regAgentWithConfig(reg *llm.Registry, cfg config.AgentConfig) *agent.Agent {
 	return agent.New(reg, cfg)
 }
@@ -602,12 +1103,244 @@ func regAgentWithConfig(reg *llm.Registry, cfg config.AgentConfig) *agent.Agent
 type fakeMetrics struct {
 	usage    []string
 	failures []string
+	tokens   []string
+	runs     []string
 }
 
-func (f *fakeMetrics) RecordAgentRun(finishReason string, duration time.Duration, tokenCount int) {}
+func (f *fakeMetrics) RecordAgentRun(finishReason string, duration time.Duration, tokenCount int) {
+	f.runs = append(f.runs, fmt.Sprintf("%s:%d", finishReason, tokenCount))
+}
+func (f *fakeMetrics) RecordTokenUsage(role, model string, promptTokens, completionTokens int, costUSD float64) {
+	f.tokens = append(f.tokens, fmt.Sprintf("%s:%s:%d/%d:$%.5f", role, model, promptTokens, completionTokens, costUSD))
+}
 func (f *fakeMetrics) RecordModelUsage(role, model string) {
 	f.usage = append(f.usage, role+":"+model)
 }
 func (f *fakeMetrics) RecordModelFailure(role, model string) {
 	f.failures = append(f.failures, role+":"+model)
 }
+
+func TestAgentRunnerStreamsProviderDeltas(t *testing.T) {
+	reg := llm.NewRegistry()
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		StreamChunks: []llm.StreamChunk{
+			{Content: "par"},
+			{Content: "tial "},
+			{Content: "[done]"},
+			{FinishReason: "stop"},
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	ar := &AgentRunner{Agent: regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 1})}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "stream", Prompt: "p"})
+	require.NoError(t, err)
+
+	var tokens []string
+	var messageSeen bool
+	for ev := range ch {
+		switch ev.Type {
+		case "token":
+			require.False(t, messageSeen, "tokens should arrive before the final message")
+			require.Equal(t, 1, ev.Step)
+			tokens = append(tokens, ev.Token)
+		case "message":
+			messageSeen = true
+			require.Equal(t, "partial [done]", ev.Message)
+		}
+	}
+	require.Equal(t, []string{"par", "tial ", "[done]"}, tokens)
+	require.True(t, messageSeen)
+}
+
+func TestAgentRunnerDispatchesNativeToolCalls(t *testing.T) {
+	tmp := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "notes.txt"), []byte("native-data"), 0o644))
+	fsTool, err := tools.NewFilesystem(tmp, true)
+	require.NoError(t, err)
+
+	reg := llm.NewRegistry()
+	call := 0
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			call++
+			require.NotEmpty(t, req.Tools, "tool definitions should be offered to the model")
+			if call == 1 {
+				return llm.ChatResponse{
+					Message: llm.ChatMessage{
+						Role:    llm.RoleAssistant,
+						Content: `{"name":"fs.read_file","args":{"path":"ignored.txt"}}`,
+						ToolCalls: []llm.ToolCall{{
+							ID:       "call_1",
+							Type:     "function",
+							Function: llm.ToolFunctionCall{Name: "fs.read_file", Arguments: []byte(`{"path":"notes.txt"}`)},
+						}},
+					},
+					FinishReason: "tool_calls",
+				}, nil
+			}
+			var toolMsg *llm.ChatMessage
+			for i := range req.Messages {
+				if req.Messages[i].Role == llm.RoleTool {
+					toolMsg = &req.Messages[i]
+				}
+			}
+			require.NotNil(t, toolMsg, "tool result should be in history")
+			require.Equal(t, "call_1", toolMsg.ToolCallID)
+			require.Equal(t, "native-data", toolMsg.Content)
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "[done]"}, FinishReason: "stop"}, nil
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	ar := &AgentRunner{
+		Agent: regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 2}),
+		Tools: tools.NewRegistry(fsTool, nil, nil, nil),
+	}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "native-tools", Prompt: "read notes"})
+	require.NoError(t, err)
+
+	var toolOutputs []string
+	var done rpc.RunTaskEvent
+	for ev := range ch {
+		if ev.Type == "tool" {
+			toolOutputs = append(toolOutputs, ev.ToolOutput)
+		}
+		if ev.Type == "done" {
+			done = ev
+		}
+	}
+	require.Equal(t, []string{"native-data"}, toolOutputs)
+	require.Equal(t, "stop", done.FinishReason)
+	require.Equal(t, 2, done.Step)
+}
+
+func TestAgentRunnerFeedsToolResultsIntoNextStep(t *testing.T) {
+	tmp := t.TempDir()
+	fsTool, err := tools.NewFilesystem(tmp, true)
+	require.NoError(t, err)
+
+	reg := llm.NewRegistry()
+	call := 0
+	reg.RegisterProvider("mock", &llmmock.Provider{
+		ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			call++
+			switch call {
+			case 1:
+				return llm.ChatResponse{Message: llm.ChatMessage{
+					Role:    llm.RoleAssistant,
+					Content: `{"name":"fs.read_file","args":{"path":"missing.txt"}}`,
+				}}, nil
+			default:
+				last := req.Messages[len(req.Messages)-1]
+				require.Equal(t, llm.RoleUser, last.Role)
+				require.Contains(t, last.Content, "Tool fs.read_file returned:\nerror:")
+				for _, m := range req.Messages {
+					if m.Role == llm.RoleUser {
+						require.NotContains(t, m.Content, "Continue working", "no nudge needed after a tool result")
+					}
+				}
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "[done]"}, FinishReason: "stop"}, nil
+			}
+		},
+	})
+	reg.RegisterModel("default", llm.ModelRoute{Provider: "mock", Model: "m"}, true)
+
+	ar := &AgentRunner{
+		Agent: regAgentWithConfig(reg, config.AgentConfig{MaxSteps: 3}),
+		Tools: tools.NewRegistry(fsTool, nil, nil, nil),
+	}
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "observe", Prompt: "read missing.txt"})
+	require.NoError(t, err)
+
+	var toolErr string
+	var done rpc.RunTaskEvent
+	for ev := range ch {
+		if ev.Type == "tool" {
+			toolErr = ev.Error
+		}
+		if ev.Type == "done" {
+			done = ev
+		}
+	}
+	require.NotEmpty(t, toolErr)
+	require.Equal(t, "stop", done.FinishReason)
+	require.Equal(t, 2, done.Step)
+	require.Equal(t, 2, call)
+}
+
+func TestAgentRunnerReplaysRecordedSession(t *testing.T) {
+	tmp := t.TempDir()
+	cassette := filepath.Join(tmp, "session.json")
+
+	upstream := &llmmock.Provider{ChatFn: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+		system := req.Messages[0].Content
+		switch {
+		case strings.Contains(system, "reflection"):
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "looks fine"}}, nil
+		case strings.Contains(system, "plan"):
+			return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "1) write notes"}}, nil
+		}
+		for _, m := range req.Messages {
+			if m.Role == llm.RoleTool {
+				return llm.ChatResponse{Message: llm.ChatMessage{Role: llm.RoleAssistant, Content: "notes written [done]"}, FinishReason: "stop"}, nil
+			}
+		}
+		return llm.ChatResponse{
+			Message: llm.ChatMessage{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{
+				ID:       "call_1",
+				Function: llm.ToolFunctionCall{Name: "fs.write_file", Arguments: []byte(`{"path":"notes.txt","content":"hi"}`)},
+			}}},
+			FinishReason: "tool_calls",
+		}, nil
+	}}
+
+	run := func(p llm.Provider) []rpc.RunTaskEvent {
+		reg := llm.NewRegistry()
+		reg.RegisterProvider("llm", p)
+		reg.RegisterModel("default", llm.ModelRoute{Provider: "llm", Model: "m"}, true)
+		a := agent.New(reg, config.AgentConfig{
+			MaxSteps:      3,
+			EnablePlan:    true,
+			EnableReflect: true,
+			EnableTestRun: true,
+			TestCommand:   "echo ok",
+		})
+		fsTool, err := tools.NewFilesystem(t.TempDir(), true)
+		require.NoError(t, err)
+		term := &tools.Terminal{AllowExecution: true, Allowed: []string{"echo"}}
+		ar := &AgentRunner{Agent: a, Tools: tools.NewRegistry(fsTool, term, nil, nil)}
+
+		req, _ := http.NewRequest(http.MethodPost, "/", nil)
+		ch, err := ar.Run(req, rpc.RunTaskRequest{SessionID: "cassette", Prompt: "write notes"})
+		require.NoError(t, err)
+		var events []rpc.RunTaskEvent
+		for ev := range ch {
+			events = append(events, ev)
+		}
+		return events
+	}
+
+	recorder, err := replay.NewProvider("llm", cassette, replay.ModeRecord, upstream)
+	require.NoError(t, err)
+	recorded := run(recorder)
+
+	player, err := replay.NewProvider("llm", cassette, replay.ModeReplay, nil)
+	require.NoError(t, err)
+	replayed := run(player)
+
+	require.Equal(t, recorded, replayed)
+	require.Zero(t, player.Remaining())
+
+	var types []string
+	for _, ev := range replayed {
+		if ev.Type != "token" {
+			types = append(types, ev.Type)
+		}
+	}
+	require.Subset(t, types, []string{"plan", "tool", "test", "reflect", "done"})
+	require.Equal(t, "done", types[len(types)-1])
+}
@@ -6,10 +6,23 @@ type StartSession struct {
 	Model     string `json:"model,omitempty"`
 }
 
-// UserInput represents a chunk of user-supplied text.
+// UserInput represents a chunk of user-supplied text sent during an interactive run.
+// With QuestionID, or while a question is open, it answers that question; otherwise it
+// is added to the session before the next step.
 type UserInput struct {
-	SessionID string `json:"session_id"`
-	Text      string `json:"text"`
+	SessionID  string `json:"session_id"`
+	Text       string `json:"text"`
+	QuestionID string `json:"question_id,omitempty"`
+}
+
+// ApprovalDecision answers an approval_request event.
+type ApprovalDecision struct {
+	SessionID     string                 `json:"session_id,omitempty"`
+	CorrelationID string                 `json:"correlation_id,omitempty"` // the run; required by the NDJSON approval endpoint
+	ApprovalID    string                 `json:"approval_id,omitempty"`    // empty answers the open request
+	Decision      string                 `json:"decision"`                 // approve|deny|edit
+	Args          map[string]interface{} `json:"args,omitempty"`           // edit: the arguments to run the tool with instead
+	Reason        string                 `json:"reason,omitempty"`         // deny: passed on to the model
 }
 
 // RunTaskRequest is the top-level request for starting an agent task.
@@ -22,11 +35,12 @@ type RunTaskRequest struct {
 	Prompt        string     `json:"prompt"`
 	Tools         []ToolCall `json:"tools,omitempty"`
 	ContextPaths  []string   `json:"context_paths,omitempty"`
+	Interactive   bool       `json:"interactive,omitempty"` // the client sends input and answers questions mid-run
 }
 
 // RunTaskEvent streams back progress from the daemon.
 type RunTaskEvent struct {
-	Type          string `json:"type"` // token|message|error|done|tool|plan|reflect|test
+	Type          string `json:"type"` // token|message|error|done|tool|plan|plan_update|reflect|test|escalate|classify|input|question|approval_request|approval
 	SessionID     string `json:"session_id,omitempty"`
 	CorrelationID string `json:"correlation_id,omitempty"`
 	Token         string `json:"token,omitempty"`
@@ -42,19 +56,76 @@ type RunTaskEvent struct {
 	TestSummary   string   `json:"test_summary,omitempty"`
 	FailingTests  []string `json:"failing_tests,omitempty"`
 	TestAttempts  int      `json:"test_attempts,omitempty"`
+	Usage         *RunUsage `json:"usage,omitempty"`
+	Model         string    `json:"model,omitempty"` // escalate/classify: the model the coder switched to
+	Complexity    string    `json:"complexity,omitempty"` // classify: trivial|standard|hard
+	PlanSteps     []PlanStep `json:"plan_steps,omitempty"` // plan/plan_update: the full checklist
+	PlanVersion   int        `json:"plan_version,omitempty"` // plan/plan_update: 1 for the first plan, +1 per revision
+	QuestionID    string     `json:"question_id,omitempty"` // question: the id answers refer to
+	ApprovalID    string                 `json:"approval_id,omitempty"` // approval_request/approval: the id decisions refer to
+	ToolArgs      map[string]interface{} `json:"tool_args,omitempty"`   // approval_request/approval: the arguments the tool runs with
+	Diff          string                 `json:"diff,omitempty"`        // approval_request: the change a write or patch would make
+	Decision      string                 `json:"decision,omitempty"`    // approval: approve|deny|edit
+}
+
+// PlanStep is one step of the structured plan carried by plan and plan_update events.
+type PlanStep struct {
+	ID          int            `json:"id"`
+	Description string         `json:"description"`
+	Status      string         `json:"status"` // pending|in_progress|done|skipped
+	ToolCalls   []PlanToolCall `json:"tool_calls,omitempty"`
+	Tests       []PlanTest     `json:"tests,omitempty"`
+}
+
+// PlanToolCall is a tool call made while a plan step was active.
+type PlanToolCall struct {
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// PlanTest is a test run made while a plan step was active.
+type PlanTest struct {
+	ExitCode int      `json:"exit_code"`
+	Summary  string   `json:"summary,omitempty"`
+	Failing  []string `json:"failing,omitempty"`
+}
+
+// RunUsage reports provider-reported token usage and cost for a run, carried by the done event.
+type RunUsage struct {
+	PromptTokens     int          `json:"prompt_tokens"`
+	CompletionTokens int          `json:"completion_tokens"`
+	TotalTokens      int          `json:"total_tokens"`
+	CostUSD          float64      `json:"cost_usd"`
+	ByModel          []ModelUsage `json:"by_model,omitempty"`
+}
+
+// ModelUsage is the usage of one model in one role (planner, coder or critic).
+type ModelUsage struct {
+	Role             string  `json:"role"`
+	Model            string  `json:"model"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
 }
 
-// ToolCall describes an invocation request.
+// ToolCall describes an invocation request. ID links native model tool calls to their results.
 type ToolCall struct {
+	ID   string                 `json:"id,omitempty"`
 	Name string                 `json:"name"`
 	Args map[string]interface{} `json:"args"`
 }
 
 // RunTaskStreamRequest is the bidirectional stream payload for Connect RPC.
-// The first message must contain the Run task; subsequent messages can carry control signals.
+// The first message must contain the Run task; subsequent messages can carry control signals
+// such as approval decisions and, for interactive runs, user input.
 type RunTaskStreamRequest struct {
-	Run           *RunTaskRequest `json:"run,omitempty"`
-	Cancel        bool            `json:"cancel,omitempty"`
-	SessionID     string          `json:"session_id,omitempty"`
-	CorrelationID string          `json:"correlation_id,omitempty"`
+	Run           *RunTaskRequest   `json:"run,omitempty"`
+	Cancel        bool              `json:"cancel,omitempty"`
+	Input         *UserInput        `json:"input,omitempty"`
+	Approval      *ApprovalDecision `json:"approval,omitempty"`
+	SessionID     string            `json:"session_id,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
 }
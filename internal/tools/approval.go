@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/animus-coder/animus-coder/internal/config"
+)
+
+// ApprovalPolicy decides which tool calls wait for a human decision before they run.
+// A nil policy requires no approvals.
+type ApprovalPolicy struct {
+	Rules   []config.ApprovalRule
+	Timeout time.Duration // unanswered requests are denied after it; 0 waits indefinitely
+}
+
+// NewApprovalPolicy builds the policy configured under tools.approval.
+func NewApprovalPolicy(cfg config.ApprovalConfig) *ApprovalPolicy {
+	return &ApprovalPolicy{Rules: cfg.Rules, Timeout: cfg.Timeout}
+}
+
+// Enabled reports whether any rule is configured.
+func (p *ApprovalPolicy) Enabled() bool {
+	return p != nil && len(p.Rules) > 0
+}
+
+// Requires reports whether the call needs approval and, if so, describes the first
+// matching rule.
+func (p *ApprovalPolicy) Requires(name string, args map[string]interface{}) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	for _, rule := range p.Rules {
+		if matchApprovalRule(rule, name, args) {
+			return describeApprovalRule(rule), true
+		}
+	}
+	return "", false
+}
+
+func matchApprovalRule(rule config.ApprovalRule, name string, args map[string]interface{}) bool {
+	if rule.Tool != "*" && rule.Tool != name {
+		return false
+	}
+	p, hasPath := args["path"].(string)
+	if len(rule.Paths) > 0 && (!hasPath || !underAnyDir(p, rule.Paths)) {
+		return false
+	}
+	if hasPath && underAnyDir(p, rule.ExceptPaths) {
+		return false
+	}
+	for key, want := range rule.Args {
+		got, ok := args[key]
+		if !ok {
+			if want == nil || !reflect.ValueOf(want).IsZero() {
+				return false
+			}
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+// underAnyDir reports whether the workspace-relative path p is one of dirs or inside one.
+func underAnyDir(p string, dirs []string) bool {
+	p = path.Clean(filepath.ToSlash(p))
+	for _, dir := range dirs {
+		dir = path.Clean(filepath.ToSlash(dir))
+		if dir == "." || p == dir || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func describeApprovalRule(rule config.ApprovalRule) string {
+	var conds []string
+	if len(rule.Paths) > 0 {
+		conds = append(conds, "under "+strings.Join(rule.Paths, ", "))
+	}
+	if len(rule.ExceptPaths) > 0 {
+		conds = append(conds, "outside "+strings.Join(rule.ExceptPaths, ", "))
+	}
+	keys := make([]string, 0, len(rule.Args))
+	for key := range rule.Args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		conds = append(conds, fmt.Sprintf("%s=%v", key, rule.Args[key]))
+	}
+	tool := rule.Tool
+	if tool == "*" {
+		tool = "every tool call"
+	}
+	if len(conds) == 0 {
+		return tool + " requires approval"
+	}
+	return fmt.Sprintf("%s (%s) requires approval", tool, strings.Join(conds, "; "))
+}
+
+// PreviewChange renders what a call would change: a unified diff against the current file
+// for fs.write_file and the patch itself for git.apply_patch. Other tools yield "".
+func PreviewChange(reg *Registry, name string, args map[string]interface{}) (string, error) {
+	switch name {
+	case "fs.write_file":
+		p, _ := args["path"].(string)
+		content, _ := args["content"].(string)
+		if reg == nil || reg.FS == nil {
+			return "", errors.New("filesystem tool unavailable")
+		}
+		current, err := reg.FS.ReadFile(p)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		from := "a/" + p
+		if err != nil {
+			from = "/dev/null"
+		}
+		return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(current),
+			B:        difflib.SplitLines(content),
+			FromFile: from,
+			ToFile:   "b/" + p,
+			Context:  3,
+		})
+	case "git.apply_patch":
+		patch, _ := args["patch"].(string)
+		return patch, nil
+	default:
+		return "", nil
+	}
+}
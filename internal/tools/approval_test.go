@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/animus-coder/animus-coder/internal/config"
+)
+
+func TestApprovalPolicyMatchesRules(t *testing.T) {
+	policy := NewApprovalPolicy(config.ApprovalConfig{Rules: []config.ApprovalRule{
+		{Tool: "fs.write_file", ExceptPaths: []string{"docs/"}},
+		{Tool: "git.apply_patch", Args: map[string]interface{}{"dry_run": false}},
+		{Tool: "terminal.exec"},
+	}})
+
+	cases := []struct {
+		name string
+		args map[string]interface{}
+		want bool
+	}{
+		{"fs.write_file", map[string]interface{}{"path": "internal/main.go"}, true},
+		{"fs.write_file", map[string]interface{}{"path": "docs/guide.md"}, false},
+		{"fs.write_file", map[string]interface{}{"path": "./docs/api/rpc.md"}, false},
+		{"fs.write_file", map[string]interface{}{"path": "docs.md"}, true},
+		{"git.apply_patch", map[string]interface{}{"patch": "p", "dry_run": true}, false},
+		{"git.apply_patch", map[string]interface{}{"patch": "p", "dry_run": false}, true},
+		{"git.apply_patch", map[string]interface{}{"patch": "p"}, true},
+		{"terminal.exec", map[string]interface{}{"command": "ls"}, true},
+		{"fs.read_file", map[string]interface{}{"path": "internal/main.go"}, false},
+	}
+	for _, tc := range cases {
+		reason, got := policy.Requires(tc.name, tc.args)
+		if got != tc.want {
+			t.Fatalf("Requires(%s, %v) = %v, want %v", tc.name, tc.args, got, tc.want)
+		}
+		if got && !strings.Contains(reason, tc.name) {
+			t.Fatalf("reason %q does not name the tool", reason)
+		}
+	}
+
+	if reason, _ := policy.Requires("fs.write_file", map[string]interface{}{"path": "a.go"}); reason != "fs.write_file (outside docs/) requires approval" {
+		t.Fatalf("unexpected reason %q", reason)
+	}
+	var none *ApprovalPolicy
+	if none.Enabled() {
+		t.Fatalf("nil policy must be disabled")
+	}
+	if _, ok := none.Requires("terminal.exec", nil); ok {
+		t.Fatalf("nil policy must not require approval")
+	}
+}
+
+func TestPreviewChangeDiffsWrites(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	fsTool, err := NewFilesystem(dir, true)
+	if err != nil {
+		t.Fatalf("new filesystem: %v", err)
+	}
+	reg := NewRegistry(fsTool, nil, nil, nil)
+
+	diff, err := PreviewChange(reg, "fs.write_file", map[string]interface{}{"path": "main.go", "content": "package main\n\nfunc main() { run() }\n"})
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	for _, want := range []string{"--- a/main.go", "+++ b/main.go", "-func main() {}", "+func main() { run() }"} {
+		if !strings.Contains(diff, want) {
+			t.Fatalf("diff missing %q:\n%s", want, diff)
+		}
+	}
+
+	diff, err = PreviewChange(reg, "fs.write_file", map[string]interface{}{"path": "new.go", "content": "package main\n"})
+	if err != nil {
+		t.Fatalf("preview new file: %v", err)
+	}
+	if !strings.Contains(diff, "--- /dev/null") || !strings.Contains(diff, "+package main") {
+		t.Fatalf("unexpected new file diff:\n%s", diff)
+	}
+
+	if diff, _ := PreviewChange(reg, "git.apply_patch", map[string]interface{}{"patch": "diff --git a/x b/x"}); diff != "diff --git a/x b/x" {
+		t.Fatalf("patch preview should be the patch, got %q", diff)
+	}
+}
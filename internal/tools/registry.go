@@ -1,6 +1,11 @@
 package tools
 
-import "github.com/animus-coder/animus-coder/internal/semantic"
+import (
+	"fmt"
+
+	"github.com/animus-coder/animus-coder/internal/config"
+	"github.com/animus-coder/animus-coder/internal/semantic"
+)
 
 // Registry exposes shared tool instances.
 type Registry struct {
@@ -15,6 +20,24 @@ func NewRegistry(fs *Filesystem, term *Terminal, git *GitTool, sem *semantic.Eng
 	return &Registry{FS: fs, Terminal: term, Git: git, Semantic: sem}
 }
 
+// NewRegistryFromConfig builds the sandboxed tools for the configured workspace.
+func NewRegistryFromConfig(cfg *config.Config) (*Registry, error) {
+	sandbox, err := NewSandbox(cfg.Sandbox.WorkingDir, cfg.Sandbox, cfg.Tools)
+	if err != nil {
+		return nil, fmt.Errorf("build sandbox: %w", err)
+	}
+	git := &GitTool{
+		WorkingDir: cfg.Sandbox.WorkingDir,
+		AllowExec:  cfg.Tools.AllowGit && cfg.Sandbox.Enabled,
+		DryRunOnly: !cfg.Sandbox.AllowWrite || !cfg.Tools.AllowFileWrite,
+	}
+	var sem *semantic.Engine
+	if cfg.Tools.EnableSemantic {
+		sem = semantic.NewEngine(sandbox.FS, cfg.Tools.SemanticMaxFiles, cfg.Tools.SemanticMaxFileBytes)
+	}
+	return NewRegistry(sandbox.FS, sandbox.Terminal, git, sem), nil
+}
+
 // Schema returns schema for a given tool name if present.
 func (r *Registry) Schema(name string) (Schema, bool) {
 	for _, s := range r.Schemas() {
@@ -16,6 +16,36 @@ type SchemaField struct {
 	Enum        []string `json:"enum,omitempty"`
 }
 
+// JSONSchema renders the parameters as a JSON schema object for function calling.
+func (s Schema) JSONSchema() map[string]interface{} {
+	props := make(map[string]interface{}, len(s.Parameters))
+	required := make([]string, 0, len(s.Parameters))
+	for _, f := range s.Parameters {
+		prop := map[string]interface{}{"type": f.Type}
+		if f.Description != "" {
+			prop["description"] = f.Description
+		}
+		if len(f.Enum) > 0 {
+			prop["enum"] = f.Enum
+		}
+		if f.Type == "array" {
+			prop["items"] = map[string]interface{}{"type": "string"}
+		}
+		props[f.Name] = prop
+		if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+	out := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		out["required"] = required
+	}
+	return out
+}
+
 // Schemas provides descriptors for available tools (stub).
 func (r *Registry) Schemas() []Schema {
 	s := []Schema{
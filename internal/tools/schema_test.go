@@ -0,0 +1,24 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaJSONSchema(t *testing.T) {
+	schema, ok := NewRegistry(nil, nil, nil, nil).Schema("terminal.exec")
+	require.True(t, ok)
+
+	js := schema.JSONSchema()
+	require.Equal(t, "object", js["type"])
+	require.Equal(t, []string{"command"}, js["required"])
+
+	props := js["properties"].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"type": "string"}, props["command"])
+	require.Equal(t, map[string]interface{}{
+		"type":        "array",
+		"description": "Arguments",
+		"items":       map[string]interface{}{"type": "string"},
+	}, props["args"])
+}